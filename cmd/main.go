@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/skpharma/docker-compose-manager/internal/config"
+	"github.com/skpharma/docker-compose-manager/internal/daemon"
 	"github.com/skpharma/docker-compose-manager/internal/docker"
 	"github.com/skpharma/docker-compose-manager/internal/ui"
 )
@@ -14,9 +22,11 @@ import (
 const (
 	defaultSearchDir = "/home/dockeruser/docker"
 	defaultMaxDepth  = 10
-	cacheMaxAge      = 24 * time.Hour // Cache valid for 24 hours
 )
 
+// version is set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
 // getCacheFile returns the cache file path
 // Tries system-wide cache first, falls back to user cache
 func getCacheFile() string {
@@ -51,6 +61,46 @@ func getCacheFile() string {
 	return filepath.Join(userCacheDir, "cache.json")
 }
 
+// isDir tells whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// printPlannedUpdate prints a project of a plan for review
+func printPlannedUpdate(u docker.PlannedUpdate) {
+	name := u.Project
+	if u.Host != "" {
+		name += " @ " + u.Host
+	}
+	details := "strategy " + string(u.Strategy)
+	if len(u.Services) > 0 {
+		details += ", services " + strings.Join(u.Services, " ")
+	}
+	if u.Backup {
+		details += ", backup first"
+	}
+	fmt.Printf("\n  %s (%s)\n", name, details)
+
+	for _, img := range u.Images {
+		current := img.Current
+		if current == "" {
+			current = "not running"
+		}
+		fmt.Printf("    %s  %s -> %s\n", img.Name, img.CurrentVersion, img.TargetVersion)
+		fmt.Printf("      %s -> %s\n", current, img.Target)
+	}
+	for _, event := range docker.HookEvents {
+		for _, h := range u.Hooks[event] {
+			where := "host"
+			if h.Service != "" {
+				where = h.Service
+			}
+			fmt.Printf("    %s (%s): %s\n", event, where, h.Command)
+		}
+	}
+}
+
 func main() {
 	// Get cache file path
 	// Try system-wide cache first (/var/cache), fall back to user cache (~/.cache)
@@ -59,8 +109,22 @@ func main() {
 	// Check for flags
 	listMode := false
 	updateCacheMode := false
+	daemonMode := false
+	planMode := false
+	applyMode := false
+	planFile := "plan.json"
+	lockMode := false
+	lockUpdate := false
+	var lockProjects []string
 	debugMode := false
 	searchDir := defaultSearchDir
+	checkWorkers := docker.DefaultCheckWorkers
+	registryWorkers := docker.DefaultRegistryWorkers
+	maxParallel := docker.DefaultMaxParallelUpdates
+	var checkMaxAge time.Duration
+	serialUpdates := false
+	dryRun := false
+	configFile := config.DefaultPath()
 
 	for i := 1; i < len(os.Args); i++ {
 		arg := os.Args[i]
@@ -68,16 +132,100 @@ func main() {
 			listMode = true
 		} else if arg == "--update-cache" {
 			updateCacheMode = true
+		} else if arg == "daemon" && i == 1 {
+			daemonMode = true
+		} else if arg == "lock" && i == 1 {
+			lockMode = true
+		} else if arg == "--update" && lockMode {
+			lockUpdate = true
+		} else if arg == "plan" && i == 1 {
+			planMode = true
+		} else if arg == "apply" && i == 1 {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: apply requires a plan file\n")
+				os.Exit(1)
+			}
+			applyMode = true
+			i++
+			planFile = os.Args[i]
+		} else if arg == "--out" || arg == "-o" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a path\n", arg)
+				os.Exit(1)
+			}
+			i++
+			planFile = os.Args[i]
 		} else if arg == "--debug" || arg == "-d" {
 			debugMode = true
+		} else if arg == "--check-max-age" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a duration\n", arg)
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d < 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid value for %s: %s\n", arg, os.Args[i])
+				os.Exit(1)
+			}
+			checkMaxAge = d
+		} else if arg == "--config" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a path\n", arg)
+				os.Exit(1)
+			}
+			i++
+			configFile = os.Args[i]
+		} else if arg == "--serial" {
+			serialUpdates = true
+		} else if arg == "--dry-run" || arg == "-n" {
+			dryRun = true
+		} else if arg == "--workers" || arg == "--registry-workers" || arg == "--max-parallel" {
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a number\n", arg)
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid value for %s: %s\n", arg, os.Args[i])
+				os.Exit(1)
+			}
+			switch arg {
+			case "--workers":
+				checkWorkers = n
+			case "--registry-workers":
+				registryWorkers = n
+			default:
+				maxParallel = n
+			}
 		} else if arg == "--help" || arg == "-h" {
 			fmt.Println("Docker Compose Manager")
 			fmt.Println("\nUsage:")
 			fmt.Println("  docker-compose-manager [OPTIONS] [DIRECTORY]")
+			fmt.Println("  docker-compose-manager daemon [OPTIONS] [DIRECTORY]")
+			fmt.Println("  docker-compose-manager plan [-o FILE] [OPTIONS] [DIRECTORY]")
+			fmt.Println("  docker-compose-manager apply FILE [OPTIONS] [DIRECTORY]")
+			fmt.Println("  docker-compose-manager lock [--update] [PROJECT...] [OPTIONS] [DIRECTORY]")
+			fmt.Println("\nCommands:")
+			fmt.Println("  daemon             Check on the config's schedule and apply updates in maintenance windows")
+			fmt.Println("  plan               Check for updates and write them to a plan file for review")
+			fmt.Println("  apply FILE         Apply a plan, refusing projects whose images changed since")
+			fmt.Println("  lock PROJECT...    Pin the images of projects to their digests in the compose file")
+			fmt.Println("  lock --update      Pull and pin new digests (all locked projects if none are named)")
 			fmt.Println("\nOptions:")
 			fmt.Println("  -l, --list         List all projects and their status (non-interactive)")
 			fmt.Println("  --update-cache     Update cache with latest image versions (for cron)")
 			fmt.Println("  -d, --debug        Enable debug logging to ~/docker-compose-manager-debug.log")
+			fmt.Println("  --workers N        Number of projects checked for updates in parallel (default 4)")
+			fmt.Println("  --registry-workers N")
+			fmt.Println("                     Max concurrent pulls per registry, 0 = unlimited (default 2)")
+			fmt.Println("  --check-max-age D  Skip images checked less than D ago, e.g. 6h (default: check all)")
+			fmt.Println("  --max-parallel N   Number of projects updated in parallel from the TUI (default 3)")
+			fmt.Println("  --serial           Update projects one at a time, ordered by dcm.update.priority")
+			fmt.Println("  -n, --dry-run      Show the commands of updates, start/stop etc. instead of running them")
+			fmt.Println("  -o, --out FILE     Plan file written by plan (default plan.json)")
+			fmt.Println("  --config PATH      Config file (default ~/.config/docker-compose-manager/config.json)")
 			fmt.Println("  -h, --help         Show this help message")
 			fmt.Println("\nExamples:")
 			fmt.Println("  docker-compose-manager")
@@ -85,11 +233,27 @@ func main() {
 			fmt.Println("  docker-compose-manager --list")
 			fmt.Println("  docker-compose-manager --debug")
 			fmt.Println("  docker-compose-manager --update-cache  # For cron job")
+			fmt.Println("  docker-compose-manager daemon          # Unattended updates, e.g. as a systemd service")
+			fmt.Println("  docker-compose-manager daemon -n       # Only log what the daemon would update")
+			fmt.Println("  docker-compose-manager plan -o updates.json")
+			fmt.Println("  docker-compose-manager apply updates.json")
+			fmt.Println("  docker-compose-manager lock web-app ./docker")
 			os.Exit(0)
+		} else if lockMode && !isDir(arg) {
+			lockProjects = append(lockProjects, arg)
 		} else {
 			searchDir = arg
 		}
 	}
+	if lockMode && len(lockProjects) == 0 && !lockUpdate {
+		fmt.Fprintf(os.Stderr, "Error: lock requires project names, or --update for all locked projects\n")
+		os.Exit(1)
+	}
+	if dryRun && (updateCacheMode || planMode) {
+		// Update checks find updates by pulling, there is nothing to show instead
+		fmt.Fprintf(os.Stderr, "Error: --dry-run can't be used with --update-cache or plan, update checks pull images\n")
+		os.Exit(1)
+	}
 
 	// Check if search directory exists
 	if _, err := os.Stat(searchDir); os.IsNotExist(err) {
@@ -97,25 +261,163 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Try to load from cache first
-	var projects []*docker.Project
-	projects, err := docker.LoadFromCache(cacheFile, cacheMaxAge)
+	// Ctrl+C / SIGTERM cancel running docker commands in CLI modes.
+	// The TUI reads Ctrl+C as a key and cancels through the same context.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
+	hosts := make([]*docker.Host, 0, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		hosts = append(hosts, &docker.Host{
+			Name:       h.Name,
+			Context:    h.Context,
+			DockerHost: h.DockerHost,
+			TLSVerify:  h.TLSVerify,
+			CertPath:   h.CertPath,
+			SearchDir:  h.SearchDir,
+		})
+	}
+	hostBackends := make(map[*docker.Host]string, len(hosts))
+	for i, h := range hosts {
+		hostBackends[h] = cfg.Hosts[i].Backend
+	}
+
+	policies, err := docker.ParseUpdatePolicies(cfg.UpdatePolicies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: update_policies: %v\n", err)
+		os.Exit(1)
+	}
+	strategies, err := docker.ParseUpdateStrategies(cfg.UpdateStrategies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: update_strategies: %v\n", err)
+		os.Exit(1)
+	}
+	hooks := make(map[string]docker.Hooks, len(cfg.Hooks))
+	for name, events := range cfg.Hooks {
+		hooks[name] = make(docker.Hooks, len(events))
+		for event, list := range events {
+			for _, h := range list {
+				hooks[name][docker.HookEvent(event)] = append(hooks[name][docker.HookEvent(event)],
+					docker.Hook{Command: h.Command, Service: h.Service, Timeout: time.Duration(h.Timeout)})
+			}
+		}
+	}
+	options, err := docker.NewOptions(docker.Options{
+		Policies:   policies,
+		Strategies: strategies,
+		Hooks:      hooks,
+		Verify: docker.VerifyOptions{
+			Enabled:       cfg.Verify.Enabled,
+			HealthTimeout: time.Duration(cfg.Verify.HealthTimeout),
+			GracePeriod:   time.Duration(cfg.Verify.GracePeriod),
+			Rollback:      !cfg.Verify.KeepFailed,
+			Probes:        cfg.Verify.Probes,
+		},
+		Backup: docker.BackupOptions{
+			Dir:          cfg.Backup.Dir,
+			Keep:         cfg.Backup.Keep,
+			Image:        cfg.Backup.Image,
+			BeforeUpdate: cfg.Backup.BeforeUpdate,
+			Stop:         cfg.Backup.Stop,
+		},
+	})
 	if err != nil {
-		// Cache miss or expired - scan for projects
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The compose implementation is detected once, not retried on every command
+	if backend, err := docker.DetectBackend(ctx, nil, cfg.Backend); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, assuming docker compose\n", err)
+	} else {
+		docker.SetLocalBackend(backend)
+	}
+
+	cache := docker.NewCache(cacheFile, []string{searchDir}, version)
+	cache.Exclude = cfg.Exclude
+
+	// Discovery results stay valid until a searched directory or compose
+	// file changes; update check results are kept either way
+	var projects []*docker.Project
+	cached, err := cache.Load()
+	if cached != nil {
+		cache.Adopted = cached.Adopted
+	}
+	if err == nil {
+		err = cached.DiscoveryChanged(cfg.Exclude)
+	}
+
+	rescanned := false
+	if err == nil {
+		projects = docker.LocalProjects(cached.Projects)
+		cache.Directories = cached.Directories
+		cache.Diagnostics = cached.Diagnostics
+	} else {
+		// Cache miss, rejected or projects changed - scan for projects
 		fmt.Println("🔍 Scanning for Docker Compose projects...")
 		fmt.Printf("   Directory: %s\n", searchDir)
+		fmt.Printf("   Rescan reason: %v\n", err)
 
-		projects, err = docker.FindProjects(searchDir, defaultMaxDepth)
+		discovery, err := docker.FindProjects(searchDir, defaultMaxDepth, cfg.Exclude)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			if len(hosts) == 0 {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			// Projects on other hosts are enough
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			discovery = &docker.Discovery{Directories: map[string]time.Time{}}
 		}
+		discovery.Adopt(cache.Adopted)
+
+		projects = discovery.Projects
+		cache.Directories = discovery.Directories
+		cache.Diagnostics = discovery.Diagnostics
+		rescanned = true
 
 		fmt.Printf("✓ Found %d projects\n", len(projects))
+		if n := len(discovery.Diagnostics); n > 0 {
+			fmt.Printf("   %d discovery diagnostics (see Discovery Diagnostics in the menu)\n", n)
+		}
+	}
+
+	// Other hosts are discovered on every start, changes in remote
+	// directories can't be detected cheaply
+	for _, h := range hosts {
+		fmt.Printf("🔍 Scanning %s (%s)...\n", h.Name, h.SearchDir)
+		backend, err := docker.DetectBackend(ctx, h, hostBackends[h])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		h.SetBackend(backend)
+
+		discovery, err := docker.FindHostProjects(ctx, h, defaultMaxDepth, cfg.Exclude)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		for _, d := range discovery.Diagnostics {
+			fmt.Fprintf(os.Stderr, "   %s: %s %s: %s\n", h.Name, d.Kind, d.Path, d.Message)
+		}
+		fmt.Printf("✓ Found %d projects on %s (%s)\n", len(discovery.Projects), h.Name, backend.Name())
+		projects = append(projects, discovery.Projects...)
+	}
 
-		// Save to cache
-		if err := docker.SaveToCache(projects, cacheFile); err != nil {
+	// Status is never trusted from the cache
+	docker.RefreshStatuses(ctx, projects)
+
+	if rescanned || len(hosts) > 0 {
+		if cached != nil {
+			projects = docker.CarryOverImageInfo(projects, cached.Projects)
+		}
+		if err := cache.Save(projects); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
 		}
 
@@ -123,22 +425,36 @@ func main() {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	// Update cache mode - check for updates and save to cache (for cron)
-	if updateCacheMode {
+	for _, p := range projects {
+		p.SetOptions(options)
+	}
+
+	checker := docker.NewUpdateChecker(checkWorkers, registryWorkers)
+	checker.MaxAge = checkMaxAge
+
+	// Update cache mode - check for updates and save to cache (for cron).
+	// Plan mode checks the same way before planning.
+	if updateCacheMode || planMode {
 		fmt.Printf("🔍 Checking for updates...\n")
 		fmt.Printf("Cache location: %s\n", cacheFile)
-		fmt.Printf("Found %d projects\n\n", len(projects))
+		fmt.Printf("Found %d projects (%d workers, %d per registry)\n\n", len(projects), checker.Workers, checker.RegistryWorkers)
 
-		for i, p := range projects {
-			fmt.Printf("[%d/%d] %-20s ", i+1, len(projects), p.Name)
-			os.Stdout.Sync() // Flush output immediately
+		completed := 0
+		for ev := range checker.Run(ctx, projects) {
+			if !ev.Done {
+				continue
+			}
+			ev.Project.ApplyCheck(ev.Result)
+			completed++
 
-			err := p.UpdateImageInfo()
-			if err != nil {
-				fmt.Printf("❌ error: %v\n", err)
+			fmt.Printf("[%d/%d] %-20s ", completed, len(projects), ev.Project.Name)
+			if ev.Err != nil {
+				fmt.Printf("❌ error: %v\n", ev.Err)
+			} else if ev.Project.UpdatePolicy() == docker.PolicyPinned {
+				fmt.Printf("📌 pinned, not checked\n")
 			} else {
 				updateCount := 0
-				for _, img := range p.ImageInfo {
+				for _, img := range ev.Project.ImageInfo {
 					if img.HasUpdate {
 						updateCount++
 					}
@@ -149,17 +465,217 @@ func main() {
 					fmt.Printf("✓ up to date\n")
 				}
 			}
+			os.Stdout.Sync() // Flush output immediately
+		}
 
-			// Save cache incrementally after each project
-			if err := docker.SaveToCache(projects, cacheFile); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
-			}
+		// Saved once every result is applied
+		if err := cache.Save(projects); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+
+		if ctx.Err() != nil {
+			fmt.Printf("\n✗ Cancelled after %d/%d projects, partial results saved: %s\n", completed, len(projects), cacheFile)
+			os.Exit(130)
 		}
 
 		fmt.Printf("\n✓ Cache updated successfully: %s\n", cacheFile)
+		if updateCacheMode {
+			os.Exit(0)
+		}
+	}
+
+	// Plan mode - write the updates found to a plan file for review
+	if planMode {
+		fmt.Printf("\n📝 Planning updates...\n")
+		plan, err := docker.NewPlan(ctx, projects)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, u := range plan.Projects {
+			printPlannedUpdate(u)
+		}
+		if err := plan.Save(planFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write plan: %v\n", err)
+			os.Exit(1)
+		}
+		if len(plan.Projects) == 0 {
+			fmt.Printf("\n✓ Nothing to update, empty plan written to %s\n", planFile)
+			os.Exit(0)
+		}
+		fmt.Printf("\n✓ Plan with %d project(s) written to %s\n", len(plan.Projects), planFile)
+		fmt.Printf("  Review it, then run: docker-compose-manager apply %s\n", planFile)
+		os.Exit(0)
+	}
+
+	// Apply mode - run a reviewed plan, nothing more
+	if applyMode {
+		plan, err := docker.LoadPlan(planFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🚀 Applying %s (%d project(s), planned %s)\n\n", planFile, len(plan.Projects), plan.Created.Format("2006-01-02 15:04"))
+
+		failed := 0
+		for i, u := range plan.Projects {
+			fmt.Printf("[%d/%d] %-20s ", i+1, len(plan.Projects), u.Project)
+			var project *docker.Project
+			for _, p := range projects {
+				if p.ComposeFile == u.ComposeFile && p.Host == u.Host {
+					project = p
+				}
+			}
+			if project == nil {
+				fmt.Printf("✗ not found: %s\n", u.ComposeFile)
+				failed++
+				continue
+			}
+
+			applyCtx, recorder := ctx, (*docker.Recorder)(nil)
+			if dryRun {
+				recorder = &docker.Recorder{}
+				applyCtx = docker.WithDryRun(ctx, recorder)
+			}
+			err := project.ApplyPlan(applyCtx, u)
+			switch {
+			case err == nil && dryRun:
+				fmt.Printf("✓ would run:\n")
+				for _, step := range recorder.Steps() {
+					fmt.Printf("    %s\n", step)
+				}
+			case err == nil && project.UpdateWarning != "":
+				fmt.Printf("✓ updated, ⚠ %s\n", project.UpdateWarning)
+			case err == nil:
+				fmt.Printf("✓ updated\n")
+			case errors.Is(err, docker.ErrPlanOutdated):
+				fmt.Printf("⚠ refused, %v\n", err)
+				failed++
+			default:
+				fmt.Printf("✗ %v\n", err)
+				failed++
+			}
+			os.Stdout.Sync()
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		if err := cache.Save(projects); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\n✗ Cancelled\n")
+			os.Exit(130)
+		}
+		if failed > 0 {
+			fmt.Printf("\n✗ %d of %d project(s) not updated, run plan again for them\n", failed, len(plan.Projects))
+			os.Exit(1)
+		}
+		fmt.Printf("\n✓ Plan applied\n")
 		os.Exit(0)
 	}
 
+	// Lock mode - pin the images of projects to their digests
+	if lockMode {
+		var targets []*docker.Project
+		if len(lockProjects) == 0 {
+			// --update alone refreshes every project with pinned images
+			states := docker.CheckDrifts(ctx, projects)
+			for _, p := range projects {
+				if states[p.ID()].Lock != docker.LockNone {
+					targets = append(targets, p)
+				}
+			}
+		}
+		for _, name := range lockProjects {
+			found := false
+			for _, p := range projects {
+				if p.Name == name {
+					targets = append(targets, p)
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(os.Stderr, "Error: no project named %s\n", name)
+				os.Exit(1)
+			}
+		}
+		if len(targets) == 0 {
+			fmt.Println("No locked projects")
+			os.Exit(0)
+		}
+
+		failed, changed := 0, 0
+		for _, p := range targets {
+			fmt.Printf("🔒 %s (%s)\n", p.Name, p.ComposeFile)
+			lockCtx, recorder := ctx, (*docker.Recorder)(nil)
+			if dryRun {
+				recorder = &docker.Recorder{}
+				lockCtx = docker.WithDryRun(ctx, recorder)
+			}
+			images, err := p.Lock(lockCtx, lockUpdate)
+			for _, img := range images {
+				switch {
+				case img.Skipped != "":
+					fmt.Printf("    line %d: %s skipped, %s\n", img.Line, img.Old, img.Skipped)
+				case img.New == img.Old:
+					fmt.Printf("    line %d: %s unchanged\n", img.Line, img.Old)
+				default:
+					fmt.Printf("    line %d: %s -> %s\n", img.Line, img.Old, img.New)
+					changed++
+				}
+			}
+			if recorder != nil {
+				for _, step := range recorder.Steps() {
+					fmt.Printf("    would run: %s\n", step)
+				}
+			}
+			if err != nil {
+				fmt.Printf("    ✗ %v\n", err)
+				failed++
+			}
+			if ctx.Err() != nil {
+				os.Exit(130)
+			}
+		}
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+		if lockUpdate && changed > 0 && !dryRun {
+			fmt.Println("\n✓ New digests pinned. Apply the projects (action menu or docker compose up -d) to run them.")
+		} else if !dryRun {
+			fmt.Println("\n✓ Images locked")
+		}
+		os.Exit(0)
+	}
+
+	// Daemon mode - check on a schedule and apply updates unattended
+	if daemonMode {
+		d, err := daemon.New(cfg.Daemon, options, checker, cache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid daemon config: %v\n", err)
+			os.Exit(1)
+		}
+		d.DryRun = dryRun
+
+		watcher, err := docker.NewWatcher(searchDir, defaultMaxDepth, cfg.Exclude)
+		if err != nil {
+			fmt.Printf("Not watching %s for changes: %v\n", searchDir, err)
+			watcher = nil
+		} else {
+			defer watcher.Close()
+		}
+
+		if err := d.Run(ctx, projects, watcher); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// List mode - just print projects and exit
 	if listMode {
 		fmt.Println("\nDocker Compose Projects:")
@@ -170,7 +686,13 @@ func main() {
 				status = fmt.Sprintf("Running (%d)", p.RunningContainers)
 			}
 			fmt.Printf("%2d. %-20s  %s\n", i+1, p.Name, status)
+			if p.Host != "" {
+				fmt.Printf("    Host: %s\n", p.Host)
+			}
 			fmt.Printf("    Path: %s\n", p.Path)
+			for _, warning := range p.Warnings {
+				fmt.Printf("    Warning: %s\n", warning)
+			}
 		}
 		fmt.Printf("\nTotal: %d projects\n", len(projects))
 		os.Exit(0)
@@ -184,7 +706,18 @@ func main() {
 		time.Sleep(1 * time.Second)
 	}
 
-	model := ui.NewModel(projects, cacheFile, debugMode)
+	scheduler := docker.NewUpdateScheduler(maxParallel, serialUpdates)
+
+	// Pick up projects added, edited or removed while the TUI is open
+	watcher, err := docker.NewWatcher(searchDir, defaultMaxDepth, cfg.Exclude)
+	if err != nil {
+		fmt.Printf("Not watching %s for changes: %v\n", searchDir, err)
+		watcher = nil
+	} else {
+		defer watcher.Close()
+	}
+
+	model := ui.NewModel(ctx, projects, append([]*docker.Host{nil}, hosts...), cache, options, checker, scheduler, watcher, debugMode, dryRun)
 
 	// Use inline mode instead of alt screen to avoid diff-rendering artifacts
 	// This forces a full redraw on every update
@@ -194,7 +727,9 @@ func main() {
 		tea.WithMouseCellMotion(),
 	)
 
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	stop() // Interrupt anything the TUI left running
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config is the optional configuration file. Every setting has a default,
+// so a missing file is the same as an empty one.
+type Config struct {
+	// Exclude lists paths skipped during project discovery, relative to the
+	// search directory, in .dcmignore syntax (e.g. "backups/", "archive/**")
+	Exclude []string `json:"exclude"`
+
+	// Backend is the compose implementation of the local daemon: "docker"
+	// (compose v2), "docker-compose" (v1), "podman", "nerdctl", or "auto"
+	// (default) to detect it at startup
+	Backend string `json:"backend"`
+
+	// Hosts are docker daemons managed in addition to the local one
+	Hosts []Host `json:"hosts"`
+
+	// UpdatePolicies sets update policies by project name or
+	// "project/service" ("auto", "notify-only", "patch-only", "pinned");
+	// they take precedence over dcm.update.policy labels
+	UpdatePolicies map[string]string `json:"update_policies"`
+
+	// UpdateStrategies sets how projects are recreated by project name
+	// ("full", "changed", "rolling"); they take precedence over
+	// dcm.update.strategy labels
+	UpdateStrategies map[string]string `json:"update_strategies"`
+
+	// Daemon configures `docker-compose-manager daemon`
+	Daemon Daemon `json:"daemon"`
+
+	// Verify configures checks after updates and the rollback on failure
+	Verify Verify `json:"verify"`
+
+	// Hooks are commands run before/after operations by project name and
+	// event ("pre_update", "post_start", ...); per event they replace the
+	// x-dcm-hooks of the compose file
+	Hooks map[string]map[string][]Hook `json:"hooks"`
+
+	// Backup configures the volume backups before updates and on demand
+	Backup Backup `json:"backup"`
+}
+
+// Backup configures volume backups. The archives are written by a helper
+// container, so Dir is on the machine the project's daemon runs on.
+type Backup struct {
+	Dir          string `json:"dir"`           // "/var/backups/docker-compose-manager" by default
+	Keep         int    `json:"keep"`          // Backups kept per project, 5 by default
+	Image        string `json:"image"`         // Helper image with sh and tar, "alpine:3" by default
+	BeforeUpdate bool   `json:"before_update"` // Back up before updates: default of the TUI toggle, the daemon only if set
+	Stop         bool   `json:"stop"`          // Stop the project while backing up
+}
+
+// Hook is a shell command run on the project's host, or in a service's
+// container if Service is set
+type Hook struct {
+	Command string `json:"command"`
+	Service string `json:"service"`
+	Timeout Duration `json:"timeout"` // e.g. "30m", 10 minutes by default
+}
+
+// Verify configures the verification after updates (TUI, daemon and CLI)
+type Verify struct {
+	Enabled       bool              `json:"enabled"`        // Check containers after every update
+	HealthTimeout Duration          `json:"health_timeout"` // Time to become running/healthy, "2m" by default
+	GracePeriod   Duration          `json:"grace_period"`   // Time to keep running without restarts, "30s" by default
+	KeepFailed    bool              `json:"keep_failed"`    // Don't roll back to the previous images on failure
+	Probes        map[string]string `json:"probes"`         // HTTP URL to check by project name
+}
+
+// Duration is a time.Duration written as a string like "90s" or "2m"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Daemon configures unattended checks and updates. Schedule and windows are
+// in local time; the daemon package parses and validates them at startup.
+type Daemon struct {
+	Schedule           string   `json:"schedule"`            // Cron expression of update checks, "0 3 * * *" by default
+	MaintenanceWindows []string `json:"maintenance_windows"` // When updates may be applied, e.g. "Sat,Sun 02:00-06:00" (always if empty)
+	QuietHours         []string `json:"quiet_hours"`         // When notifications are held back, e.g. "22:00-07:00"
+	NotifyCommand      string   `json:"notify_command"`      // Run with sh -c, message on stdin and in $DCM_MESSAGE
+	NotifyURL          string   `json:"notify_url"`          // Message is POSTed as text/plain
+}
+
+// backends are the valid values of Backend
+var backends = []string{"", "auto", "docker", "docker-compose", "podman", "nerdctl"}
+
+// policies are the valid values of UpdatePolicies ("ignore" = pinned)
+var policies = []string{"auto", "notify-only", "patch-only", "pinned", "ignore"}
+
+// strategies are the valid values of UpdateStrategies
+var strategies = []string{"full", "changed", "rolling"}
+
+// Host is a docker daemon reached through a docker context or DOCKER_HOST.
+// For ssh:// hosts, SearchDir is on the remote machine and every command
+// runs there over ssh (key-based login required).
+type Host struct {
+	Name       string `json:"name"`        // Shown in the TUI
+	Context    string `json:"context"`     // Docker context, or
+	DockerHost string `json:"docker_host"` // tcp://host:2376 or ssh://user@host
+	TLSVerify  bool   `json:"tls_verify"`  // Verify the daemon certificate (tcp)
+	CertPath   string `json:"cert_path"`   // Directory with ca.pem, cert.pem, key.pem (tcp)
+	SearchDir  string `json:"search_dir"`  // Where the host's compose projects are
+	Backend    string `json:"backend"`     // Like Config.Backend, detected on the host by default
+}
+
+// DefaultPath returns the config file location:
+// $XDG_CONFIG_HOME/docker-compose-manager/config.json, or ~/.config/... if unset
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "docker-compose-manager", "config.json")
+}
+
+// Load reads the config file at path. A missing file yields the defaults;
+// unknown keys are rejected so typos don't go unnoticed.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate checks settings the JSON decoder can't
+func (c *Config) validate() error {
+	if !validBackend(c.Backend) {
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	if c.Backup.Dir != "" && !strings.HasPrefix(c.Backup.Dir, "/") {
+		return fmt.Errorf("backup.dir must be an absolute path: %q", c.Backup.Dir)
+	}
+	if c.Backup.Keep < 0 {
+		return fmt.Errorf("backup.keep must not be negative")
+	}
+	for name, probe := range c.Verify.Probes {
+		if !strings.HasPrefix(probe, "http://") && !strings.HasPrefix(probe, "https://") {
+			return fmt.Errorf("verify.probes[%s]: not an http(s) URL: %q", name, probe)
+		}
+	}
+	for name, strategy := range c.UpdateStrategies {
+		if !contains(strategies, strategy) {
+			return fmt.Errorf("update_strategies[%s]: unknown strategy %q", name, strategy)
+		}
+	}
+	for key, policy := range c.UpdatePolicies {
+		if !contains(policies, policy) {
+			return fmt.Errorf("update_policies[%s]: unknown policy %q", key, policy)
+		}
+	}
+
+	names := make(map[string]bool, len(c.Hosts))
+	for i, h := range c.Hosts {
+		if h.Name == "" {
+			return fmt.Errorf("hosts[%d]: name is required", i)
+		}
+		if h.Name == "local" || names[h.Name] {
+			return fmt.Errorf("hosts[%d]: name %q is reserved or used twice", i, h.Name)
+		}
+		names[h.Name] = true
+
+		if (h.Context == "") == (h.DockerHost == "") {
+			return fmt.Errorf("host %s: set either context or docker_host", h.Name)
+		}
+		if h.DockerHost != "" {
+			scheme, _, _ := strings.Cut(h.DockerHost, "://")
+			switch scheme {
+			case "tcp", "ssh", "unix":
+			default:
+				return fmt.Errorf("host %s: unsupported docker_host %q", h.Name, h.DockerHost)
+			}
+		}
+
+		if !validBackend(h.Backend) {
+			return fmt.Errorf("host %s: unknown backend %q", h.Name, h.Backend)
+		}
+		// Contexts and DOCKER_HOST only select docker daemons
+		if (h.Backend == "podman" || h.Backend == "nerdctl") && !strings.HasPrefix(h.DockerHost, "ssh://") {
+			return fmt.Errorf("host %s: %s hosts must use docker_host ssh://...", h.Name, h.Backend)
+		}
+	}
+	return nil
+}
+
+// validBackend reports whether name is one of the known backends
+func validBackend(name string) bool {
+	return contains(backends, name)
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`"30s"`, 30 * time.Second, false},
+		{`"1h30m"`, 90 * time.Minute, false},
+		{`"0s"`, 0, false},
+		{`"-5m"`, 0, true},
+		{`"soon"`, 0, true},
+		{`30`, 0, true},
+	}
+	for _, tt := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(tt.json), &d)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if time.Duration(d) != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.json, time.Duration(d), tt.want)
+		}
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	var hook Hook
+	if err := json.Unmarshal([]byte(`{"command": "true", "timeout": "2m"}`), &hook); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(hook.Timeout) != 2*time.Minute {
+		t.Errorf("Timeout = %s, want 2m", time.Duration(hook.Timeout))
+	}
+	if err := json.Unmarshal([]byte(`{"command": "true", "timeout": "2 minutes"}`), &hook); err == nil {
+		t.Error("invalid timeout accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"0 3 * * *", false},
+		{"*/30 2-5 * * sat,sun", false},
+		{"0 0 1 jan *", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{" @weekly ", false},
+		{"0 3 * *", true},
+		{"60 * * * *", true},
+		{"0 24 * * *", true},
+		{"0 0 0 * *", true},
+		{"0 0 * 13 *", true},
+		{"0 0 * * 8", true},
+		{"0 0 * * funday", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"@often", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchedule(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSchedule(%q) error = %v, want error %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  string
+		expr string
+		from string // RFC 3339, the offset picks the pass of a repeated hour
+		want string // RFC 3339, empty for never
+	}{
+		{"utc", "UTC", "0 3 * * *", "2026-10-18T12:00:00Z", "2026-10-19T03:00:00Z"},
+		{"same day", "UTC", "0 3 * * *", "2026-10-18T01:59:30Z", "2026-10-18T03:00:00Z"},
+		{"strictly after", "UTC", "0 3 * * *", "2026-10-18T03:00:00Z", "2026-10-19T03:00:00Z"},
+		{"half hour offset", "Asia/Kolkata", "0 3 * * *", "2026-10-18T12:00:00+05:30", "2026-10-19T03:00:00+05:30"},
+		{"half hour offset minutes", "Asia/Kolkata", "*/15 * * * *", "2026-10-18T12:07:00+05:30", "2026-10-18T12:15:00+05:30"},
+		{"half hour offset with dst", "Australia/Adelaide", "0 3 * * *", "2026-10-18T12:00:00+10:30", "2026-10-19T03:00:00+10:30"},
+		{"quarter hour offset", "Asia/Kathmandu", "0 * * * *", "2026-10-18T12:10:00+05:45", "2026-10-18T13:00:00+05:45"},
+		{"weekdays", "UTC", "0 3 * * 1-5", "2026-10-16T04:00:00Z", "2026-10-19T03:00:00Z"}, // Friday to Monday
+		{"dom or dow", "UTC", "0 0 1 * mon", "2026-10-27T00:00:00Z", "2026-11-01T00:00:00Z"},
+		{"leap day", "UTC", "0 0 29 2 *", "2026-10-18T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"never", "UTC", "0 0 30 2 *", "2026-10-18T00:00:00Z", ""},
+		// 02:30 doesn't exist when DST starts, the run is skipped that day
+		{"dst start skipped", "Europe/Berlin", "30 2 * * *", "2026-03-29T00:00:00+01:00", "2026-03-30T02:30:00+02:00"},
+		{"dst start hourly", "Europe/Berlin", "0 * * * *", "2026-03-29T01:30:00+01:00", "2026-03-29T03:00:00+02:00"},
+		{"dst start new york", "America/New_York", "0 3 * * *", "2026-03-08T00:00:00-05:00", "2026-03-08T03:00:00-04:00"},
+		// 02:00-02:59 happens twice when DST ends, each time runs once
+		{"dst end once", "Europe/Berlin", "30 2 * * *", "2026-10-25T00:00:00+02:00", "2026-10-25T02:30:00+01:00"},
+		{"dst end after", "Europe/Berlin", "30 2 * * *", "2026-10-25T02:40:00+01:00", "2026-10-26T02:30:00+01:00"},
+		{"dst end first pass", "Europe/Berlin", "*/20 * * * *", "2026-10-25T02:50:00+02:00", "2026-10-25T03:00:00+01:00"},
+		{"dst end second pass", "Europe/Berlin", "*/20 * * * *", "2026-10-25T02:50:00+01:00", "2026-10-25T03:00:00+01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.loc)
+			if err != nil {
+				t.Skipf("no time zone data: %v", err)
+			}
+			s, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := s.Next(from.In(loc))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want never", tt.from, got.Format(time.RFC3339))
+				}
+				return
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package daemon runs update checks on a cron schedule and applies the
+// updates policies allow without asking, inside maintenance windows.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skpharma/docker-compose-manager/internal/config"
+	"github.com/skpharma/docker-compose-manager/internal/docker"
+)
+
+// DefaultSchedule checks for updates every night
+const DefaultSchedule = "0 3 * * *"
+
+// Daemon checks projects for updates on a schedule. Updates of auto and
+// patch-only services are applied inside the maintenance windows, one
+// project at a time ordered by priority; notify-only updates are only
+// reported. Everything runs on the goroutine calling Run.
+type Daemon struct {
+	DryRun bool // Log the commands of each update instead of running them
+
+	schedule *Schedule
+	windows  []Window // Maintenance windows, empty = always
+	notifier *Notifier
+	checker  *docker.UpdateChecker
+	cache    *docker.Cache
+	options  *docker.Options // Given to projects found while running
+
+	projects []*docker.Project
+	pending  map[string]bool // IDs of projects with updates to apply
+	notified map[string]bool // Notify-only updates already reported
+}
+
+// New creates a daemon from the config
+func New(cfg config.Daemon, options *docker.Options, checker *docker.UpdateChecker, cache *docker.Cache) (*Daemon, error) {
+	if cfg.Schedule == "" {
+		cfg.Schedule = DefaultSchedule
+	}
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := ParseWindows(cfg.MaintenanceWindows)
+	if err != nil {
+		return nil, fmt.Errorf("maintenance_windows: %w", err)
+	}
+	quiet, err := ParseWindows(cfg.QuietHours)
+	if err != nil {
+		return nil, fmt.Errorf("quiet_hours: %w", err)
+	}
+
+	return &Daemon{
+		schedule: schedule,
+		windows:  windows,
+		notifier: &Notifier{Command: cfg.NotifyCommand, URL: cfg.NotifyURL, Quiet: quiet},
+		checker:  checker,
+		cache:    cache,
+		options:  options,
+		pending:  make(map[string]bool),
+		notified: make(map[string]bool),
+	}, nil
+}
+
+// Run checks and updates projects until ctx is cancelled. With a watcher,
+// projects added, edited or removed below the search directory are picked up.
+func (d *Daemon) Run(ctx context.Context, projects []*docker.Project, watcher *docker.Watcher) error {
+	d.projects = projects
+	var watchEvents <-chan docker.WatchEvent
+	if watcher != nil {
+		watchEvents = watcher.Events
+	}
+
+	nextCheck := d.schedule.Next(time.Now())
+	if nextCheck.IsZero() {
+		return fmt.Errorf("schedule never runs")
+	}
+	log.Printf("daemon started with %d projects, next check at %s", len(d.projects), nextCheck.Format(time.RFC1123))
+	if d.DryRun {
+		log.Print("dry run: updates are only logged, not applied")
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(d.wakeUp(nextCheck)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Print("daemon stopped")
+			return nil
+
+		case ev, ok := <-watchEvents:
+			timer.Stop()
+			if !ok {
+				watchEvents = nil
+				continue
+			}
+			d.applyWatchEvent(ctx, ev)
+			continue
+
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		if !now.Before(nextCheck) {
+			d.check(ctx)
+			nextCheck = d.schedule.Next(now)
+			log.Printf("next check at %s", nextCheck.Format(time.RFC1123))
+		}
+		if len(d.pending) > 0 && d.inWindow(time.Now()) {
+			d.apply(ctx)
+		}
+		d.notifier.Flush(ctx)
+	}
+}
+
+// wakeUp returns when the loop has something to do: the next check, the
+// next maintenance window with updates pending, or the end of quiet hours
+func (d *Daemon) wakeUp(nextCheck time.Time) time.Time {
+	now := time.Now()
+	wake := nextCheck
+	if len(d.pending) > 0 {
+		if d.inWindow(now) {
+			return now // Left over from a check that ran outside the window
+		}
+		if start := nextChange(d.windows, now); !start.IsZero() && start.Before(wake) {
+			wake = start
+		}
+	}
+	if end := d.notifier.QuietUntil(now); !end.IsZero() && end.Before(wake) {
+		wake = end
+	}
+	return wake
+}
+
+// inWindow reports whether updates may be applied at t
+func (d *Daemon) inWindow(t time.Time) bool {
+	return len(d.windows) == 0 || inAny(d.windows, t)
+}
+
+// check runs an update check of every project and queues the updates
+// policies allow for the next maintenance window
+func (d *Daemon) check(ctx context.Context) {
+	log.Printf("checking %d projects for updates", len(d.projects))
+	failed := 0
+	for ev := range d.checker.Run(ctx, d.projects) {
+		if ev.Done {
+			ev.Project.ApplyCheck(ev.Result)
+		}
+		if ev.Done && ev.Err != nil {
+			failed++
+			log.Printf("%s: check failed: %v", ev.Project.Name, ev.Err)
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err := d.cache.Save(d.projects); err != nil {
+		log.Printf("failed to save cache: %v", err)
+	}
+
+	var queued, manual []string
+	for _, p := range d.projects {
+		auto, notify := updatesByPolicy(p)
+		if len(auto) > 0 {
+			d.pending[p.ID()] = true
+			queued = append(queued, fmt.Sprintf("%s (%s)", p.Name, strings.Join(auto, ", ")))
+		}
+
+		var fresh []string
+		for _, info := range notify {
+			key := p.ID() + "\x00" + info.Name + "\x00" + info.LatestVersion
+			if !d.notified[key] {
+				d.notified[key] = true
+				fresh = append(fresh, info.Name)
+			}
+		}
+		if len(fresh) > 0 {
+			manual = append(manual, fmt.Sprintf("%s (%s)", p.Name, strings.Join(fresh, ", ")))
+		}
+	}
+
+	log.Printf("check done: %d updates to apply, %d failed", len(queued), failed)
+	if len(queued) > 0 && !d.inWindow(time.Now()) {
+		log.Printf("updates wait for the next maintenance window at %s", nextChange(d.windows, time.Now()).Format(time.RFC1123))
+	}
+	if len(manual) > 0 {
+		d.notifier.Notify(ctx, "Updates to apply by hand: "+strings.Join(manual, "; "))
+	}
+}
+
+// updatesByPolicy returns the images of p with an update the daemon may
+// apply (auto, patch-only) and those it only reports (notify-only)
+func updatesByPolicy(p *docker.Project) (auto []string, notify []docker.ImageInfo) {
+	for _, info := range p.ImageInfo {
+		if !info.HasUpdate {
+			continue
+		}
+		switch info.Policy {
+		case docker.PolicyNotify:
+			notify = append(notify, info)
+		case docker.PolicyPinned:
+		default:
+			auto = append(auto, info.Name)
+		}
+	}
+	sort.Strings(auto)
+	sort.Slice(notify, func(i, j int) bool { return notify[i].Name < notify[j].Name })
+	return auto, notify
+}
+
+// apply updates the pending projects ordered by priority, one at a time.
+// No update is started once the maintenance window has closed; the rest
+// wait for the next one.
+func (d *Daemon) apply(ctx context.Context) {
+	var queue []*docker.Project
+	priorities := make(map[string]int)
+	for _, p := range d.projects {
+		if d.pending[p.ID()] {
+			queue = append(queue, p)
+			priorities[p.ID()] = p.UpdatePriority(ctx)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return priorities[queue[i].ID()] < priorities[queue[j].ID()]
+	})
+
+	// Failed updates are retried after the next check
+	d.pending = make(map[string]bool)
+
+	var updated, failed, planned []string
+	for i, p := range queue {
+		if ctx.Err() != nil {
+			return
+		}
+		if !d.inWindow(time.Now()) {
+			for _, rest := range queue[i:] {
+				d.pending[rest.ID()] = true
+			}
+			log.Printf("maintenance window closed, %d updates wait for the next one", len(d.pending))
+			break
+		}
+
+		if d.DryRun {
+			d.plan(ctx, p)
+			planned = append(planned, p.Name)
+			continue
+		}
+
+		log.Printf("%s: updating", p.Name)
+		if err := p.AutoUpdate(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("%s: update failed: %v", p.Name, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", p.Name, err))
+			continue
+		}
+		// Notify-only updates are still there
+		p.HasUpdates = false
+		for name, info := range p.ImageInfo {
+			if info.Policy != docker.PolicyNotify {
+				info.HasUpdate = false
+				p.ImageInfo[name] = info
+			}
+			p.HasUpdates = p.HasUpdates || info.HasUpdate
+		}
+		if p.UpdateWarning != "" {
+			log.Printf("%s: updated, but %s", p.Name, p.UpdateWarning)
+			updated = append(updated, fmt.Sprintf("%s (warning: %s)", p.Name, p.UpdateWarning))
+			continue
+		}
+		updated = append(updated, p.Name)
+	}
+
+	if err := d.cache.Save(d.projects); err != nil {
+		log.Printf("failed to save cache: %v", err)
+	}
+	if len(updated) > 0 {
+		d.notifier.Notify(ctx, "Updated: "+strings.Join(updated, ", "))
+	}
+	if len(failed) > 0 {
+		d.notifier.Notify(ctx, "Updates failed: "+strings.Join(failed, "; "))
+	}
+	if len(planned) > 0 {
+		d.notifier.Notify(ctx, "Dry run, would update: "+strings.Join(planned, ", "))
+	}
+}
+
+// plan logs the commands an update of p would run
+func (d *Daemon) plan(ctx context.Context, p *docker.Project) {
+	recorder := &docker.Recorder{}
+	err := p.AutoUpdate(docker.WithDryRun(ctx, recorder))
+	log.Printf("%s: dry run, update would run:", p.Name)
+	for _, step := range recorder.Steps() {
+		log.Printf("%s:   %s", p.Name, step)
+	}
+	if err != nil {
+		log.Printf("%s: dry run failed: %v", p.Name, err)
+	}
+}
+
+// applyWatchEvent adds, refreshes or removes projects like the TUI does
+func (d *Daemon) applyWatchEvent(ctx context.Context, ev docker.WatchEvent) {
+	if ev.Kind != docker.ComposeFileWritten {
+		kept := d.projects[:0]
+		for _, p := range d.projects {
+			if ev.Affects(p) {
+				log.Printf("%s: project removed", p.Name)
+				delete(d.pending, p.ID())
+				continue
+			}
+			kept = append(kept, p)
+		}
+		d.projects = kept
+		return
+	}
+
+	project, err := docker.NewProject(ev.ComposeFile)
+	if err != nil {
+		return // Gone again already; the removal event follows
+	}
+	project.UpdateStatus(ctx)
+	for _, p := range d.projects {
+		if p.Host == "" && p.Path == project.Path {
+			// Keep update check results, the next check refreshes them
+			p.ComposeFile = project.ComposeFile
+			p.ComposeModTime = project.ComposeModTime
+			p.Status = project.Status
+			p.RunningContainers = project.RunningContainers
+			return
+		}
+	}
+	log.Printf("%s: new project", project.Name)
+	project.SetOptions(d.options)
+	d.projects = append(d.projects, project)
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skpharma/docker-compose-manager/internal/config"
+	"github.com/skpharma/docker-compose-manager/internal/docker"
+)
+
+func TestUpdatesByPolicy(t *testing.T) {
+	p := &docker.Project{ImageInfo: map[string]docker.ImageInfo{
+		"redis:7":     {Name: "redis:7", HasUpdate: true, Policy: docker.PolicyAuto},
+		"nginx:1.25":  {Name: "nginx:1.25", HasUpdate: true, Policy: docker.PolicyPatch},
+		"app:latest":  {Name: "app:latest", HasUpdate: true},
+		"postgres:15": {Name: "postgres:15", HasUpdate: true, Policy: docker.PolicyNotify},
+		"mysql:8":     {Name: "mysql:8", HasUpdate: true, Policy: docker.PolicyNotify},
+		"busybox:1":   {Name: "busybox:1", HasUpdate: true, Policy: docker.PolicyPinned},
+		"alpine:3":    {Name: "alpine:3", Policy: docker.PolicyNotify},
+	}}
+
+	auto, notify := updatesByPolicy(p)
+	if want := []string{"app:latest", "nginx:1.25", "redis:7"}; !reflect.DeepEqual(auto, want) {
+		t.Errorf("auto %q, want %q", auto, want)
+	}
+	var names []string
+	for _, info := range notify {
+		names = append(names, info.Name)
+	}
+	if want := []string{"mysql:8", "postgres:15"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("notify %q, want %q", names, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	d, err := New(config.Daemon{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.windows) != 0 || !d.inWindow(time.Now()) {
+		t.Errorf("without maintenance windows updates must always be allowed")
+	}
+	// DefaultSchedule: every night at 3
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	if next := d.schedule.Next(at); !next.Equal(time.Date(2026, 10, 18, 3, 0, 0, 0, time.Local)) {
+		t.Errorf("default schedule runs at %v", next)
+	}
+
+	for _, cfg := range []config.Daemon{
+		{Schedule: "0 3 * *"},
+		{MaintenanceWindows: []string{"02:00"}},
+		{QuietHours: []string{"Someday 22:00-07:00"}},
+	} {
+		if _, err := New(cfg, nil, nil, nil); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
+
+func TestWakeUp(t *testing.T) {
+	now := time.Now()
+	nextCheck := now.Add(24 * time.Hour)
+	// window returns a daily window from now+from to now+to
+	window := func(from, to time.Duration) Window {
+		w, err := ParseWindow(now.Add(from).Format("15:04") + "-" + now.Add(to).Format("15:04"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	d := &Daemon{windows: []Window{window(2*time.Hour, 3*time.Hour)}, notifier: &Notifier{}, pending: map[string]bool{}}
+	if wake := d.wakeUp(nextCheck); !wake.Equal(nextCheck) {
+		t.Errorf("nothing pending: wake up at %v, want the next check", wake)
+	}
+
+	d.pending["app"] = true
+	if wake, want := d.wakeUp(nextCheck), now.Add(2*time.Hour).Truncate(time.Minute); !wake.Equal(want) {
+		t.Errorf("pending: wake up at %v, want the window start %v", wake, want)
+	}
+
+	d.windows = []Window{window(-time.Hour, time.Hour)}
+	if wake := d.wakeUp(nextCheck); wake.Sub(now) > time.Second {
+		t.Errorf("pending inside the window: wake up at %v, want now", wake)
+	}
+
+	// Held notifications are sent when the quiet hours end
+	d.pending = map[string]bool{}
+	d.notifier = &Notifier{Quiet: []Window{window(-time.Hour, time.Hour)}, held: []string{"update"}}
+	if wake, want := d.wakeUp(nextCheck), now.Add(time.Hour).Truncate(time.Minute); !wake.Equal(want) {
+		t.Errorf("held notifications: wake up at %v, want %v", wake, want)
+	}
+}
+
+func TestNotifierQuietHours(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	always, err := ParseWindow("00:00-24:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Notifier{URL: server.URL, Quiet: []Window{always}}
+	n.Notify(context.Background(), "web updated")
+	n.Notify(context.Background(), "db updated")
+	n.Flush(context.Background())
+	mu.Lock()
+	if len(received) != 0 {
+		t.Fatalf("sent during quiet hours: %q", received)
+	}
+	mu.Unlock()
+
+	n.Quiet = nil
+	n.Flush(context.Background())
+	n.Notify(context.Background(), "cache updated")
+	want := []string{"2 notifications during quiet hours:\nweb updated\ndb updated", "cache updated"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+}
+
+func TestNotifierCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := &Notifier{Command: `cat > "` + out + `"; echo "|$DCM_MESSAGE" >> "` + out + `"`}
+	n.Notify(context.Background(), "web updated")
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "web updated|web updated" {
+		t.Errorf("command got %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds a single notification command or request
+const notifyTimeout = 30 * time.Second
+
+// Notifier sends daemon notifications through a command and/or a webhook.
+// During quiet hours messages are held back and sent as one digest when
+// they end.
+type Notifier struct {
+	Command string   // Run with sh -c, message on stdin and in $DCM_MESSAGE
+	URL     string   // Message is POSTed as text/plain
+	Quiet   []Window // Quiet hours
+
+	held []string
+}
+
+// Notify sends msg, or holds it back during quiet hours. Without a command
+// or URL messages are only logged.
+func (n *Notifier) Notify(ctx context.Context, msg string) {
+	log.Print(msg)
+	if n.Command == "" && n.URL == "" {
+		return
+	}
+	if inAny(n.Quiet, time.Now()) {
+		n.held = append(n.held, msg)
+		return
+	}
+	n.send(ctx, msg)
+}
+
+// Flush sends the messages held back during quiet hours, once they are over
+func (n *Notifier) Flush(ctx context.Context) {
+	if len(n.held) == 0 || inAny(n.Quiet, time.Now()) {
+		return
+	}
+	msg := fmt.Sprintf("%d notifications during quiet hours:\n%s", len(n.held), strings.Join(n.held, "\n"))
+	n.held = nil
+	n.send(ctx, msg)
+}
+
+// QuietUntil returns when the quiet hours end if messages are held back,
+// or the zero time
+func (n *Notifier) QuietUntil(now time.Time) time.Time {
+	if len(n.held) == 0 {
+		return time.Time{}
+	}
+	return nextChange(n.Quiet, now)
+}
+
+// send delivers msg to every configured target, logging failures
+func (n *Notifier) send(ctx context.Context, msg string) {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	if n.Command != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+		cmd.Stdin = strings.NewReader(msg)
+		cmd.Env = append(os.Environ(), "DCM_MESSAGE="+msg)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("notify command failed: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	if n.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(msg))
+		if err != nil {
+			log.Printf("notify url: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("notify url: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("notify url: %s", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring time range on some days of the week, e.g.
+// "Sat,Sun 02:00-06:00". A window ending before it starts runs past
+// midnight and belongs to the day it starts on.
+type Window struct {
+	days       uint8 // Bit set of time.Weekday values
+	start, end int   // Minutes since midnight
+}
+
+// allDays is the day set of windows without days
+const allDays = 1<<7 - 1
+
+// ParseWindow parses "[DAYS ]HH:MM-HH:MM". DAYS is "daily" or a
+// comma-separated list of days and day ranges ("Mon-Fri", "Sat,Sun",
+// "Fri-Mon"); without it the window applies every day.
+func ParseWindow(s string) (Window, error) {
+	fields := strings.Fields(s)
+	w := Window{days: allDays}
+
+	var times string
+	switch len(fields) {
+	case 1:
+		times = fields[0]
+	case 2:
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return Window{}, fmt.Errorf("window %q: %w", s, err)
+		}
+		w.days, times = days, fields[1]
+	default:
+		return Window{}, fmt.Errorf("window %q: expected \"[DAYS ]HH:MM-HH:MM\"", s)
+	}
+
+	from, to, ok := strings.Cut(times, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("window %q: expected HH:MM-HH:MM", s)
+	}
+	var err error
+	if w.start, err = parseClock(from, false); err != nil {
+		return Window{}, fmt.Errorf("window %q: %w", s, err)
+	}
+	if w.end, err = parseClock(to, true); err != nil {
+		return Window{}, fmt.Errorf("window %q: %w", s, err)
+	}
+	if w.start == w.end {
+		return Window{}, fmt.Errorf("window %q: start and end are the same", s)
+	}
+	return w, nil
+}
+
+// ParseWindows parses a list of windows
+func ParseWindows(specs []string) ([]Window, error) {
+	windows := make([]Window, 0, len(specs))
+	for _, spec := range specs {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseDays parses a day list like "Mon-Fri,Sun"
+func parseDays(s string) (uint8, error) {
+	if strings.EqualFold(s, "daily") {
+		return allDays, nil
+	}
+
+	var days uint8
+	for _, item := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return 0, err
+		}
+		last := first
+		if isRange {
+			if last, err = parseDay(to); err != nil {
+				return 0, err
+			}
+		}
+		// Ranges may wrap around the week (Fri-Mon)
+		for d := first; ; d = (d + 1) % 7 {
+			days |= 1 << d
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseDay parses a day name like "Mon" or "monday"
+func parseDay(s string) (int, error) {
+	if len(s) >= 3 {
+		for i, name := range dayNames {
+			if strings.EqualFold(s[:3], name) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q", s)
+}
+
+// parseClock parses "HH:MM" into minutes since midnight. "24:00" is the
+// midnight ending the day if end is set, else the one starting it.
+func parseClock(s string, end bool) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" && end {
+			return 24 * 60, nil
+		}
+		if s == "24:00" {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t is inside the window
+func (w Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := w.days&(1<<t.Weekday()) != 0
+	if w.start < w.end {
+		return today && minute >= w.start && minute < w.end
+	}
+
+	// Past midnight: the evening part belongs to today, the morning part
+	// to the window that started yesterday
+	yesterday := w.days&(1<<((t.Weekday()+6)%7)) != 0
+	return (today && minute >= w.start) || (yesterday && minute < w.end)
+}
+
+// inAny reports whether t is inside one of windows
+func inAny(windows []Window, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextChange returns the first minute after t at which being inside one of
+// windows differs from now, or the zero time if it doesn't within a week
+func nextChange(windows []Window, t time.Time) time.Time {
+	inside := inAny(windows, t)
+	next := t.Truncate(time.Minute)
+	for i := 0; i < 8*24*60; i++ {
+		next = next.Add(time.Minute)
+		if inAny(windows, next) != inside {
+			return next
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Window
+		wantErr bool
+	}{
+		{"02:00-06:00", Window{days: allDays, start: 120, end: 360}, false},
+		{"daily 23:00-01:30", Window{days: allDays, start: 1380, end: 90}, false},
+		{"Sat,Sun 02:00-06:00", Window{days: 1<<time.Saturday | 1<<time.Sunday, start: 120, end: 360}, false},
+		{"Mon-Fri 03:00-04:00", Window{days: 0b0111110, start: 180, end: 240}, false},
+		{"Fri-Mon 22:00-02:00", Window{days: 0b1100011, start: 1320, end: 120}, false},
+		{"monday 00:00-24:00", Window{days: 1 << time.Monday, start: 0, end: 1440}, false},
+		{"00:00-24:00", Window{days: allDays, start: 0, end: 1440}, false},
+		{"24:00-06:00", Window{days: allDays, start: 0, end: 360}, false},
+		{"22:00-24:00", Window{days: allDays, start: 1320, end: 1440}, false},
+		{"02:00-02:00", Window{}, true},
+		{"00:00-00:00", Window{}, true},
+		{"02:00", Window{}, true},
+		{"25:00-26:00", Window{}, true},
+		{"Mon 02:00-06:00 extra", Window{}, true},
+		{"Someday 02:00-06:00", Window{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWindow(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWindow(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseWindow(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	// 2026-10-17 is a Saturday
+	tests := []struct {
+		spec string
+		at   string
+		want bool
+	}{
+		{"02:00-06:00", "2026-10-17 02:00", true},
+		{"02:00-06:00", "2026-10-17 05:59", true},
+		{"02:00-06:00", "2026-10-17 06:00", false},
+		{"02:00-06:00", "2026-10-17 01:59", false},
+		{"Sat,Sun 02:00-06:00", "2026-10-18 03:00", true},
+		{"Sat,Sun 02:00-06:00", "2026-10-19 03:00", false},
+		{"Sat 00:00-24:00", "2026-10-17 00:00", true},
+		{"Sat 00:00-24:00", "2026-10-17 23:59", true},
+		{"Sat 00:00-24:00", "2026-10-18 00:00", false},
+		{"Sat 00:00-24:00", "2026-10-16 23:59", false},
+		// Past midnight, the morning belongs to the day before
+		{"Fri 23:00-01:00", "2026-10-16 23:30", true},
+		{"Fri 23:00-01:00", "2026-10-17 00:30", true},
+		{"Fri 23:00-01:00", "2026-10-17 01:00", false},
+		{"Fri 23:00-01:00", "2026-10-17 23:30", false},
+		{"Fri 23:00-01:00", "2026-10-16 00:30", false},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at, err := time.Parse("2006-01-02 15:04", tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Contains(at); got != tt.want {
+			t.Errorf("%q.Contains(%s) = %v, want %v", tt.spec, tt.at, got, tt.want)
+		}
+	}
+}
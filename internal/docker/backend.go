@@ -0,0 +1,321 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Backend is the compose implementation projects are managed with. It is
+// selected by config or detected once per host at startup (see DetectBackend).
+type Backend interface {
+	// Name is the name used in the config, e.g. "docker" or "podman"
+	Name() string
+	// Engine is the CLI for container and image commands
+	Engine() string
+
+	// composeCommand returns the command running `compose args...`
+	composeCommand(args []string) (string, []string)
+	// listArgs returns the engine arguments listing all compose containers
+	listArgs() []string
+	// parseContainers parses the output of the listArgs command
+	parseContainers(output []byte) ([]psContainer, error)
+	// eventsArgs returns the engine arguments streaming container events,
+	// nil if the engine has no usable event stream
+	eventsArgs() []string
+	// parseEvent parses one line of the eventsArgs command
+	parseEvent(line []byte) (ContainerEvent, bool)
+}
+
+// Backend names accepted in the config ("auto" or empty detects)
+const (
+	BackendAuto          = "auto"
+	BackendDocker        = "docker"         // docker compose (v2 plugin)
+	BackendDockerCompose = "docker-compose" // docker-compose (v1 standalone)
+	BackendPodman        = "podman"         // podman compose
+	BackendNerdctl       = "nerdctl"        // nerdctl compose (containerd)
+)
+
+// backends in the order they are tried by autodetection
+var backends = []Backend{
+	composeV2{},
+	composeV1{},
+	podmanCompose{},
+	nerdctlCompose{},
+}
+
+// localBackend is the backend of the local daemon (the nil *Host)
+var localBackend Backend = composeV2{}
+
+// BackendByName returns the backend with the given config name
+func BackendByName(name string) (Backend, error) {
+	for _, b := range backends {
+		if b.Name() == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown backend %q", name)
+}
+
+// DetectBackend returns the backend for h: the one named by name, or with
+// name "auto" (or empty) the first whose `compose version` works on h.
+func DetectBackend(ctx context.Context, h *Host, name string) (Backend, error) {
+	if name != "" && name != BackendAuto {
+		return BackendByName(name)
+	}
+
+	for _, b := range backends {
+		cmdName, args := b.composeCommand([]string{"version"})
+		if err := h.command(ctx, "", cmdName, args...).Run(); err == nil {
+			return b, nil
+		}
+		if err := cancelled(ctx, "backend detection"); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%s: no compose implementation found (tried docker compose, docker-compose, podman compose, nerdctl compose)", h.HostName())
+}
+
+// Backend returns the backend of h
+func (h *Host) Backend() Backend {
+	if h == nil || h.backend == nil {
+		return localBackend
+	}
+	return h.backend
+}
+
+// SetBackend sets the backend of h
+func (h *Host) SetBackend(b Backend) {
+	h.backend = b
+}
+
+// SetLocalBackend sets the backend of the local daemon. Like SetBackend it
+// must be called before any command runs.
+func SetLocalBackend(b Backend) {
+	localBackend = b
+}
+
+// engine creates a container engine command (docker, podman, ...) on h
+func (h *Host) engine(ctx context.Context, args ...string) *exec.Cmd {
+	return h.command(ctx, "", h.Backend().Engine(), args...)
+}
+
+// compose creates a compose command run in the project directory
+func (p *Project) compose(ctx context.Context, args ...string) *exec.Cmd {
+	name, args := p.host.Backend().composeCommand(args)
+	return p.command(ctx, name, args...)
+}
+
+// labelSet holds container labels. docker and nerdctl list them as one
+// "key=value,key=value" string, podman as an object.
+type labelSet map[string]string
+
+// UnmarshalJSON accepts both forms
+func (l *labelSet) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err == nil {
+		*l = m
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*l = make(labelSet)
+	last := ""
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			// Values may contain commas too (e.g. several config files)
+			if last != "" {
+				(*l)[last] += "," + kv
+			}
+			continue
+		}
+		(*l)[k] = v
+		last = k
+	}
+	return nil
+}
+
+// dockerEngine lists containers and events with the docker CLI
+type dockerEngine struct{}
+
+func (dockerEngine) Engine() string { return "docker" }
+
+func (dockerEngine) listArgs() []string {
+	return []string{"ps", "--all", "--no-trunc", "--filter", "label=" + labelProject, "--format", "{{json .}}"}
+}
+
+func (dockerEngine) parseContainers(output []byte) ([]psContainer, error) {
+	return parseContainerLines(output)
+}
+
+func (dockerEngine) eventsArgs() []string {
+	return []string{"events", "--filter", "type=container", "--filter", "label=" + labelProject, "--format", "{{json .}}"}
+}
+
+func (dockerEngine) parseEvent(line []byte) (ContainerEvent, bool) {
+	var raw struct {
+		Action string `json:"Action"`
+		Actor  struct {
+			Attributes map[string]string `json:"Attributes"`
+		} `json:"Actor"`
+		TimeNano int64 `json:"timeNano"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return ContainerEvent{}, false
+	}
+
+	// Health changes come as "health_status: healthy"
+	action, health := raw.Action, ""
+	if a, h, ok := strings.Cut(raw.Action, ":"); ok && a == "health_status" {
+		action, health = a, strings.TrimSpace(h)
+	}
+	return containerEvent(raw.Actor.Attributes, action, health, time.Unix(0, raw.TimeNano))
+}
+
+// composeV2 is the docker compose CLI plugin
+type composeV2 struct{ dockerEngine }
+
+func (composeV2) Name() string { return BackendDocker }
+
+func (composeV2) composeCommand(args []string) (string, []string) {
+	return "docker", append([]string{"compose"}, args...)
+}
+
+// composeV1 is the standalone docker-compose (v1). It has no JSON output
+// for `config` and `ps`, so drift detection and update priorities aren't
+// available.
+type composeV1 struct{ dockerEngine }
+
+func (composeV1) Name() string { return BackendDockerCompose }
+
+func (composeV1) composeCommand(args []string) (string, []string) {
+	return "docker-compose", args
+}
+
+// podmanCompose is `podman compose`, which runs podman-compose or
+// docker-compose against the podman socket
+type podmanCompose struct{}
+
+func (podmanCompose) Name() string   { return BackendPodman }
+func (podmanCompose) Engine() string { return "podman" }
+
+func (podmanCompose) composeCommand(args []string) (string, []string) {
+	return "podman", append([]string{"compose"}, args...)
+}
+
+func (podmanCompose) listArgs() []string {
+	return []string{"ps", "--all", "--no-trunc", "--filter", "label=" + labelProject, "--format", "json"}
+}
+
+// parseContainers parses the JSON array podman prints, which differs from
+// docker's: Names is a list, Created a unix timestamp, Labels an object
+func (podmanCompose) parseContainers(output []byte) ([]psContainer, error) {
+	var raw []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		State   string   `json:"State"`
+		Status  string   `json:"Status"`
+		Created int64    `json:"Created"`
+		Labels  labelSet `json:"Labels"`
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse containers: %w", err)
+	}
+
+	containers := make([]psContainer, 0, len(raw))
+	for _, r := range raw {
+		containers = append(containers, psContainer{
+			ID:      r.ID,
+			Names:   strings.Join(r.Names, ","),
+			State:   r.State,
+			Status:  r.Status,
+			Created: time.Unix(r.Created, 0),
+			Labels:  r.Labels,
+		})
+	}
+	return containers, nil
+}
+
+func (podmanCompose) eventsArgs() []string {
+	return []string{"events", "--filter", "type=container", "--filter", "label=" + labelProject, "--format", "json"}
+}
+
+func (podmanCompose) parseEvent(line []byte) (ContainerEvent, bool) {
+	var raw struct {
+		Status     string            `json:"Status"`
+		Attributes map[string]string `json:"Attributes"`
+		Health     string            `json:"health_status"`
+		TimeNano   int64             `json:"timeNano"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return ContainerEvent{}, false
+	}
+	return containerEvent(raw.Attributes, raw.Status, raw.Health, time.Unix(0, raw.TimeNano))
+}
+
+// nerdctlCompose is `nerdctl compose` on containerd. Its event stream
+// carries no compose labels, status is refreshed periodically only.
+type nerdctlCompose struct{}
+
+func (nerdctlCompose) Name() string   { return BackendNerdctl }
+func (nerdctlCompose) Engine() string { return "nerdctl" }
+
+func (nerdctlCompose) composeCommand(args []string) (string, []string) {
+	return "nerdctl", append([]string{"compose"}, args...)
+}
+
+func (nerdctlCompose) listArgs() []string {
+	return []string{"ps", "--all", "--no-trunc", "--filter", "label=" + labelProject, "--format", "{{json .}}"}
+}
+
+func (nerdctlCompose) parseContainers(output []byte) ([]psContainer, error) {
+	return parseContainerLines(output)
+}
+
+func (nerdctlCompose) eventsArgs() []string { return nil }
+
+func (nerdctlCompose) parseEvent([]byte) (ContainerEvent, bool) {
+	return ContainerEvent{}, false
+}
+
+// parseContainerLines parses `ps --format '{{json .}}'` output of docker
+// and nerdctl, one container per line. nerdctl has no State column, it is
+// derived from the status ("Up 2 hours", "Exited (0) ...").
+func parseContainerLines(output []byte) ([]psContainer, error) {
+	var containers []psContainer
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var raw struct {
+			ID        string   `json:"ID"`
+			Names     string   `json:"Names"`
+			State     string   `json:"State"`
+			Status    string   `json:"Status"`
+			CreatedAt string   `json:"CreatedAt"`
+			Labels    labelSet `json:"Labels"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse containers: %w", err)
+		}
+
+		c := psContainer{ID: raw.ID, Names: raw.Names, State: raw.State, Status: raw.Status, Labels: raw.Labels}
+		if c.State == "" && strings.HasPrefix(c.Status, "Up") {
+			c.State = "running"
+		}
+		c.Created, _ = time.Parse(psTimeLayout, raw.CreatedAt)
+		containers = append(containers, c)
+	}
+	return containers, scanner.Err()
+}
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLabelSetUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  labelSet
+	}{
+		{`"a=1,b=2"`, labelSet{"a": "1", "b": "2"}},
+		{`"files=/srv/a.yml,/srv/b.yml,c=x=y"`, labelSet{"files": "/srv/a.yml,/srv/b.yml", "c": "x=y"}},
+		{`""`, labelSet{}},
+		{`{"a":"1","b":"2,3"}`, labelSet{"a": "1", "b": "2,3"}},
+	}
+	for _, tt := range tests {
+		var got labelSet
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	var l labelSet
+	if err := json.Unmarshal([]byte(`["a=1"]`), &l); err == nil {
+		t.Errorf("list accepted")
+	}
+}
+
+func TestBackendByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		engine  string
+	}{
+		{BackendDocker, []string{"docker", "compose", "up"}, "docker"},
+		{BackendDockerCompose, []string{"docker-compose", "up"}, "docker"},
+		{BackendPodman, []string{"podman", "compose", "up"}, "podman"},
+		{BackendNerdctl, []string{"nerdctl", "compose", "up"}, "nerdctl"},
+	}
+	for _, tt := range tests {
+		b, err := BackendByName(tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		name, args := b.composeCommand([]string{"up"})
+		if got := append([]string{name}, args...); b.Name() != tt.name || !reflect.DeepEqual(got, tt.command) || b.Engine() != tt.engine {
+			t.Errorf("%s: name %s, command %q, engine %s", tt.name, b.Name(), got, b.Engine())
+		}
+	}
+	if _, err := BackendByName("lxc"); err == nil {
+		t.Errorf("unknown backend accepted")
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	// Only podman is installed, docker fails
+	dir := t.TempDir()
+	for name, status := range map[string]string{"docker": "1", "podman": "0"} {
+		script := "#!/bin/sh\nexit " + status + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	tests := map[string]string{
+		"":            BackendPodman,
+		BackendAuto:   BackendPodman,
+		BackendDocker: BackendDocker, // Configured, not tried
+	}
+	for name, want := range tests {
+		b, err := DetectBackend(context.Background(), nil, name)
+		if err != nil || b.Name() != want {
+			t.Errorf("DetectBackend(%q) = %v, %v; want %s", name, b, err, want)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "podman"))
+	if _, err := DetectBackend(context.Background(), nil, BackendAuto); err == nil {
+		t.Errorf("detected a backend without any installed")
+	}
+}
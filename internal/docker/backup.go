@@ -0,0 +1,337 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBackupDir is where archives are written on the project's host
+	DefaultBackupDir = "/var/backups/docker-compose-manager"
+	// DefaultBackupKeep is how many backups are kept per project
+	DefaultBackupKeep = 5
+	// DefaultBackupImage is the helper image, it needs sh, tar and find
+	DefaultBackupImage = "alpine:3"
+
+	backupIDFormat = "20060102-150405.000" // Milliseconds, so backups made right after each other get their own
+	restoreDir     = ".dcm-restore"        // Extraction directory in the restored volume
+	manifestFile   = "manifest.json"
+	notADirectory  = 3 // Exit code of the helper for bind mounts of files
+)
+
+// ErrNothingToBackUp is returned by Backup for projects without volumes
+// or bind mounts
+var ErrNothingToBackUp = errors.New("no volumes or bind mounts to back up")
+
+// BackupOptions configures volume backups. Archives are written by a
+// helper container on the project's daemon, so Dir is a path on the
+// machine the daemon runs on.
+type BackupOptions struct {
+	Dir          string // Backups go to Dir/<project>/<timestamp>/
+	Keep         int    // Backups kept per project, older ones are deleted
+	Image        string // Helper image
+	BeforeUpdate bool   // Back up before updates: default of the TUI toggle, the daemon only if set
+	Stop         bool   // Stop the project while backing up, for consistent database files
+}
+
+// BackupDir returns the directory backups are written to on the project's host
+func (p *Project) BackupDir() string {
+	return p.options().Backup.Dir
+}
+
+// BackupMount is a volume or bind mount saved in a backup
+type BackupMount struct {
+	Type    string `json:"type"`    // "volume" or "bind"
+	Source  string `json:"source"`  // Volume name or host path
+	Archive string `json:"archive"` // File name of the tar.gz in the backup directory
+}
+
+// Backup is a snapshot of a project's volumes and bind mounts
+type Backup struct {
+	ID      string        `json:"id"` // Timestamp, also the directory name
+	Project string        `json:"project"`
+	Created time.Time     `json:"created"`
+	Mounts  []BackupMount `json:"mounts"`
+	Size    int64         `json:"-"` // Bytes on disk, set by Backups
+}
+
+// Backup archives the named volumes and bind mounts of the project into a
+// new timestamped backup and deletes the oldest ones beyond Keep
+func (p *Project) Backup(ctx context.Context) (*Backup, error) {
+	ctx = p.withComposeConfig(ctx)
+	mounts, err := p.backupMounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(mounts) == 0 {
+		return nil, ErrNothingToBackUp
+	}
+
+	now := time.Now()
+	backup := &Backup{ID: now.Format(backupIDFormat), Project: p.Name, Created: now}
+	dir := path.Join(p.Name, backup.ID)
+
+	if p.options().Backup.Stop && p.IsRunning() {
+		if err := p.up(ctx, "stop", "stop"); err != nil {
+			return nil, err
+		}
+		defer func() {
+			p.up(context.WithoutCancel(ctx), "start", "start")
+			p.UpdateStatus(ctx)
+		}()
+	}
+
+	for _, m := range mounts {
+		// Bind mounts of single files are configuration, not data
+		script := `[ -d /source ] || exit ` + strconv.Itoa(notADirectory) + `
+mkdir -p "/backup/$1" && tar czf "/backup/$1/$2" -C /source .`
+		cmd := p.backupHelper(ctx, []string{m.Source + ":/source:ro"}, script, dir, m.Archive)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == notADirectory {
+				continue
+			}
+			p.removeBackupDirs(context.WithoutCancel(ctx), backup.ID)
+			if err := cancelled(ctx, "backup"); err != nil {
+				return nil, err
+			}
+			return nil, cleanDockerError("backup "+m.Source, output, err)
+		}
+		backup.Mounts = append(backup.Mounts, m)
+	}
+	if len(backup.Mounts) == 0 {
+		return nil, ErrNothingToBackUp
+	}
+
+	// The manifest is written last, so incomplete backups are never listed
+	manifest, err := json.Marshal(backup)
+	if err != nil {
+		return nil, err
+	}
+	cmd := p.backupHelper(ctx, nil, `cat > "/backup/$1/`+manifestFile+`"`, dir)
+	cmd.Stdin = strings.NewReader(string(manifest))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		p.removeBackupDirs(context.WithoutCancel(ctx), backup.ID)
+		if err := cancelled(ctx, "backup"); err != nil {
+			return nil, err
+		}
+		return nil, cleanDockerError("backup", output, err)
+	}
+	return backup, p.pruneBackups(ctx)
+}
+
+// Backups lists the complete backups of the project, newest first
+func (p *Project) Backups(ctx context.Context) ([]Backup, error) {
+	script := `cd "/backup/$1" 2>/dev/null || exit 0
+for d in */; do
+	d=${d%/}
+	[ -f "$d/` + manifestFile + `" ] || continue
+	printf '%s\t%s\t' "$d" "$(du -sk "$d" | cut -f1)"
+	tr -d '\n' < "$d/` + manifestFile + `"
+	echo
+done`
+	// Only reads, so it runs in dry runs too
+	output, err := p.backupHelper(withoutDryRun(ctx), nil, script, p.Name).Output()
+	if err != nil {
+		if err := cancelled(ctx, "list backups"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		var backup Backup
+		if err := json.Unmarshal([]byte(fields[2]), &backup); err != nil || backup.ID != fields[0] {
+			continue // Not written by us
+		}
+		kb, _ := strconv.ParseInt(fields[1], 10, 64)
+		backup.Size = kb * 1024
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID > backups[j].ID })
+	return backups, nil
+}
+
+// Restore replaces the contents of the volumes and bind mounts saved in
+// the backup with its archives. The project is stopped meanwhile and
+// started again if it was running.
+func (p *Project) Restore(ctx context.Context, id string) error {
+	ctx = p.withComposeConfig(ctx)
+	backups, err := p.Backups(ctx)
+	if err != nil {
+		return err
+	}
+	var backup *Backup
+	for i := range backups {
+		if backups[i].ID == id {
+			backup = &backups[i]
+		}
+	}
+	if backup == nil {
+		return fmt.Errorf("backup %s of %s not found", id, p.Name)
+	}
+
+	if err := p.UpdateStatus(ctx); err != nil {
+		return err
+	}
+
+	// Read every archive before touching anything, so a damaged backup
+	// doesn't leave some volumes restored and others not
+	var archives []string
+	for _, m := range backup.Mounts {
+		archives = append(archives, path.Join(p.Name, backup.ID, m.Archive))
+	}
+	script := `for a; do tar tzf "/backup/$a" > /dev/null || { echo "error: $a is damaged" >&2; exit 1; }; done`
+	if output, err := p.backupHelper(ctx, nil, script, archives...).CombinedOutput(); err != nil {
+		if err := cancelled(ctx, "restore"); err != nil {
+			return err
+		}
+		return cleanDockerError("restore", output, err)
+	}
+
+	if p.IsRunning() {
+		if err := p.up(ctx, "stop", "stop"); err != nil {
+			return err
+		}
+		defer func() {
+			p.up(context.WithoutCancel(ctx), "start", "start")
+			p.UpdateStatus(ctx)
+		}()
+	}
+
+	for i, m := range backup.Mounts {
+		// Extracted next to the old contents first, which are only replaced
+		// once the archive is out completely (this needs the space twice)
+		script := `tmp=/target/` + restoreDir + `
+rm -rf "$tmp" && mkdir "$tmp" || exit 1
+tar xzf "/backup/$1" -C "$tmp" || { rm -rf "$tmp"; exit 1; }
+find /target -mindepth 1 -maxdepth 1 ! -name ` + restoreDir + ` -exec rm -rf {} \; &&
+find "$tmp" -mindepth 1 -maxdepth 1 -exec mv {} /target/ \; &&
+rmdir "$tmp"`
+		cmd := p.backupHelper(ctx, []string{m.Source + ":/target"}, script, archives[i])
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if err := cancelled(ctx, "restore"); err != nil {
+				return err
+			}
+			return cleanDockerError("restore "+m.Source, output, err)
+		}
+	}
+	return nil
+}
+
+// backupMounts returns the named volumes and bind mounts of the project's
+// services. Read-only bind mounts (configuration) and system paths are left
+// out.
+func (p *Project) backupMounts(ctx context.Context) ([]BackupMount, error) {
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return nil, err // docker-compose v1 can't render the config
+	}
+
+	var mounts []BackupMount
+	seen := make(map[string]bool)
+	archives := make(map[string]bool)
+	for _, name := range sortedServices(cfg) {
+		for _, v := range cfg.Services[name].Volumes {
+			var m BackupMount
+			switch {
+			case v.Type == "volume" && v.Source != "":
+				m = BackupMount{Type: "volume", Source: v.Source}
+				if vol, ok := cfg.Volumes[v.Source]; ok && vol.Name != "" {
+					m.Source = vol.Name
+				}
+			case v.Type == "bind" && !v.ReadOnly && !systemPath(v.Source):
+				m = BackupMount{Type: "bind", Source: path.Clean(v.Source)}
+			default:
+				continue // Anonymous volumes, tmpfs, configuration
+			}
+			if seen[m.Type+m.Source] {
+				continue
+			}
+			seen[m.Type+m.Source] = true
+
+			base := m.Type + "-" + archiveName(m.Source)
+			m.Archive = base + ".tar.gz"
+			for i := 2; archives[m.Archive]; i++ {
+				m.Archive = fmt.Sprintf("%s-%d.tar.gz", base, i)
+			}
+			archives[m.Archive] = true
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts, nil
+}
+
+// pruneBackups deletes the oldest backups of the project beyond Keep
+func (p *Project) pruneBackups(ctx context.Context) error {
+	backups, err := p.Backups(ctx)
+	if err != nil || len(backups) <= p.options().Backup.Keep {
+		return err
+	}
+	var ids []string
+	for _, b := range backups[p.options().Backup.Keep:] {
+		ids = append(ids, b.ID)
+	}
+	return p.removeBackupDirs(ctx, ids...)
+}
+
+// removeBackupDirs deletes backups of the project by ID
+func (p *Project) removeBackupDirs(ctx context.Context, ids ...string) error {
+	cmd := p.backupHelper(ctx, nil, `cd "/backup/$1" && shift && rm -rf -- "$@"`, append([]string{p.Name}, ids...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return cleanDockerError("delete old backups", output, err)
+	}
+	return nil
+}
+
+// backupHelper creates a command running script with sh in a throwaway
+// container of the helper image, with the backup directory at /backup and
+// the extra volumes. args are the script's $1, $2, ...
+func (p *Project) backupHelper(ctx context.Context, volumes []string, script string, args ...string) *exec.Cmd {
+	runArgs := []string{"run", "--rm", "-i", "--network", "none", "-v", p.options().Backup.Dir + ":/backup"}
+	for _, v := range volumes {
+		runArgs = append(runArgs, "-v", v)
+	}
+	runArgs = append(runArgs, p.options().Backup.Image, "sh", "-c", script, "sh")
+	return p.host.engine(ctx, append(runArgs, args...)...)
+}
+
+// systemPath reports whether a bind mount source is part of the host
+// system (docker socket, /proc, ...) rather than data
+func systemPath(source string) bool {
+	source = path.Clean(source)
+	for _, dir := range []string{"/proc", "/sys", "/dev", "/run", "/var/run", "/var/lib/docker"} {
+		if source == dir || strings.HasPrefix(source, dir+"/") {
+			return true
+		}
+	}
+	return source == "/" || source == "/etc"
+}
+
+// archiveName turns a volume name or path into a file name
+func archiveName(source string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, strings.Trim(source, "/"))
+	if name == "" {
+		name = "root"
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"myproject_data", "myproject_data"},
+		{"/srv/app/data", "srv_app_data"},
+		{"/srv/app/data/", "srv_app_data"},
+		{"/home/user/my files", "home_user_my_files"},
+		{"/opt/app-1.2", "opt_app-1.2"},
+		{"/", "root"},
+		{"/data/ünï", "data__n_"},
+	}
+	for _, tt := range tests {
+		if got := archiveName(tt.source); got != tt.want {
+			t.Errorf("archiveName(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestSystemPath(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"/var/run/docker.sock", true},
+		{"/run/docker.sock", true},
+		{"/proc", true},
+		{"/sys/fs/cgroup", true},
+		{"/dev/dri", true},
+		{"/var/lib/docker/volumes", true},
+		{"/", true},
+		{"/etc", true},
+		{"/etc/", true},
+		{"/etc/localtime", false}, // Single files are skipped later, as not a directory
+		{"/srv/app/data", false},
+		{"/var/lib/dockerdata", false},
+		{"/device", false},
+		{"/srv/../proc/1", true},
+	}
+	for _, tt := range tests {
+		if got := systemPath(tt.source); got != tt.want {
+			t.Errorf("systemPath(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestBackupIDOrder(t *testing.T) {
+	// Backups are listed by ID, newest first: IDs must sort by time, also
+	// against the older IDs without milliseconds
+	base := time.Date(2026, 1, 18, 3, 0, 0, 0, time.UTC)
+	ids := []string{
+		"20260118-025959",
+		base.Format(backupIDFormat),
+		base.Add(time.Millisecond).Format(backupIDFormat),
+		base.Add(time.Second).Format(backupIDFormat),
+		"20260118-030002",
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Errorf("%q sorts after %q", ids[i-1], ids[i])
+		}
+	}
+}
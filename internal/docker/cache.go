@@ -0,0 +1,332 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The cache is shared between the TUI and --update-cache runs from cron.
+// Every read-modify-write happens under an advisory lock on a separate
+// lock file (the cache file itself is replaced by rename on every write),
+// and writes go to a temp file that is renamed over the cache so readers
+// never see a truncated file.
+
+// CacheSchemaVersion is the version of the cache file format written by this build.
+// Bump it whenever Project or ImageInfo change incompatibly and add a
+// migration from the previous version to cacheMigrations.
+//
+//	1: bare JSON array of projects (no envelope)
+//	2: envelope with metadata
+const CacheSchemaVersion = 2
+
+// CacheFile is the on-disk envelope of the cache
+type CacheFile struct {
+	SchemaVersion int        `json:"schema_version"`
+	WrittenAt     time.Time  `json:"written_at"`
+	Host          string     `json:"host"`
+	ToolVersion   string     `json:"tool_version"`
+	SearchRoots   []string   `json:"search_roots"`
+	Projects      []*Project `json:"projects"`
+
+	// Directories holds the mtimes of all searched directories at discovery
+	// time (see Discovery). Discovery stays valid until one of them changes,
+	// independent of how old the update check results are.
+	Directories map[string]time.Time `json:"directories,omitempty"`
+	// Exclude patterns the discovery was made with
+	Exclude []string `json:"exclude,omitempty"`
+	// Diagnostics of the discovery, shown until the next rescan
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Adopted lists project directories outside the discovery that were
+	// adopted from the unmanaged stacks view (see Discovery.Adopt)
+	Adopted []string `json:"adopted,omitempty"`
+}
+
+// cacheMigrations upgrade the raw cache JSON from version n (map key) to n+1
+var cacheMigrations = map[int]func(data []byte, modTime time.Time) ([]byte, error){
+	1: migrateCacheV1,
+}
+
+// Cache reads and writes the project cache for one set of search roots
+type Cache struct {
+	Path        string   // Cache file location
+	SearchRoots []string // Directories the cached projects were discovered in
+	ToolVersion string   // Version of the binary writing the cache
+
+	// Directories, exclude patterns, diagnostics and adopted projects of
+	// the current discovery, written with every Save
+	Directories map[string]time.Time
+	Exclude     []string
+	Diagnostics []Diagnostic
+	Adopted     []string
+}
+
+// NewCache creates a cache for the given file and search roots
+func NewCache(path string, searchRoots []string, toolVersion string) *Cache {
+	roots := make([]string, 0, len(searchRoots))
+	for _, root := range searchRoots {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		roots = append(roots, filepath.Clean(root))
+	}
+	sort.Strings(roots)
+
+	return &Cache{
+		Path:        path,
+		SearchRoots: roots,
+		ToolVersion: toolVersion,
+	}
+}
+
+// lockPath returns the path of the lock file guarding the cache
+func (c *Cache) lockPath() string {
+	return c.Path + ".lock"
+}
+
+// Save writes projects to the cache file.
+// Update check results already on disk that are newer than ours (e.g. from
+// a cron run while the TUI was open) are kept instead of being overwritten.
+func (c *Cache) Save(projects []*Project) error {
+	unlock, err := lockFile(c.lockPath(), true)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer unlock()
+
+	// Merge with what's on disk if it belongs to the same search roots;
+	// an unreadable or foreign cache is simply overwritten
+	merged := projects
+	directories := c.Directories
+	if onDisk, err := readCacheFile(c.Path); err == nil && c.validate(onDisk) == nil {
+		merged = mergeProjects(projects, onDisk.Projects)
+		if directories == nil {
+			directories = onDisk.Directories
+		}
+	}
+
+	host, _ := os.Hostname()
+	data, err := json.MarshalIndent(CacheFile{
+		SchemaVersion: CacheSchemaVersion,
+		WrittenAt:     time.Now(),
+		Host:          host,
+		ToolVersion:   c.ToolVersion,
+		SearchRoots:   c.SearchRoots,
+		Projects:      merged,
+		Directories:   directories,
+		Exclude:       c.Exclude,
+		Diagnostics:   c.Diagnostics,
+		Adopted:       c.Adopted,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal projects: %w", err)
+	}
+
+	if err := writeFileAtomic(c.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the cache file. The returned error explains why the cache was
+// rejected (missing, corrupt, other host/search roots, ...).
+// Whether the discovered projects are still current is checked separately
+// with CacheFile.DiscoveryChanged.
+func (c *Cache) Load() (*CacheFile, error) {
+	unlock, err := lockFile(c.lockPath(), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer unlock()
+
+	cache, err := readCacheFile(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.validate(cache); err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// validate checks that a cache file was written for this host and these search roots
+func (c *Cache) validate(cache *CacheFile) error {
+	if host, err := os.Hostname(); err == nil && cache.Host != "" && cache.Host != host {
+		return fmt.Errorf("cache was written on host %q, this is %q", cache.Host, host)
+	}
+
+	// Caches migrated from v1 don't know their search roots
+	if len(cache.SearchRoots) > 0 && strings.Join(cache.SearchRoots, "\n") != strings.Join(c.SearchRoots, "\n") {
+		return fmt.Errorf("cache was created for %s, not %s",
+			strings.Join(cache.SearchRoots, ", "), strings.Join(c.SearchRoots, ", "))
+	}
+
+	return nil
+}
+
+// readCacheFile reads the cache file without locking and migrates it to
+// the current schema version
+func readCacheFile(path string) (*CacheFile, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no cache file at %s", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	version, err := cacheSchemaVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if version > CacheSchemaVersion {
+		return nil, fmt.Errorf("cache schema version %d is newer than supported version %d (written by a newer docker-compose-manager)",
+			version, CacheSchemaVersion)
+	}
+
+	for ; version < CacheSchemaVersion; version++ {
+		migrate, ok := cacheMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration for cache schema version %d", version)
+		}
+		if data, err = migrate(data, info.ModTime()); err != nil {
+			return nil, fmt.Errorf("failed to migrate cache from schema version %d: %w", version, err)
+		}
+	}
+
+	var cache CacheFile
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("cache file is corrupt: %w", err)
+	}
+
+	return &cache, nil
+}
+
+// cacheSchemaVersion detects the schema version of raw cache data
+func cacheSchemaVersion(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return 0, fmt.Errorf("cache file is empty")
+	}
+
+	// Version 1 was a bare array of projects
+	if data[0] == '[' {
+		return 1, nil
+	}
+
+	var probe struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return 0, fmt.Errorf("cache file is corrupt: %w", err)
+	}
+	if probe.SchemaVersion < 1 {
+		return 0, fmt.Errorf("cache file has no schema version")
+	}
+
+	return probe.SchemaVersion, nil
+}
+
+// migrateCacheV1 wraps the bare project array of version 1 in an envelope.
+// The file's modification time is the best guess for when it was written.
+func migrateCacheV1(data []byte, modTime time.Time) ([]byte, error) {
+	var projects []*Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(CacheFile{
+		SchemaVersion: 2,
+		WrittenAt:     modTime,
+		Projects:      projects,
+	})
+}
+
+// mergeProjects returns copies of ours where every image keeps whichever
+// update check result is newer, ours or the one from onDisk.
+// The project list itself is taken from ours, results on disk for images
+// the project no longer uses are dropped.
+func mergeProjects(ours, onDisk []*Project) []*Project {
+	diskByPath := make(map[string]*Project, len(onDisk))
+	for _, p := range onDisk {
+		diskByPath[p.ID()] = p
+	}
+
+	merged := make([]*Project, 0, len(ours))
+	for _, p := range ours {
+		cp := *p
+		disk, ok := diskByPath[p.ID()]
+		if !ok {
+			merged = append(merged, &cp)
+			continue
+		}
+
+		imageInfo := make(map[string]ImageInfo, len(p.ImageInfo))
+		for name, info := range p.ImageInfo {
+			imageInfo[name] = info
+		}
+		used := make(map[string]bool, len(p.Images))
+		for _, image := range p.Images {
+			used[image] = true
+		}
+		for name, diskInfo := range disk.ImageInfo {
+			if len(used) > 0 && !used[name] {
+				continue // No longer used by the project
+			}
+			if info, ok := imageInfo[name]; !ok || diskInfo.CheckedAt.After(info.CheckedAt) {
+				imageInfo[name] = diskInfo
+			}
+		}
+
+		cp.ImageInfo = imageInfo
+		cp.HasUpdates = false
+		for _, info := range imageInfo {
+			if info.HasUpdate {
+				cp.HasUpdates = true
+				break
+			}
+		}
+		merged = append(merged, &cp)
+	}
+	return merged
+}
+
+// writeFileAtomic writes data to a temp file next to name and renames it
+// over name, so the file is either completely old or completely new
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, name)
+}
@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSchemaVersion(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{`[{"name":"app"}]`, 1, false},
+		{"  \n[]", 1, false},
+		{`{"schema_version":2,"projects":[]}`, 2, false},
+		{`{"schema_version":7}`, 7, false},
+		{`{"projects":[]}`, 0, true},
+		{"", 0, true},
+		{"{not json", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := cacheSchemaVersion([]byte(tt.data))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cacheSchemaVersion(%q) = %d, %v; want %d, error %v", tt.data, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadCacheFile(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		data      string
+		projects  int
+		writtenAt time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "v1 migrated",
+			data:      `[{"name":"app","path":"/srv/app","image_info":{"nginx":{"has_update":true}}}]`,
+			projects:  1,
+			writtenAt: modTime,
+		},
+		{
+			name:      "v2",
+			data:      `{"schema_version":2,"written_at":"2024-05-01T10:00:00Z","projects":[{"name":"a"},{"name":"b"}]}`,
+			projects:  2,
+			writtenAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "newer version",
+			data:    `{"schema_version":3,"projects":[]}`,
+			wantErr: true,
+		},
+		{
+			name:    "corrupt v1",
+			data:    `[{"name":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+
+			cache, err := readCacheFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readCacheFile accepted %s", tt.data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCacheFile: %v", err)
+			}
+			if cache.SchemaVersion != CacheSchemaVersion {
+				t.Errorf("schema version = %d, want %d", cache.SchemaVersion, CacheSchemaVersion)
+			}
+			if !cache.WrittenAt.Equal(tt.writtenAt) {
+				t.Errorf("written at = %v, want %v", cache.WrittenAt, tt.writtenAt)
+			}
+			if len(cache.Projects) != tt.projects {
+				t.Errorf("%d projects, want %d", len(cache.Projects), tt.projects)
+			}
+		})
+	}
+}
+
+func TestMergeProjects(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	ours := []*Project{
+		{Name: "app", Path: "/srv/app", Images: []string{"nginx", "redis", "postgres"}, ImageInfo: map[string]ImageInfo{
+			"nginx": {HasUpdate: false, CheckedAt: older},
+			"redis": {HasUpdate: false, CheckedAt: newer},
+		}},
+		{Name: "new", Path: "/srv/new"},
+		{Name: "moved", Path: "/srv/moved", Images: []string{"nginx:1.27"}},
+	}
+	onDisk := []*Project{
+		{Name: "app", Path: "/srv/app", ImageInfo: map[string]ImageInfo{
+			"nginx":    {HasUpdate: true, CheckedAt: newer},
+			"redis":    {HasUpdate: true, CheckedAt: older},
+			"postgres": {HasUpdate: false, CheckedAt: older},
+		}},
+		{Name: "gone", Path: "/srv/gone"},
+		{Name: "moved", Path: "/srv/moved", ImageInfo: map[string]ImageInfo{
+			"nginx:1.25": {HasUpdate: true, CheckedAt: newer},
+		}},
+	}
+
+	merged := mergeProjects(ours, onDisk)
+	if len(merged) != 3 || merged[0].Path != "/srv/app" || merged[1].Path != "/srv/new" {
+		t.Fatalf("merged projects = %v, want ours", merged)
+	}
+	app := merged[0]
+	if !app.ImageInfo["nginx"].HasUpdate {
+		t.Errorf("nginx: newer result on disk was not kept")
+	}
+	if app.ImageInfo["redis"].HasUpdate {
+		t.Errorf("redis: older result on disk replaced ours")
+	}
+	if _, ok := app.ImageInfo["postgres"]; !ok {
+		t.Errorf("postgres: result only on disk was dropped")
+	}
+	if !app.HasUpdates {
+		t.Errorf("HasUpdates not recomputed")
+	}
+	if ours[0].ImageInfo["nginx"].HasUpdate {
+		t.Errorf("ours was modified")
+	}
+	if moved := merged[2]; len(moved.ImageInfo) != 0 || moved.HasUpdates {
+		t.Errorf("moved: result for an image no longer used was kept: %v", moved.ImageInfo)
+	}
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(filepath.Join(dir, "cache.json"), []string{dir}, "test")
+	projects := []*Project{{Name: "app", Path: filepath.Join(dir, "app")}}
+	if err := cache.Save(projects); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := cache.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Projects) != 1 || loaded.Projects[0].Name != "app" || loaded.ToolVersion != "test" {
+		t.Errorf("Load = %+v", loaded)
+	}
+
+	other := NewCache(cache.Path, []string{filepath.Join(dir, "other")}, "test")
+	if _, err := other.Load(); err == nil {
+		t.Errorf("Load accepted a cache of other search roots")
+	}
+}
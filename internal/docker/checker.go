@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCheckWorkers is the number of projects checked at the same time
+	DefaultCheckWorkers = 4
+	// DefaultRegistryWorkers is the number of concurrent pulls per registry
+	DefaultRegistryWorkers = 2
+)
+
+// CheckEvent reports progress of an update check run
+type CheckEvent struct {
+	Index   int          // Index of the project in the slice passed to Run
+	Project *Project     // Project the event belongs to
+	Done    bool         // false when the check started, true when it finished
+	Err     error        // Error returned by the check (only set when Done)
+	Result  *CheckResult // To apply with Project.ApplyCheck (only set when Done)
+}
+
+// UpdateChecker checks projects for image updates using a bounded worker pool.
+// Pulls against the same registry are additionally limited so that one slow
+// or rate-limiting registry cannot occupy every worker.
+type UpdateChecker struct {
+	Workers         int           // Number of projects checked in parallel
+	RegistryWorkers int           // Max concurrent pulls per registry (0 = unlimited)
+	MaxAge          time.Duration // Skip images checked more recently than this (0 = check all)
+
+	mu         sync.Mutex
+	registries map[string]chan struct{}
+
+	// check checks a project, Project.checkImages if nil (replaced in tests)
+	check func(ctx context.Context, p *Project, imageInfo map[string]ImageInfo) (*CheckResult, error)
+}
+
+// NewUpdateChecker creates a checker, falling back to defaults for values <= 0
+func NewUpdateChecker(workers, registryWorkers int) *UpdateChecker {
+	if workers <= 0 {
+		workers = DefaultCheckWorkers
+	}
+	if registryWorkers < 0 {
+		registryWorkers = DefaultRegistryWorkers
+	}
+	return &UpdateChecker{
+		Workers:         workers,
+		RegistryWorkers: registryWorkers,
+		registries:      make(map[string]chan struct{}),
+	}
+}
+
+// Run checks all given projects and streams progress events.
+// The returned channel is closed once every project has been checked or,
+// after ctx is cancelled, once the running checks have stopped.
+// The projects are not modified: the caller applies the result of each
+// Done event with Project.ApplyCheck on its own goroutine.
+func (c *UpdateChecker) Run(ctx context.Context, projects []*Project) <-chan CheckEvent {
+	// Two events per project, workers never block on a slow reader
+	events := make(chan CheckEvent, len(projects)*2)
+	jobs := make(chan int)
+
+	// Copied here, the caller may change the projects while workers run
+	imageInfo := make([]map[string]ImageInfo, len(projects))
+	for i, p := range projects {
+		imageInfo[i] = copyImageInfo(p.ImageInfo)
+	}
+	check := c.check
+	if check == nil {
+		check = func(ctx context.Context, p *Project, imageInfo map[string]ImageInfo) (*CheckResult, error) {
+			return p.checkImages(ctx, imageInfo, c.acquire, c.MaxAge)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < c.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if ctx.Err() != nil {
+					continue // Dispatched just before the cancel, not started
+				}
+				p := projects[idx]
+				events <- CheckEvent{Index: idx, Project: p}
+				result, err := check(ctx, p, imageInfo[idx])
+				events <- CheckEvent{Index: idx, Project: p, Done: true, Err: err, Result: result}
+			}
+		}()
+	}
+
+	go func() {
+	dispatch:
+		for i := range projects {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(events)
+	}()
+
+	return events
+}
+
+// acquire blocks until a pull slot for the image's registry is free
+// and returns the function releasing it
+func (c *UpdateChecker) acquire(imageName string) func() {
+	if c.RegistryWorkers <= 0 {
+		return func() {}
+	}
+
+	registry := registryOf(imageName)
+
+	c.mu.Lock()
+	sem, ok := c.registries[registry]
+	if !ok {
+		sem = make(chan struct{}, c.RegistryWorkers)
+		c.registries[registry] = sem
+	}
+	c.mu.Unlock()
+
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// registryOf returns the registry host of an image reference
+// e.g. "ghcr.io/foo/bar:1" -> "ghcr.io", "postgres:15" -> "docker.io"
+func registryOf(imageName string) string {
+	slash := strings.Index(imageName, "/")
+	if slash == -1 {
+		return "docker.io"
+	}
+
+	first := imageName[:slash]
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return "docker.io"
+}
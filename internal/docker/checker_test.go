@@ -0,0 +1,266 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateCheckerRun(t *testing.T) {
+	fakeDocker(t)
+
+	var projects []*Project
+	for i := 0; i < 6; i++ {
+		images := []string{"nginx:1.25", fmt.Sprintf("example/app%d:1.0", i)}
+		if i%2 == 0 {
+			images = append(images, "example/missing:1.0")
+		}
+		projects = append(projects, fakeProject(t, fmt.Sprintf("app%d", i), images, ""))
+	}
+	// Results of an earlier check are kept for images not checked again
+	projects[0].ImageInfo = map[string]ImageInfo{"old:1": {Name: "old:1"}}
+
+	checker := NewUpdateChecker(3, 1)
+	done := 0
+	for ev := range checker.Run(context.Background(), projects) {
+		if projects[ev.Index] != ev.Project {
+			t.Fatalf("event %d for project %s", ev.Index, ev.Project.Name)
+		}
+		if !ev.Done {
+			continue
+		}
+		if ev.Err != nil {
+			t.Errorf("%s: %v", ev.Project.Name, ev.Err)
+		}
+		// Like the TUI: apply and read while other workers still run
+		ev.Project.ApplyCheck(ev.Result)
+		for _, p := range projects {
+			_ = p.HasUpdates
+			_ = len(p.ImageInfo)
+			_ = len(p.Images)
+		}
+		done++
+	}
+	if done != len(projects) {
+		t.Fatalf("%d projects done, want %d", done, len(projects))
+	}
+
+	for i, p := range projects {
+		if len(p.Images) != 2+(1-i%2) {
+			t.Errorf("%s: images %v", p.Name, p.Images)
+		}
+		for _, name := range p.Images {
+			if _, ok := p.ImageInfo[name]; !ok {
+				t.Errorf("%s: no result for %s", p.Name, name)
+			}
+		}
+		// The missing image was never pulled, that counts as an update
+		if want := i%2 == 0; p.HasUpdates != want {
+			t.Errorf("%s: HasUpdates = %v, want %v", p.Name, p.HasUpdates, want)
+		}
+	}
+	if _, ok := projects[0].ImageInfo["old:1"]; !ok {
+		t.Errorf("earlier result dropped")
+	}
+}
+
+func TestUpdateCheckerRunLeavesProjects(t *testing.T) {
+	fakeDocker(t)
+	p := fakeProject(t, "app", []string{"example/missing:1.0"}, "")
+
+	var result *CheckResult
+	for ev := range NewUpdateChecker(1, 0).Run(context.Background(), []*Project{p}) {
+		if ev.Done {
+			result = ev.Result
+		}
+	}
+	if p.HasUpdates || p.ImageInfo != nil || p.Images != nil {
+		t.Fatalf("Run changed the project: %+v", p)
+	}
+	p.ApplyCheck(result)
+	if !p.HasUpdates || p.ImageInfo["example/missing:1.0"].LatestVersion != "not pulled" {
+		t.Errorf("ApplyCheck: %+v", p)
+	}
+}
+
+// concurrency tracks how many calls run at the same time
+type concurrency struct {
+	mu       sync.Mutex
+	running  int
+	max      int
+	finished int
+}
+
+// enter records a started call and returns the function recording its end
+func (c *concurrency) enter() func() {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		c.running--
+		c.finished++
+		c.mu.Unlock()
+	}
+}
+
+func (c *concurrency) stats() (max, finished int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.max, c.finished
+}
+
+// waitGoroutines fails the test if more than n goroutines are still
+// running after a second
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Errorf("%d goroutines running, %d before: leaked", runtime.NumGoroutine(), n)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testProjects(n int) []*Project {
+	projects := make([]*Project, n)
+	for i := range projects {
+		projects[i] = &Project{Name: fmt.Sprintf("app%d", i), Path: fmt.Sprintf("/srv/app%d", i)}
+	}
+	return projects
+}
+
+func TestUpdateCheckerWorkers(t *testing.T) {
+	for _, workers := range []int{1, 3, 8} {
+		var c concurrency
+		checker := NewUpdateChecker(workers, 0)
+		checker.check = func(ctx context.Context, p *Project, _ map[string]ImageInfo) (*CheckResult, error) {
+			defer c.enter()()
+			time.Sleep(5 * time.Millisecond)
+			return &CheckResult{complete: true}, nil
+		}
+
+		started, done := 0, 0
+		for ev := range checker.Run(context.Background(), testProjects(12)) {
+			if ev.Done {
+				done++
+			} else {
+				started++
+			}
+		}
+		max, _ := c.stats()
+		if started != 12 || done != 12 {
+			t.Errorf("workers %d: %d started, %d done events, want 12 each", workers, started, done)
+		}
+		if max > workers {
+			t.Errorf("workers %d: %d checks ran at the same time", workers, max)
+		}
+		if workers > 1 && max < 2 {
+			t.Errorf("workers %d: checks never ran in parallel", workers)
+		}
+	}
+}
+
+func TestUpdateCheckerRegistryLimit(t *testing.T) {
+	checker := NewUpdateChecker(8, 2)
+	var dockerHub, ghcr concurrency
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, image := range []string{"nginx:1.25", "ghcr.io/org/app:1"} {
+			wg.Add(1)
+			go func(image string) {
+				defer wg.Done()
+				release := checker.acquire(image)
+				defer release()
+				c := &dockerHub
+				if registryOf(image) == "ghcr.io" {
+					c = &ghcr
+				}
+				defer c.enter()()
+				time.Sleep(5 * time.Millisecond)
+			}(image)
+		}
+	}
+	wg.Wait()
+
+	for name, c := range map[string]*concurrency{"docker.io": &dockerHub, "ghcr.io": &ghcr} {
+		if max, finished := c.stats(); max > 2 || finished != 8 {
+			t.Errorf("%s: %d pulls at the same time, %d finished; want at most 2 and 8", name, max, finished)
+		}
+	}
+}
+
+func TestUpdateCheckerCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var c concurrency
+	checker := NewUpdateChecker(2, 0)
+	checker.check = func(ctx context.Context, p *Project, _ map[string]ImageInfo) (*CheckResult, error) {
+		defer c.enter()()
+		<-ctx.Done()
+		return nil, cancelled(ctx, "update check")
+	}
+
+	events := checker.Run(ctx, testProjects(10))
+	<-events // First check started
+	cancel()
+
+	errs := 0
+	timeout := time.After(5 * time.Second)
+	for open := true; open; {
+		select {
+		case ev, ok := <-events:
+			open = ok
+			if ok && ev.Done && ev.Err != nil {
+				errs++
+			}
+		case <-timeout:
+			t.Fatal("event channel not closed after cancel")
+		}
+	}
+	if _, finished := c.stats(); finished > 2 || errs != finished {
+		t.Errorf("%d checks ran, %d failed; want at most the 2 running ones, all failed", finished, errs)
+	}
+	waitGoroutines(t, before)
+}
+
+func TestUpdateCheckerUnreadEvents(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var c concurrency
+	checker := NewUpdateChecker(4, 0)
+	checker.check = func(ctx context.Context, p *Project, _ map[string]ImageInfo) (*CheckResult, error) {
+		defer c.enter()()
+		return &CheckResult{complete: true}, nil
+	}
+
+	// Nobody reads until every check finished: the buffer holds all events
+	events := checker.Run(context.Background(), testProjects(20))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, finished := c.stats(); finished == 20 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("checks blocked on unread events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitGoroutines(t, before)
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != 40 {
+		t.Errorf("%d events, want 40", n)
+	}
+}
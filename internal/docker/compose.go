@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Labels read from compose services to configure docker-compose-manager
+const (
+	LabelUpdatePriority = "dcm.update.priority"
+	LabelUpdatePolicy   = "dcm.update.policy"
+)
+
+// composeConfig is the subset of `docker compose config --format json` we use
+type composeConfig struct {
+	Name     string                    `json:"name"`
+	Services map[string]composeService `json:"services"`
+	Volumes  map[string]composeVolume  `json:"volumes"`     // Named volumes by key
+	Hooks    json.RawMessage           `json:"x-dcm-hooks"` // Top-level extension, see Hooks
+}
+
+// composeService is a single service of the rendered compose config
+type composeService struct {
+	Image     string                     `json:"image"`
+	Labels    map[string]string          `json:"labels"`
+	DependsOn map[string]json.RawMessage `json:"depends_on"`
+	Volumes   []composeMount             `json:"volumes"`
+}
+
+// composeMount is a volume or bind mount of a service
+type composeMount struct {
+	Type     string `json:"type"`   // "volume", "bind", "tmpfs", ...
+	Source   string `json:"source"` // Key of a named volume or absolute host path
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// composeVolume is a top-level named volume
+type composeVolume struct {
+	Name string `json:"name"` // Name of the volume on the daemon, e.g. "myproject_data"
+}
+
+// renderedConfigKey is the context key of a renderedConfig
+type renderedConfigKey struct{}
+
+// renderedConfig is the compose config of a project, rendered once for all
+// lookups of an operation (policies, strategy, hooks, probe, backups)
+type renderedConfig struct {
+	project *Project
+	once    sync.Once
+	cfg     *composeConfig
+	err     error
+}
+
+// withComposeConfig returns ctx for an operation on the project: within it,
+// loadComposeConfig renders the config once and returns it again after
+func (p *Project) withComposeConfig(ctx context.Context) context.Context {
+	if r, ok := ctx.Value(renderedConfigKey{}).(*renderedConfig); ok && r.project == p {
+		return ctx
+	}
+	return context.WithValue(ctx, renderedConfigKey{}, &renderedConfig{project: p})
+}
+
+// loadComposeConfig returns the compose config of the project, rendered
+// once per operation (see withComposeConfig)
+func (p *Project) loadComposeConfig(ctx context.Context) (*composeConfig, error) {
+	r, ok := ctx.Value(renderedConfigKey{}).(*renderedConfig)
+	if !ok || r.project != p {
+		return p.renderComposeConfig(ctx)
+	}
+	r.once.Do(func() {
+		r.cfg, r.err = p.renderComposeConfig(ctx)
+	})
+	return r.cfg, r.err
+}
+
+// renderComposeConfig renders the compose config of the project.
+// docker-compose v1 has no JSON output and always fails here.
+func (p *Project) renderComposeConfig(ctx context.Context) (*composeConfig, error) {
+	cmd := p.compose(ctx, "config", "--format", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render compose config: %w", err)
+	}
+
+	var cfg composeConfig
+	if err := json.Unmarshal(output, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdatePriority returns the update priority of the project (lower runs first).
+// It is the smallest dcm.update.priority label of all services, or
+// DefaultUpdatePriority if no service sets one.
+func (p *Project) UpdatePriority(ctx context.Context) int {
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return DefaultUpdatePriority
+	}
+
+	priority := DefaultUpdatePriority
+	found := false
+	for _, svc := range cfg.Services {
+		value, ok := svc.Labels[LabelUpdatePriority]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		if !found || n < priority {
+			priority = n
+			found = true
+		}
+	}
+	return priority
+}
@@ -0,0 +1,227 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statusWorkers is the number of projects whose status is queried in parallel
+const statusWorkers = 8
+
+// Discovery is the result of searching a directory tree for compose projects
+type Discovery struct {
+	Projects []*Project
+	// Directories maps every directory that was searched (and every
+	// .dcmignore file) to its mtime. Adding or removing a file or
+	// subdirectory changes the parent's mtime, so comparing them tells
+	// whether a rescan is needed.
+	Directories map[string]time.Time
+	// Diagnostics lists ambiguous, unreadable and skipped paths
+	Diagnostics []Diagnostic
+}
+
+// DiagnosticKind classifies a discovery diagnostic
+type DiagnosticKind string
+
+const (
+	DiagnosticAmbiguous  DiagnosticKind = "ambiguous"  // Several compose files in one directory
+	DiagnosticUnreadable DiagnosticKind = "unreadable" // Directory could not be read
+	DiagnosticSkipped    DiagnosticKind = "skipped"    // Excluded, hidden, duplicate or broken path
+)
+
+// Diagnostic is a noteworthy path found during discovery
+type Diagnostic struct {
+	Kind    DiagnosticKind `json:"kind"`
+	Path    string         `json:"path"`
+	Message string         `json:"message"`
+}
+
+// Adopt adds the projects in dirs, adopted from the unmanaged stacks view,
+// that weren't discovered anyway. Their directories are tracked like
+// searched ones, so removing the compose file triggers a rescan.
+func (d *Discovery) Adopt(dirs []string) {
+	known := make(map[string]bool, len(d.Projects))
+	for _, p := range d.Projects {
+		known[p.Path] = true
+	}
+
+	for _, dir := range dirs {
+		if known[dir] {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil {
+			d.Directories[dir] = info.ModTime()
+		}
+		project, err := AdoptProject(dir)
+		if err != nil {
+			d.Diagnostics = append(d.Diagnostics, Diagnostic{
+				Kind:    DiagnosticUnreadable,
+				Path:    dir,
+				Message: "adopted project: " + err.Error(),
+			})
+			continue
+		}
+		known[dir] = true
+		d.Projects = append(d.Projects, project)
+	}
+}
+
+// composeFileNames are the compose file names in the order compose itself
+// prefers them when a directory contains several
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// FindProjects searches for docker-compose projects in a directory.
+// Directories are read in parallel and symlinked directories are followed
+// (each real directory once). Paths matching exclude or a .dcmignore file
+// are skipped (see Ignorer), as are projects hidden with a .dcmhide marker.
+// The container status is not queried, see RefreshStatuses.
+func FindProjects(searchDir string, maxDepth int, exclude []string) (*Discovery, error) {
+	if _, err := os.Stat(searchDir); err != nil {
+		return nil, fmt.Errorf("failed to search for projects: %w", err)
+	}
+
+	result := walkTree(searchDir, searchDir, maxDepth, NewIgnorer(searchDir, exclude))
+
+	discovery := &Discovery{
+		Directories: result.dirs,
+		Diagnostics: result.diagnostics,
+	}
+	for path, modTime := range result.ignoreFiles {
+		discovery.Directories[path] = modTime
+	}
+	for path, info := range result.composeFiles {
+		project := newProject(path, info)
+		project.Warnings = ambiguityWarnings(path, result.ignored[path])
+		discovery.Projects = append(discovery.Projects, project)
+	}
+	sort.Slice(discovery.Projects, func(i, j int) bool {
+		return discovery.Projects[i].ComposeFile < discovery.Projects[j].ComposeFile
+	})
+
+	if len(discovery.Projects) == 0 {
+		return nil, fmt.Errorf("no docker-compose projects found in %s", searchDir)
+	}
+
+	return discovery, nil
+}
+
+// IsComposeFile reports whether name is one of the compose file names we look for
+func IsComposeFile(name string) bool {
+	for _, n := range composeFileNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredComposeFile returns the compose file compose would use out of
+// several in the same directory, and the others
+func preferredComposeFile(files []string) (used string, others []string) {
+	rank := func(f string) int {
+		for i, n := range composeFileNames {
+			if filepath.Base(f) == n {
+				return i
+			}
+		}
+		return len(composeFileNames)
+	}
+
+	sorted := append([]string(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return rank(sorted[i]) < rank(sorted[j]) })
+	return sorted[0], sorted[1:]
+}
+
+// ambiguityWarnings returns the project warnings for compose files ignored in favor of used
+func ambiguityWarnings(used string, others []string) []string {
+	var warnings []string
+	for _, f := range others {
+		warnings = append(warnings, fmt.Sprintf("%s is ignored, compose uses %s", filepath.Base(f), filepath.Base(used)))
+	}
+	return warnings
+}
+
+// NewProject creates a project for a compose file found outside of
+// FindProjects (e.g. by the Watcher). Other compose files in the same
+// directory are recorded as warnings; the status is not queried.
+func NewProject(composeFile string) (*Project, error) {
+	info, err := os.Stat(composeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var others []string
+	for _, name := range composeFileNames {
+		other := filepath.Join(filepath.Dir(composeFile), name)
+		if other == composeFile {
+			continue
+		}
+		if _, err := os.Stat(other); err == nil {
+			others = append(others, other)
+		}
+	}
+
+	project := newProject(composeFile, info)
+	project.Warnings = ambiguityWarnings(composeFile, others)
+	return project, nil
+}
+
+// newProject creates a project for a compose file; the project is named after its directory
+func newProject(composeFile string, info os.FileInfo) *Project {
+	projectDir := filepath.Dir(composeFile)
+	return &Project{
+		Name:           filepath.Base(projectDir),
+		Path:           projectDir,
+		ComposeFile:    composeFile,
+		ComposeModTime: info.ModTime(),
+		LastUpdated:    time.Now(),
+	}
+}
+
+// DiscoveryChanged returns why the discovery stored in the cache is outdated,
+// or nil if the exclude patterns are the same and no searched directory,
+// ignore file or compose file changed since.
+func (cf *CacheFile) DiscoveryChanged(exclude []string) error {
+	if len(cf.Directories) == 0 {
+		return fmt.Errorf("cache has no discovery data")
+	}
+	if strings.Join(cf.Exclude, "\n") != strings.Join(exclude, "\n") {
+		return fmt.Errorf("exclude patterns changed")
+	}
+
+	for dir, modTime := range cf.Directories {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("%s was removed", dir)
+		}
+		if !info.ModTime().Equal(modTime) {
+			return fmt.Errorf("%s changed", dir)
+		}
+	}
+
+	for _, p := range cf.Projects {
+		if p.Host != "" {
+			continue // Other hosts are discovered on every start
+		}
+		info, err := os.Stat(p.ComposeFile)
+		if err != nil {
+			return fmt.Errorf("%s was removed", p.ComposeFile)
+		}
+		if !info.ModTime().Equal(p.ComposeModTime) {
+			return fmt.Errorf("%s was edited", p.ComposeFile)
+		}
+	}
+
+	return nil
+}
+
+// CarryOverImageInfo copies update check results from previously known
+// projects (matched by ID) into freshly discovered ones, keeping the
+// newer result per image
+func CarryOverImageInfo(projects, previous []*Project) []*Project {
+	return mergeProjects(projects, previous)
+}
@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryChanged(t *testing.T) {
+	// discover returns a cache of a fresh discovery of root
+	discover := func(t *testing.T, root string) *CacheFile {
+		t.Helper()
+		d, err := FindProjects(root, 5, []string{"tmp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &CacheFile{Projects: d.Projects, Directories: d.Directories, Exclude: []string{"tmp"}}
+	}
+	// touch sets the mtime of path to an hour from now
+	touch := func(t *testing.T, path string) {
+		t.Helper()
+		future := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, root string)
+		exclude []string
+		want    string
+	}{
+		{"unchanged", func(*testing.T, string) {}, []string{"tmp"}, ""},
+		{"exclude patterns", func(*testing.T, string) {}, nil, "exclude patterns changed"},
+		{"project added", func(t *testing.T, root string) {
+			writeFiles(t, root, map[string]string{"apps/new/compose.yml": ""})
+		}, []string{"tmp"}, "apps changed"},
+		{"directory removed", func(t *testing.T, root string) {
+			parent := filepath.Join(root, "apps", "db")
+			info, err := os.Stat(parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.RemoveAll(filepath.Join(parent, "data"))
+			// Only the removal itself tells, not the parent's mtime
+			os.Chtimes(parent, info.ModTime(), info.ModTime())
+		}, []string{"tmp"}, "data was removed"},
+		{"compose file edited", func(t *testing.T, root string) {
+			touch(t, filepath.Join(root, "apps", "web", "compose.yml"))
+		}, []string{"tmp"}, "compose.yml was edited"},
+		{"ignore file edited", func(t *testing.T, root string) {
+			touch(t, filepath.Join(root, IgnoreFileName))
+		}, []string{"tmp"}, IgnoreFileName + " changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				IgnoreFileName:               "old/\n",
+				"apps/web/compose.yml":       "",
+				"apps/db/docker-compose.yml": "",
+				"apps/db/data/.keep":         "",
+			})
+			cf := discover(t, root)
+			tt.change(t, root)
+
+			err := cf.DiscoveryChanged(tt.exclude)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("DiscoveryChanged = %v, want nil", err)
+				}
+			} else if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("DiscoveryChanged = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if err := (&CacheFile{}).DiscoveryChanged(nil); err == nil {
+		t.Errorf("cache without discovery data accepted")
+	}
+}
+
+func TestCheckImagesMaxAge(t *testing.T) {
+	dir := fakeDocker(t)
+	p := fakeProject(t, "app", []string{"nginx:1.25", "redis:7"}, "")
+
+	result, err := p.checkImages(context.Background(), nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := result.imageInfo
+	stale := info["redis:7"]
+	stale.CheckedAt = time.Now().Add(-2 * time.Hour)
+	info["redis:7"] = stale
+	fresh := info["nginx:1.25"]
+	os.Remove(filepath.Join(dir, "calls"))
+
+	// Only the image checked longer than maxAge ago goes to the registry
+	if _, err := p.checkImages(context.Background(), info, nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var pulls []string
+	for _, call := range fakeDockerCalls(t, dir) {
+		if _, args, _ := strings.Cut(call, "|"); strings.HasPrefix(args, "pull ") {
+			pulls = append(pulls, args)
+		}
+	}
+	if len(pulls) != 1 || pulls[0] != "pull --quiet redis:7" {
+		t.Errorf("pulls %q, want only redis:7", pulls)
+	}
+	if !info["nginx:1.25"].CheckedAt.Equal(fresh.CheckedAt) {
+		t.Errorf("fresh result replaced")
+	}
+	if !info["redis:7"].CheckedAt.After(stale.CheckedAt) {
+		t.Errorf("stale result kept")
+	}
+}
+
+func TestPreferredComposeFile(t *testing.T) {
+	tests := []struct {
+		files  []string
+		used   string
+		others []string
+	}{
+		{[]string{"/a/compose.yml"}, "/a/compose.yml", []string{}},
+		{[]string{"/a/docker-compose.yml", "/a/compose.yml"}, "/a/compose.yml", []string{"/a/docker-compose.yml"}},
+		{[]string{"/a/docker-compose.yml", "/a/docker-compose.yaml", "/a/compose.yml", "/a/compose.yaml"},
+			"/a/compose.yaml", []string{"/a/compose.yml", "/a/docker-compose.yaml", "/a/docker-compose.yml"}},
+	}
+	for _, tt := range tests {
+		used, others := preferredComposeFile(tt.files)
+		if used != tt.used || !reflect.DeepEqual(others, tt.others) {
+			t.Errorf("preferredComposeFile(%q) = %q, %q; want %q, %q", tt.files, used, others, tt.used, tt.others)
+		}
+	}
+}
+
+func TestFindProjectsDiagnostics(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"web/compose.yml":         "",
+		"web/docker-compose.yaml": "",
+		"db/docker-compose.yml":   "",
+		"locked/app/compose.yml":  "",
+	})
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	// root can read it anyway
+	readable := os.Geteuid() == 0
+
+	d, err := FindProjects(root, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, p := range d.Projects {
+		files = append(files, p.ComposeFile)
+	}
+	want := []string{filepath.Join(root, "db", "docker-compose.yml"), filepath.Join(root, "web", "compose.yml")}
+	if readable {
+		want = []string{want[0], filepath.Join(locked, "app", "compose.yml"), want[1]}
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("compose files %q, want %q", files, want)
+	}
+	web := d.Projects[len(d.Projects)-1]
+	if want := []string{"docker-compose.yaml is ignored, compose uses compose.yml"}; !reflect.DeepEqual(web.Warnings, want) {
+		t.Errorf("warnings %q, want %q", web.Warnings, want)
+	}
+
+	kinds := make(map[string]DiagnosticKind)
+	for _, diag := range d.Diagnostics {
+		kinds[diag.Path] = diag.Kind
+	}
+	if kinds[filepath.Join(root, "web")] != DiagnosticAmbiguous {
+		t.Errorf("web not diagnosed as ambiguous: %+v", d.Diagnostics)
+	}
+	if !readable && kinds[locked] != DiagnosticUnreadable {
+		t.Errorf("locked not diagnosed as unreadable: %+v", d.Diagnostics)
+	}
+
+	if _, err := FindProjects(filepath.Join(root, "db", "missing"), 5, nil); err == nil {
+		t.Errorf("missing search directory accepted")
+	}
+}
+
+func TestNewProject(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"app/compose.yaml": "", "app/docker-compose.yml": ""})
+
+	p, err := NewProject(filepath.Join(root, "app", "compose.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "app" || p.Path != filepath.Join(root, "app") || p.ComposeModTime.IsZero() {
+		t.Errorf("project %+v", p)
+	}
+	if want := []string{"docker-compose.yml is ignored, compose uses compose.yaml"}; !reflect.DeepEqual(p.Warnings, want) {
+		t.Errorf("warnings %q, want %q", p.Warnings, want)
+	}
+	if _, err := NewProject(filepath.Join(root, "app", "compose.yml")); err == nil {
+		t.Errorf("missing compose file accepted")
+	}
+}
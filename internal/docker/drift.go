@@ -0,0 +1,226 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compose labels on containers used for drift detection
+const (
+	labelConfigHash = "com.docker.compose.config-hash"
+	labelOneOff     = "com.docker.compose.oneoff"
+)
+
+// ServiceDrift describes how the containers of a service differ from the
+// current compose file, i.e. what `up -d` would change
+type ServiceDrift struct {
+	Service string
+	Reason  string
+}
+
+// DriftResult is the outcome of CheckDrift for a project
+type DriftResult struct {
+	Drift []ServiceDrift
+	Lock  LockState
+}
+
+// composeContainer is a container as listed by `compose ps --format json`
+type composeContainer struct {
+	Service string   `json:"Service"`
+	Image   string   `json:"Image"`
+	Labels  labelSet `json:"Labels"`
+}
+
+// label returns the value of a label of the container
+func (c composeContainer) label(key string) string {
+	return c.Labels[key]
+}
+
+// CheckDrift compares the running containers with the current compose file.
+// A service drifted if its containers were created from a different config
+// (com.docker.compose.config-hash vs. `docker compose config --hash`), use a
+// different image reference, no longer exist in the compose file, or were
+// never created although other services were. Projects without containers
+// never drift. The lock state of the images is checked along the way. Not
+// available with docker-compose v1.
+func (p *Project) CheckDrift(ctx context.Context) (DriftResult, error) {
+	containers, err := p.composeContainers(ctx)
+	if err != nil {
+		return DriftResult{}, err
+	}
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return DriftResult{}, err
+	}
+	result := DriftResult{Lock: lockState(cfg, containers)}
+	if len(containers) == 0 {
+		return result, nil
+	}
+
+	hashes, err := p.configHashes(ctx)
+	if err != nil {
+		return DriftResult{}, err
+	}
+
+	reasons := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		if c.label(labelOneOff) == "True" {
+			continue // `compose run` containers
+		}
+		seen[c.Service] = true
+		if _, done := reasons[c.Service]; done {
+			continue // Report each scaled service once
+		}
+
+		hash, ok := hashes[c.Service]
+		image := cfg.Services[c.Service].Image
+		switch {
+		case !ok:
+			reasons[c.Service] = "removed from compose file"
+		case c.label(labelConfigHash) != hash:
+			reasons[c.Service] = "configuration changed"
+		case image != "" && normalizeImageRef(c.Image) != normalizeImageRef(image):
+			reasons[c.Service] = fmt.Sprintf("image changed: %s → %s", c.Image, image)
+		}
+	}
+	for service := range hashes {
+		if !seen[service] {
+			reasons[service] = "not created"
+		}
+	}
+
+	result.Drift = make([]ServiceDrift, 0, len(reasons))
+	for service, reason := range reasons {
+		result.Drift = append(result.Drift, ServiceDrift{Service: service, Reason: reason})
+	}
+	sort.Slice(result.Drift, func(i, j int) bool { return result.Drift[i].Service < result.Drift[j].Service })
+	return result, nil
+}
+
+// CheckDrifts runs CheckDrift for all projects in parallel and returns the
+// results by project ID. Projects whose check failed are left out.
+func CheckDrifts(ctx context.Context, projects []*Project) map[string]DriftResult {
+	results := make(map[string]DriftResult, len(projects))
+	jobs := make(chan *Project)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < statusWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				result, err := p.CheckDrift(ctx)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				results[p.ID()] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, p := range projects {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// Apply brings the containers in line with the compose file: changed services
+// are recreated, missing ones created and removed ones stopped and deleted
+func (p *Project) Apply(ctx context.Context) error {
+	p.holdBackImages(ctx, true)
+	output, err := p.compose(ctx, "up", "-d", "--remove-orphans").CombinedOutput()
+	if err != nil {
+		if err := cancelled(ctx, "apply"); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to apply: %s", string(output))
+	}
+
+	return p.UpdateStatus(ctx)
+}
+
+// composeContainers lists all containers of the project, running or not
+func (p *Project) composeContainers(ctx context.Context) ([]composeContainer, error) {
+	cmd := p.compose(ctx, "ps", "--all", "--format", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, nil
+	}
+
+	// Older compose versions print one JSON array, newer ones one object per line
+	var containers []composeContainer
+	if output[0] == '[' {
+		if err := json.Unmarshal(output, &containers); err != nil {
+			return nil, fmt.Errorf("failed to parse containers: %w", err)
+		}
+		return containers, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var c composeContainer
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse containers: %w", err)
+		}
+		containers = append(containers, c)
+	}
+	return containers, scanner.Err()
+}
+
+// configHashes returns the config hash compose assigns to each service of
+// the current compose file
+func (p *Project) configHashes(ctx context.Context) (map[string]string, error) {
+	cmd := p.compose(ctx, "config", "--hash", "*")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash compose config: %w", err)
+	}
+
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if service, hash, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			hashes[service] = strings.TrimSpace(hash)
+		}
+	}
+	return hashes, nil
+}
+
+// normalizeImageRef expands the short forms docker accepts for the same
+// image ("nginx" = "docker.io/library/nginx:latest") so references compare equal
+func normalizeImageRef(ref string) string {
+	if ref == "" || strings.Contains(ref, "@") {
+		return ref
+	}
+
+	name, tag := ref, "latest"
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, tag = ref[:i], ref[i+1:]
+	}
+
+	if registryOf(name) == "docker.io" {
+		name = strings.TrimPrefix(name, "docker.io/")
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+		name = "docker.io/" + name
+	}
+	return name + ":" + tag
+}
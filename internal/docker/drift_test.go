@@ -0,0 +1,26 @@
+package docker
+
+import "testing"
+
+func TestNormalizeImageRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"", ""},
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"library/nginx", "docker.io/library/nginx:latest"},
+		{"docker.io/nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"grafana/grafana", "docker.io/grafana/grafana:latest"},
+		{"ghcr.io/org/app", "ghcr.io/org/app:latest"},
+		{"localhost/app:dev", "localhost/app:dev"},
+		{"registry.local:5000/app", "registry.local:5000/app:latest"},
+		{"nginx@sha256:abcd", "nginx@sha256:abcd"},
+	}
+	for _, tt := range tests {
+		if got := normalizeImageRef(tt.ref); got != tt.want {
+			t.Errorf("normalizeImageRef(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// PlannedCommand is a step of a dry run: a command that would have run, or
+// a note for a step that isn't a single command (e.g. the verification)
+type PlannedCommand struct {
+	Host string   // Name of the host, empty for the local daemon
+	Dir  string   // Working directory, empty if it doesn't matter
+	Args []string // Command line, empty for notes
+	Note string   // Description of a step without command
+}
+
+// String formats the step like a shell command line
+func (c PlannedCommand) String() string {
+	if len(c.Args) == 0 {
+		return "# " + c.Note
+	}
+	s := shellQuote(c.Args)
+	if c.Dir != "" {
+		s = "cd " + shellQuote([]string{c.Dir}) + " && " + s
+	}
+	if c.Host != "" {
+		s = c.Host + ": " + s
+	}
+	return s
+}
+
+// Recorder collects the commands operations would run. Under a context from
+// WithDryRun, commands changing anything (up, down, pull, tag, run, hooks,
+// ...) are recorded instead of executed; read-only ones (ps, config,
+// inspect, ...) still run, so the plan reflects the actual state, e.g.
+// which services an update would recreate.
+type Recorder struct {
+	mu    sync.Mutex
+	steps []PlannedCommand
+}
+
+type recorderKey struct{}
+
+// WithDryRun returns a context under which operations record their
+// commands in r instead of executing them
+func WithDryRun(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// Steps returns the recorded steps in order
+func (r *Recorder) Steps() []PlannedCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PlannedCommand(nil), r.steps...)
+}
+
+func (r *Recorder) record(step PlannedCommand) {
+	r.mu.Lock()
+	r.steps = append(r.steps, step)
+	r.mu.Unlock()
+}
+
+// recorderFrom returns the recorder of a dry run, nil otherwise
+func recorderFrom(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// dryRun reports whether ctx belongs to a dry run
+func dryRun(ctx context.Context) bool {
+	return recorderFrom(ctx) != nil
+}
+
+// withoutDryRun lets read-only commands the classification can't tell
+// apart (helper containers listing backups) run during a dry run
+func withoutDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recorderKey{}, (*Recorder)(nil))
+}
+
+// notef records a note if ctx belongs to a dry run
+func notef(ctx context.Context, format string, args ...any) {
+	if r := recorderFrom(ctx); r != nil {
+		r.record(PlannedCommand{Note: fmt.Sprintf(format, args...)})
+	}
+}
+
+// readOnlyEngine and readOnlyCompose are the subcommands that never change
+// anything; "image", "network", ... are followed by a second word
+var (
+	readOnlyEngine = map[string]bool{
+		"ps": true, "inspect": true, "images": true, "events": true, "version": true, "info": true,
+		"image inspect": true, "image ls": true, "network ls": true, "network inspect": true,
+		"volume ls": true, "volume inspect": true, "container ls": true, "container inspect": true,
+	}
+	readOnlyCompose = map[string]bool{
+		"config": true, "ps": true, "version": true, "images": true, "ls": true, "top": true,
+	}
+)
+
+// readOnly reports whether the command name args only reads state.
+// Unknown commands (hooks, helpers) count as changing something.
+func readOnly(name string, args []string) bool {
+	switch name {
+	case "find":
+		return true
+	case "docker-compose":
+		return len(args) > 0 && readOnlyCompose[args[0]]
+	case "docker", "podman", "nerdctl":
+	default:
+		return false
+	}
+
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "compose" {
+		return len(args) > 1 && readOnlyCompose[args[1]]
+	}
+	if len(args) > 1 && readOnlyEngine[args[0]+" "+args[1]] {
+		return true
+	}
+	return readOnlyEngine[args[0]]
+}
@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"docker ps --all", true},
+		{"docker inspect abc", true},
+		{"docker image inspect --format {{.Id}} nginx", true},
+		{"docker network ls", true},
+		{"docker compose config --format json", true},
+		{"docker compose ps --quiet", true},
+		{"podman compose config --images", true},
+		{"nerdctl ps --all", true},
+		{"docker-compose config", true},
+		{"find /srv -name compose.yml", true},
+
+		{"docker compose up -d", false},
+		{"docker compose pull", false},
+		{"docker compose run --rm app sh", false},
+		{"docker-compose down", false},
+		{"docker tag nginx:1.25 nginx:rollback", false},
+		{"docker image rm nginx", false},
+		{"docker network rm web", false},
+		{"docker run --rm busybox", false},
+		{"docker stop abc", false},
+		{"docker compose", false},
+		{"docker", false},
+		{"sh -c ./hook.sh", false},
+		{"cat compose.yml", false},
+	}
+	for _, tt := range tests {
+		fields := strings.Fields(tt.cmd)
+		if got := readOnly(fields[0], fields[1:]); got != tt.want {
+			t.Errorf("readOnly(%q) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestPlannedCommandString(t *testing.T) {
+	tests := []struct {
+		step PlannedCommand
+		want string
+	}{
+		{PlannedCommand{Args: []string{"docker", "compose", "pull"}}, "docker compose pull"},
+		{PlannedCommand{Dir: "/srv/my app", Args: []string{"docker", "compose", "up", "-d"}}, "cd '/srv/my app' && docker compose up -d"},
+		{PlannedCommand{Host: "nas", Dir: "/srv/web", Args: []string{"sh", "-c", "echo hi"}}, "nas: cd /srv/web && sh -c 'echo hi'"},
+		{PlannedCommand{Note: "verify web"}, "# verify web"},
+	}
+	for _, tt := range tests {
+		if got := tt.step.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDryRunRecords(t *testing.T) {
+	dir := fakeDocker(t)
+	p := fakeProject(t, "app", []string{"nginx:1.25"}, "")
+
+	var r Recorder
+	ctx := WithDryRun(context.Background(), &r)
+	if _, err := p.recreate(ctx, StrategyChanged, []string{"web"}, false); err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+	notef(ctx, "done")
+
+	// Reading the config still runs, recreating is only recorded
+	if got, want := fakeDockerCalls(t, dir), []string{p.Path + "|compose config --format json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commands run %q, want %q", got, want)
+	}
+	want := []PlannedCommand{
+		{Dir: p.Path, Args: []string{"docker", "compose", "up", "-d", "--remove-orphans", "web"}},
+		{Note: "done"},
+	}
+	if got := r.Steps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps %+v, want %+v", got, want)
+	}
+
+	// Outside a dry run, and with it lifted, nothing is recorded
+	notef(context.Background(), "not recorded")
+	if err := (*Host)(nil).command(withoutDryRun(ctx), "", "docker", "tag", "a", "b").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Steps()) != len(want) {
+		t.Errorf("recorded outside the dry run: %+v", r.Steps())
+	}
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"time"
+)
+
+// eventsRetryInterval is how long to wait before restarting `docker events`
+// after it exited (daemon restart, docker not running yet)
+const eventsRetryInterval = 5 * time.Second
+
+// statusActions are the container events that change a project's status or health
+var statusActions = map[string]bool{
+	"create":  true,
+	"start":   true,
+	"restart": true,
+	"die":     true,
+	"stop":    true,
+	"kill":    true,
+	"destroy": true,
+	"pause":   true,
+	"unpause": true,
+	"oom":     true,
+	"died":    true, // podman's names for die and destroy
+	"remove":  true,
+}
+
+// ContainerEvent is a status change of a compose container
+type ContainerEvent struct {
+	Host       string    // Name of the host, empty for the local daemon
+	Project    string    // com.docker.compose.project label
+	WorkingDir string    // Directory compose ran in (empty for old compose versions)
+	Service    string    // Compose service of the container
+	Action     string    // Docker event action, e.g. "start", "die", "health_status"
+	Health     string    // New health status for "health_status" events
+	Time       time.Time // When the event happened
+}
+
+// WatchContainerEvents streams status changes of compose containers on all
+// hosts from `docker events` (or the backend's equivalent) until ctx is
+// cancelled, then closes the channel. Each stream is restarted if docker
+// (or ssh) exits, so it survives daemon restarts and dropped connections.
+// Hosts whose backend has no event stream are skipped.
+func WatchContainerEvents(ctx context.Context, hosts []*Host) <-chan ContainerEvent {
+	events := make(chan ContainerEvent, 64)
+
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		if h.Backend().eventsArgs() == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(h *Host) {
+			defer wg.Done()
+			for {
+				streamContainerEvents(ctx, h, events)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(eventsRetryInterval):
+				}
+			}
+		}(h)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events
+}
+
+// streamContainerEvents runs `docker events` on h once and forwards its
+// events until it exits
+func streamContainerEvents(ctx context.Context, h *Host, events chan<- ContainerEvent) {
+	b := h.Backend()
+	cmd := h.engine(ctx, b.eventsArgs()...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	defer cmd.Wait()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		ev, ok := b.parseEvent(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		if h != nil {
+			ev.Host = h.Name
+		}
+
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// containerEvent builds an event from the container's labels, reporting
+// false for events that don't affect status or health (exec, attach, ...)
+func containerEvent(attrs map[string]string, action, health string, t time.Time) (ContainerEvent, bool) {
+	if attrs[labelOneOff] == "True" {
+		return ContainerEvent{}, false
+	}
+
+	ev := ContainerEvent{
+		Project:    attrs[labelProject],
+		WorkingDir: attrs[labelWorkingDir],
+		Service:    attrs[labelService],
+		Action:     action,
+		Health:     health,
+		Time:       t,
+	}
+	return ev, action == "health_status" || statusActions[action]
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDockerParseEvent(t *testing.T) {
+	at := time.Unix(1704189600, 123)
+	tests := []struct {
+		name string
+		line string
+		want ContainerEvent
+		ok   bool
+	}{
+		{
+			"start",
+			`{"Type":"container","Action":"start","Actor":{"ID":"abc","Attributes":{"` + labelProject + `":"web","` + labelWorkingDir + `":"/srv/web","` + labelService + `":"app","image":"nginx"}},"timeNano":1704189600000000123}`,
+			ContainerEvent{Project: "web", WorkingDir: "/srv/web", Service: "app", Action: "start", Time: at},
+			true,
+		},
+		{
+			"health",
+			`{"Action":"health_status: unhealthy","Actor":{"Attributes":{"` + labelProject + `":"web","` + labelService + `":"app"}},"timeNano":1704189600000000123}`,
+			ContainerEvent{Project: "web", Service: "app", Action: "health_status", Health: "unhealthy", Time: at},
+			true,
+		},
+		{
+			"exec doesn't change the status",
+			`{"Action":"exec_start: sh","Actor":{"Attributes":{"` + labelProject + `":"web"}}}`,
+			ContainerEvent{}, false,
+		},
+		{
+			"compose run",
+			`{"Action":"start","Actor":{"Attributes":{"` + labelProject + `":"web","` + labelOneOff + `":"True"}}}`,
+			ContainerEvent{}, false,
+		},
+		{"invalid", `start web`, ContainerEvent{}, false},
+	}
+	for _, tt := range tests {
+		ev, ok := dockerEngine{}.parseEvent([]byte(tt.line))
+		if ok != tt.ok || (ok && ev != tt.want) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", tt.name, ev, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPodmanParseEvent(t *testing.T) {
+	at := time.Unix(1704189600, 0)
+	tests := []struct {
+		name string
+		line string
+		want ContainerEvent
+		ok   bool
+	}{
+		{
+			"died",
+			`{"ID":"abc","Status":"died","Type":"container","Attributes":{"` + labelProject + `":"web","` + labelWorkingDir + `":"/srv/web","` + labelService + `":"app"},"timeNano":1704189600000000000}`,
+			ContainerEvent{Project: "web", WorkingDir: "/srv/web", Service: "app", Action: "died", Time: at},
+			true,
+		},
+		{
+			"health",
+			`{"Status":"health_status","health_status":"healthy","Attributes":{"` + labelProject + `":"web","` + labelService + `":"app"},"timeNano":1704189600000000000}`,
+			ContainerEvent{Project: "web", Service: "app", Action: "health_status", Health: "healthy", Time: at},
+			true,
+		},
+		{"attach", `{"Status":"attach","Attributes":{"` + labelProject + `":"web"}}`, ContainerEvent{}, false},
+		{"invalid", `{`, ContainerEvent{}, false},
+	}
+	for _, tt := range tests {
+		ev, ok := podmanCompose{}.parseEvent([]byte(tt.line))
+		if ok != tt.ok || (ok && ev != tt.want) {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", tt.name, ev, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStatusActions(t *testing.T) {
+	for _, action := range []string{"start", "die", "destroy", "died", "remove", "oom"} {
+		if _, ok := containerEvent(map[string]string{labelProject: "web"}, action, "", time.Time{}); !ok {
+			t.Errorf("%s: ignored", action)
+		}
+	}
+	for _, action := range []string{"exec_create", "attach", "top", "resize", ""} {
+		if _, ok := containerEvent(map[string]string{labelProject: "web"}, action, "", time.Time{}); ok {
+			t.Errorf("%s: not ignored", action)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// commandWaitDelay is how long a cancelled child process gets to exit
+// after SIGINT before it is killed
+const commandWaitDelay = 10 * time.Second
+
+// command creates a command bound to ctx. When ctx is cancelled the child
+// receives SIGINT first (like Ctrl+C in a shell) so docker can stop pulls
+// and half-done recreates cleanly; it is killed if it doesn't exit in time.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// cancelled returns a descriptive error if ctx has been cancelled
+func cancelled(ctx context.Context, operation string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s cancelled: %w", operation, err)
+	}
+	return nil
+}
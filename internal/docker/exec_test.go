@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh and signals")
+	}
+	marker := filepath.Join(t.TempDir(), "interrupted")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cleans up on SIGINT like docker does, a kill would skip the trap
+	cmd := command(ctx, "sh", "-c", `trap 'echo cleaned > "$0"; exit 3' INT; echo ready; sleep 5 >/dev/null & wait`, marker)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdout.Read(make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	cancel()
+	cmd.Wait()
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("took %v to stop", elapsed)
+	}
+	if data, err := os.ReadFile(marker); err != nil || strings.TrimSpace(string(data)) != "cleaned" {
+		t.Errorf("no SIGINT cleanup: %q, %v", data, err)
+	}
+}
+
+func TestCancelledOperation(t *testing.T) {
+	fakeDocker(t)
+	p := fakeProject(t, "app", []string{"nginx:1.25"}, "")
+	p.Status = "running:1"
+	p.RunningContainers = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := p.UpdateStatus(ctx)
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "status cancelled") {
+		t.Errorf("UpdateStatus = %v, want a cancelled error", err)
+	}
+	// A cancelled query says nothing about the containers
+	if p.Status != "running:1" || p.RunningContainers != 1 {
+		t.Errorf("status changed to %q (%d)", p.Status, p.RunningContainers)
+	}
+
+	if err := cancelled(context.Background(), "status"); err != nil {
+		t.Errorf("cancelled without cancel = %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeDockerScript answers the docker commands of the tests. Compose
+// commands read the files of the project directory: images for
+// `config --images`, config.json for `config --format json` (fails if
+// missing). `ps` prints the ps file of the fake's directory. Image IDs are
+// derived from the reference, references containing "missing" don't exist.
+const fakeDockerScript = `#!/bin/sh
+echo "$PWD|$*" >> "$FAKE_DOCKER_DIR/calls"
+case "$*" in
+"compose config --images") exec cat images ;;
+"compose config --format json") [ -f config.json ] || exit 1; exec cat config.json ;;
+"ps "*) [ -f "$FAKE_DOCKER_DIR/ps" ] && cat "$FAKE_DOCKER_DIR/ps"; exit 0 ;;
+"image inspect --format {{.Id}} "*missing*) exit 1 ;;
+"image inspect --format {{.Id}} "*) echo "sha256:$(printf %s "$5" | cksum | cut -d' ' -f1)" ;;
+esac
+exit 0
+`
+
+// fakeDocker puts fakeDockerScript on PATH as docker and returns its
+// directory, which holds the calls log and the ps output
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(fakeDockerScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_DOCKER_DIR", dir)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// fakeProject creates a project directory with the given images and
+// compose config (none if empty)
+func fakeProject(t *testing.T, name string, images []string, config string) *Project {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images"), []byte(strings.Join(images, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Project{Name: name, Path: dir, ComposeFile: filepath.Join(dir, "compose.yaml")}
+}
+
+// fakeDockerCalls returns the logged calls as "dir|args"
+func fakeDockerCalls(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
@@ -0,0 +1,215 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// HookEvent is a point of an operation hooks can run at
+type HookEvent string
+
+const (
+	HookPreStart   HookEvent = "pre_start"
+	HookPostStart  HookEvent = "post_start"
+	HookPreStop    HookEvent = "pre_stop"
+	HookPostStop   HookEvent = "post_stop"
+	HookPrePull    HookEvent = "pre_pull"
+	HookPostPull   HookEvent = "post_pull"
+	HookPreUpdate  HookEvent = "pre_update"
+	HookPostUpdate HookEvent = "post_update"
+)
+
+// HookEvents lists every event, in the order of an update
+var HookEvents = []HookEvent{
+	HookPreUpdate, HookPrePull, HookPostPull, HookPostUpdate,
+	HookPreStart, HookPostStart, HookPreStop, HookPostStop,
+}
+
+const (
+	// DefaultHookTimeout bounds a hook without its own timeout
+	DefaultHookTimeout = 10 * time.Minute
+	// maxHookLines is how many output lines of a hook are kept
+	maxHookLines = 20
+)
+
+// Hook is a command run before or after an operation: a shell command on
+// the project's host (in the project directory), or with Service set, a
+// command in that service's container via `compose exec`
+type Hook struct {
+	Command string        // Shell command, or the command run in the container
+	Service string        // Run in this service's container
+	Timeout time.Duration // DefaultHookTimeout if zero
+}
+
+// hookJSON is a Hook in x-dcm-hooks and plan files
+type hookJSON struct {
+	Command string `json:"command"`
+	Service string `json:"service,omitempty"`
+	Timeout string `json:"timeout,omitempty"` // e.g. "30m"
+}
+
+// UnmarshalJSON reads a hook with its timeout as a duration string
+func (h *Hook) UnmarshalJSON(data []byte) error {
+	var raw hookJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*h = Hook{Command: raw.Command, Service: raw.Service}
+	if raw.Timeout != "" {
+		d, err := time.ParseDuration(raw.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid hook timeout %q", raw.Timeout)
+		}
+		h.Timeout = d
+	}
+	return nil
+}
+
+// MarshalJSON writes a hook the way UnmarshalJSON reads it
+func (h Hook) MarshalJSON() ([]byte, error) {
+	raw := hookJSON{Command: h.Command, Service: h.Service}
+	if h.Timeout > 0 {
+		raw.Timeout = h.Timeout.String()
+	}
+	return json.Marshal(raw)
+}
+
+// Hooks are the hooks of a project by event, from the config or the
+// x-dcm-hooks extension of the compose file
+type Hooks map[HookEvent][]Hook
+
+// validate checks event names and commands
+func (h Hooks) validate() error {
+	for event, hooks := range h {
+		known := false
+		for _, e := range HookEvents {
+			known = known || e == event
+		}
+		if !known {
+			return fmt.Errorf("unknown hook event %q", event)
+		}
+		for _, hook := range hooks {
+			if strings.TrimSpace(hook.Command) == "" {
+				return fmt.Errorf("%s: hook without command", event)
+			}
+		}
+	}
+	return nil
+}
+
+// HookError is a failed hook. Pre-hooks abort the operation, a failing
+// post-hook is reported after the operation finished.
+type HookError struct {
+	Event  HookEvent
+	Hook   Hook
+	Err    error
+	Output string // Last line of output
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%s hook failed: %v", e.Event, e.Err)
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// hooksFor returns the hooks of the project for event: from the config if
+// it sets the event, else from x-dcm-hooks of the compose file
+func (p *Project) hooksFor(ctx context.Context, event HookEvent) []Hook {
+	if hooks, ok := p.options().Hooks[p.Name][event]; ok {
+		return hooks
+	}
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil || len(cfg.Hooks) == 0 {
+		return nil // docker-compose v1: config hooks only
+	}
+
+	var hooks Hooks
+	err = json.Unmarshal(cfg.Hooks, &hooks)
+	if err == nil {
+		err = hooks.validate()
+	}
+	if err != nil {
+		warning := fmt.Sprintf("x-dcm-hooks ignored: %v", err)
+		if len(p.HookLog) == 0 || p.HookLog[0] != warning {
+			p.HookLog = append([]string{warning}, p.HookLog...)
+		}
+		return nil
+	}
+	return hooks[event]
+}
+
+// runHooks runs the hooks of the project for event in order, stopping at
+// the first failure. Their output is collected in HookLog.
+func (p *Project) runHooks(ctx context.Context, event HookEvent) error {
+	for _, hook := range p.hooksFor(ctx, event) {
+		if err := p.runHook(ctx, event, hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook runs a single hook with its timeout
+func (p *Project) runHook(ctx context.Context, event HookEvent, hook Hook) error {
+	timeout := DefaultHookTimeout
+	if hook.Timeout > 0 {
+		timeout = hook.Timeout
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env := []string{"DCM_PROJECT=" + p.Name, "DCM_PROJECT_DIR=" + p.Path, "DCM_HOOK=" + string(event)}
+	if hook.Service != "" {
+		args := []string{"exec", "-T"}
+		for _, e := range env {
+			args = append(args, "-e", e)
+		}
+		args = append(args, hook.Service, "sh", "-c", hook.Command)
+		return p.finishHook(ctx, hookCtx, event, hook, p.compose(hookCtx, args...))
+	}
+
+	cmd := p.command(hookCtx, "sh", "-c", hook.Command)
+	p.host.withEnv(cmd, env...)
+	return p.finishHook(ctx, hookCtx, event, hook, cmd)
+}
+
+// finishHook runs the hook command and records its output
+func (p *Project) finishHook(ctx, hookCtx context.Context, event HookEvent, hook Hook, cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxHookLines {
+		lines = lines[len(lines)-maxHookLines:]
+	}
+	for _, line := range lines {
+		p.HookLog = append(p.HookLog, fmt.Sprintf("%s: %s", event, line))
+	}
+
+	if err == nil {
+		return nil
+	}
+	if err := cancelled(ctx, string(event)+" hook"); err != nil {
+		return err
+	}
+	if hookCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out")
+	}
+	last := ""
+	if len(lines) > 0 {
+		last = lines[len(lines)-1]
+	}
+	return &HookError{Event: event, Hook: hook, Err: err, Output: last}
+}
@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHookJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Hook
+		wantErr bool
+	}{
+		{`{"command":"echo hi"}`, Hook{Command: "echo hi"}, false},
+		{`{"command":"pg_dump","service":"db","timeout":"30m"}`, Hook{Command: "pg_dump", Service: "db", Timeout: 30 * time.Minute}, false},
+		{`{"command":"x","timeout":"1h30m"}`, Hook{Command: "x", Timeout: 90 * time.Minute}, false},
+		{`{"command":"x","timeout":"soon"}`, Hook{}, true},
+		{`{"command":"x","timeout":"-5s"}`, Hook{}, true},
+		{`{"command":"x","timeout":30}`, Hook{}, true},
+	}
+	for _, tt := range tests {
+		var got Hook
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+
+		// Written the way it is read
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var again Hook
+		if err := json.Unmarshal(data, &again); err != nil || again != got {
+			t.Errorf("round trip of %+v via %s = %+v, %v", got, data, again, err)
+		}
+	}
+}
+
+func TestHooksValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   Hooks
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"known events", Hooks{HookPreUpdate: {{Command: "a"}}, HookPostStop: {{Command: "b", Service: "web"}}}, false},
+		{"unknown event", Hooks{"before_update": {{Command: "a"}}}, true},
+		{"no command", Hooks{HookPostStart: {{Command: "  "}}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.hooks.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHooksFor(t *testing.T) {
+	fakeDocker(t)
+	config := `{"services":{},"x-dcm-hooks":{"pre_update":[{"command":"compose pre"}],"post_update":[{"command":"compose post","timeout":"1m"}]}}`
+	p := fakeProject(t, "app", []string{"nginx:1.25"}, config)
+	options, err := NewOptions(Options{Hooks: map[string]Hooks{"app": {HookPreUpdate: {{Command: "config pre"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetOptions(options)
+
+	ctx := p.withComposeConfig(context.Background())
+	// The config replaces the compose file's hooks per event
+	if got, want := p.hooksFor(ctx, HookPreUpdate), []Hook{{Command: "config pre"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pre_update hooks %+v, want %+v", got, want)
+	}
+	if got, want := p.hooksFor(ctx, HookPostUpdate), []Hook{{Command: "compose post", Timeout: time.Minute}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("post_update hooks %+v, want %+v", got, want)
+	}
+	if got := p.hooksFor(ctx, HookPreStart); got != nil {
+		t.Errorf("pre_start hooks %+v, want none", got)
+	}
+}
+
+func TestHooksForInvalid(t *testing.T) {
+	fakeDocker(t)
+	p := fakeProject(t, "app", []string{"nginx:1.25"}, `{"services":{},"x-dcm-hooks":{"pre_update":[{"command":"x","timeout":"soon"}]}}`)
+	if got := p.hooksFor(context.Background(), HookPreUpdate); got != nil {
+		t.Errorf("hooks %+v from an invalid x-dcm-hooks", got)
+	}
+	if len(p.HookLog) != 1 {
+		t.Errorf("HookLog %q, want the warning", p.HookLog)
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	p := &Project{Name: "app", Path: t.TempDir()}
+	ctx := context.Background()
+
+	if err := p.runHook(ctx, HookPreStart, Hook{Command: `echo "$DCM_PROJECT $DCM_HOOK"`}); err != nil {
+		t.Fatalf("runHook: %v", err)
+	}
+	if want := []string{"pre_start: app pre_start"}; !reflect.DeepEqual(p.HookLog, want) {
+		t.Errorf("HookLog %q, want %q", p.HookLog, want)
+	}
+
+	err := p.runHook(ctx, HookPreStop, Hook{Command: "echo stopping; exit 3"})
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || hookErr.Event != HookPreStop || hookErr.Output != "stopping" {
+		t.Errorf("failing hook: %v", err)
+	}
+
+	start := time.Now()
+	err = p.runHook(ctx, HookPostPull, Hook{Command: "exec sleep 5", Timeout: 50 * time.Millisecond})
+	if !errors.As(err, &hookErr) || hookErr.Err.Error() != "timed out" {
+		t.Errorf("slow hook: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("timeout not applied, took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,295 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalHost is the name of the local docker daemon
+const LocalHost = "local"
+
+// Host is a docker daemon projects run on. A nil *Host is the local daemon.
+//
+// Hosts reached through a docker context or DOCKER_HOST=tcp://... run the
+// docker CLI locally, so their compose directories are local too. For
+// DOCKER_HOST=ssh://... the compose directories live on the remote machine:
+// discovery and every docker command run there over ssh.
+type Host struct {
+	Name       string // Shown in the TUI and stored with cached projects
+	Context    string // Docker context to use (DOCKER_CONTEXT)
+	DockerHost string // DOCKER_HOST, e.g. tcp://host:2376 or ssh://user@host
+	TLSVerify  bool   // Verify the daemon's certificate (DOCKER_TLS_VERIFY)
+	CertPath   string // Directory with ca.pem, cert.pem and key.pem (DOCKER_CERT_PATH)
+	SearchDir  string // Directory searched for projects (on the remote machine for ssh)
+
+	backend Backend // Compose implementation, see DetectBackend
+}
+
+// HostName returns the name of h, LocalHost for nil
+func (h *Host) HostName() string {
+	if h == nil {
+		return LocalHost
+	}
+	return h.Name
+}
+
+// SSH reports whether commands for h run on another machine over ssh
+func (h *Host) SSH() bool {
+	return h != nil && strings.HasPrefix(h.DockerHost, "ssh://")
+}
+
+// env returns the environment selecting the daemon of h
+func (h *Host) env() []string {
+	if h == nil || h.SSH() {
+		return nil
+	}
+
+	var env []string
+	if h.Context != "" {
+		env = append(env, "DOCKER_CONTEXT="+h.Context)
+	}
+	if h.DockerHost != "" {
+		env = append(env, "DOCKER_HOST="+h.DockerHost)
+	}
+	if h.TLSVerify {
+		env = append(env, "DOCKER_TLS_VERIFY=1")
+	}
+	if h.CertPath != "" {
+		env = append(env, "DOCKER_CERT_PATH="+h.CertPath)
+	}
+	return env
+}
+
+// command creates a command running name with args in dir on h. dir may be
+// empty for commands that don't depend on the working directory. During a
+// dry run, commands changing anything are recorded and replaced by a no-op.
+func (h *Host) command(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	if r := recorderFrom(ctx); r != nil && !readOnly(name, args) {
+		step := PlannedCommand{Dir: dir, Args: append([]string{name}, args...)}
+		if h != nil {
+			step.Host = h.Name
+		}
+		r.record(step)
+		return command(ctx, "true")
+	}
+
+	if !h.SSH() {
+		cmd := command(ctx, name, args...)
+		cmd.Dir = dir
+		if env := h.env(); env != nil {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		return cmd
+	}
+
+	remote := shellQuote(append([]string{name}, args...))
+	if dir != "" {
+		remote = "cd " + shellQuote([]string{dir}) + " && " + remote
+	}
+
+	target, sshArgs := h.sshTarget()
+	// Never prompt for a password, the TUI owns the terminal
+	sshArgs = append(sshArgs, "-o", "BatchMode=yes", target, "--", remote)
+	return command(ctx, "ssh", sshArgs...)
+}
+
+// withEnv adds environment variables to a command created by Host.command
+func (h *Host) withEnv(cmd *exec.Cmd, env ...string) {
+	if !h.SSH() {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, env...)
+		return
+	}
+	// The remote command is the last argument, export the variables first
+	last := len(cmd.Args) - 1
+	cmd.Args[last] = "export " + shellQuote(env) + "; " + cmd.Args[last]
+}
+
+// sshTarget returns the ssh destination of h and the options for its port
+func (h *Host) sshTarget() (string, []string) {
+	u, err := url.Parse(h.DockerHost)
+	if err != nil {
+		return strings.TrimPrefix(h.DockerHost, "ssh://"), nil
+	}
+
+	target := u.Hostname()
+	if u.User != nil {
+		target = u.User.Username() + "@" + target
+	}
+	if port := u.Port(); port != "" {
+		return target, []string{"-p", port}
+	}
+	return target, nil
+}
+
+// shellQuote joins args into a POSIX shell command line
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@") == "" {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// command creates a command for the project, run in its directory on its host
+func (p *Project) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return p.host.command(ctx, p.Path, name, args...)
+}
+
+// HostOf returns the host of the project (nil for the local daemon)
+func (p *Project) HostOf() *Host {
+	return p.host
+}
+
+// SetHost assigns the project to h (nil for the local daemon)
+func (p *Project) SetHost(h *Host) {
+	p.host = h
+	p.Host = ""
+	if h != nil {
+		p.Host = h.Name
+	}
+}
+
+// ID identifies the project across hosts: its path, prefixed with the host
+// name for projects that aren't on the local daemon
+func (p *Project) ID() string {
+	if p.Host == "" {
+		return p.Path
+	}
+	return p.Host + ":" + p.Path
+}
+
+// LocalProjects returns the projects of the local daemon. Projects of other
+// hosts aren't taken from the cache, they are discovered on every start.
+func LocalProjects(projects []*Project) []*Project {
+	var local []*Project
+	for _, p := range projects {
+		if p.Host == "" {
+			local = append(local, p)
+		}
+	}
+	return local
+}
+
+// ProjectHosts returns the hosts of projects, the local daemon (nil) first
+// and the others by name
+func ProjectHosts(projects []*Project) []*Host {
+	seen := make(map[*Host]bool)
+	hosts := []*Host{nil}
+	for _, p := range projects {
+		if p.host != nil && !seen[p.host] {
+			seen[p.host] = true
+			hosts = append(hosts, p.host)
+		}
+	}
+	sort.Slice(hosts[1:], func(i, j int) bool {
+		return hosts[i+1].Name < hosts[j+1].Name
+	})
+	return hosts
+}
+
+// groupByHost splits projects by host, keeping their order
+func groupByHost(projects []*Project) map[*Host][]*Project {
+	groups := make(map[*Host][]*Project)
+	for _, p := range projects {
+		groups[p.host] = append(groups[p.host], p)
+	}
+	return groups
+}
+
+// FindHostProjects discovers the projects of a configured host in its
+// SearchDir. Local directories are searched like FindProjects; on ssh hosts
+// `find` runs on the remote machine, without .dcmignore/.dcmhide support.
+func FindHostProjects(ctx context.Context, h *Host, maxDepth int, exclude []string) (*Discovery, error) {
+	if h.SearchDir == "" {
+		return &Discovery{Directories: map[string]time.Time{}}, nil
+	}
+
+	if !h.SSH() {
+		discovery, err := FindProjects(h.SearchDir, maxDepth, exclude)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.Name, err)
+		}
+		for _, p := range discovery.Projects {
+			p.SetHost(h)
+		}
+		return discovery, nil
+	}
+
+	args := []string{h.SearchDir, "-maxdepth", fmt.Sprint(maxDepth)}
+	// Skip the default excludes like the local walk does
+	args = append(args, "(")
+	for i, pattern := range defaultExcludes {
+		if i > 0 {
+			args = append(args, "-o")
+		}
+		args = append(args, "-name", strings.TrimSuffix(pattern, "/"))
+	}
+	args = append(args, ")", "-prune", "-o", "-type", "f", "(")
+	for i, name := range composeFileNames {
+		if i > 0 {
+			args = append(args, "-o")
+		}
+		args = append(args, "-name", name)
+	}
+	args = append(args, ")", "-print")
+
+	// find fails on unreadable directories but still lists everything else
+	output, err := h.command(ctx, "", "find", args...).Output()
+	if err != nil && len(output) == 0 {
+		if err := cancelled(ctx, "discovery"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: failed to search %s: %w", h.Name, h.SearchDir, err)
+	}
+
+	byDir := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line == "" {
+			continue
+		}
+		// Remote paths always use slashes
+		dir := strings.TrimSuffix(line[:strings.LastIndex(line, "/")+1], "/")
+		byDir[dir] = append(byDir[dir], line)
+	}
+
+	discovery := &Discovery{Directories: map[string]time.Time{}}
+	for dir, files := range byDir {
+		used, others := preferredComposeFile(files)
+		if len(others) > 0 {
+			discovery.Diagnostics = append(discovery.Diagnostics, Diagnostic{
+				Kind:    DiagnosticAmbiguous,
+				Path:    h.Name + ":" + dir,
+				Message: fmt.Sprintf("using %s, ignoring %s", filepath.Base(used), strings.Join(baseNames(others), ", ")),
+			})
+		}
+
+		project := &Project{
+			Name:        filepath.Base(dir),
+			Path:        dir,
+			ComposeFile: used,
+			Status:      "unknown",
+			LastUpdated: time.Now(),
+			Warnings:    ambiguityWarnings(used, others),
+		}
+		project.SetHost(h)
+		discovery.Projects = append(discovery.Projects, project)
+	}
+	sort.Slice(discovery.Projects, func(i, j int) bool {
+		return discovery.Projects[i].ComposeFile < discovery.Projects[j].ComposeFile
+	})
+	return discovery, nil
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"docker", "compose", "ps"}, "docker compose ps"},
+		{[]string{"cd", "/srv/my app"}, "cd '/srv/my app'"},
+		{[]string{"COMPOSE_ANSI=never", "a:b,c@d"}, "COMPOSE_ANSI=never a:b,c@d"},
+		{[]string{""}, "''"},
+		{[]string{"it's"}, `'it'\''s'`},
+		{[]string{"$HOME", "a;b", "`x`"}, `'$HOME' 'a;b' '` + "`x`" + `'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.args); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	args := []string{"plain", "with space", "it's", "$HOME", "a\nb", "*", `back\slash`, ""}
+	output, err := exec.Command("sh", "-c", "printf '%s\\0' "+shellQuote(args)).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("sh got %q, want %q", got, args)
+	}
+}
+
+func TestSSHTarget(t *testing.T) {
+	tests := []struct {
+		dockerHost string
+		target     string
+		opts       []string
+	}{
+		{"ssh://nas", "nas", nil},
+		{"ssh://admin@nas.local", "admin@nas.local", nil},
+		{"ssh://admin@10.0.0.5:2222", "admin@10.0.0.5", []string{"-p", "2222"}},
+	}
+	for _, tt := range tests {
+		h := &Host{Name: "test", DockerHost: tt.dockerHost}
+		target, opts := h.sshTarget()
+		if target != tt.target || !reflect.DeepEqual(opts, tt.opts) {
+			t.Errorf("sshTarget(%s) = %s %v, want %s %v", tt.dockerHost, target, opts, tt.target, tt.opts)
+		}
+	}
+}
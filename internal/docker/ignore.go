@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// IgnoreFileName lists paths to skip during discovery, relative to the
+	// directory it is in (gitignore-like syntax, see Ignorer)
+	IgnoreFileName = ".dcmignore"
+	// HideMarkerName hides the project in the directory containing it
+	HideMarkerName = ".dcmhide"
+)
+
+// defaultExcludes are never searched
+var defaultExcludes = []string{".git/", "node_modules/"}
+
+// ignoreRule is one pattern of an ignore file or the exclude list
+type ignoreRule struct {
+	base     string   // Directory the pattern is relative to
+	segments []string // Pattern split at "/"
+	anchored bool     // Matched against the path relative to base, not just the name
+	dirOnly  bool     // Trailing "/": only matches directories
+}
+
+// Ignorer decides which paths discovery skips. Rules come from the exclude
+// list (relative to the search root) and from .dcmignore files in the
+// searched directories (relative to their directory):
+//
+//	# comment
+//	backups/        directories named backups, at any depth
+//	*.old           names ending in .old, at any depth
+//	/archive        archive directly below the ignore file's directory
+//	stacks/**/test  test anywhere below stacks
+//
+// Patterns without a slash match names at any depth, patterns with a slash
+// are anchored. "**" matches any number of directories. Negation is not
+// supported.
+type Ignorer struct {
+	root   string
+	global []ignoreRule
+
+	mu     sync.Mutex
+	perDir map[string][]ignoreRule // Parsed .dcmignore by directory
+}
+
+// isDefaultExclude reports whether name is skipped by default (not worth a diagnostic)
+func isDefaultExclude(name string) bool {
+	for _, pattern := range defaultExcludes {
+		if strings.TrimSuffix(pattern, "/") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIgnorer creates an Ignorer for the search root with additional exclude patterns
+func NewIgnorer(root string, excludes []string) *Ignorer {
+	ig := &Ignorer{
+		root:   filepath.Clean(root),
+		perDir: make(map[string][]ignoreRule),
+	}
+	for _, pattern := range append(append([]string(nil), defaultExcludes...), excludes...) {
+		if rule, ok := parseIgnoreRule(ig.root, pattern); ok {
+			ig.global = append(ig.global, rule)
+		}
+	}
+	return ig
+}
+
+// Ignored reports whether path (a directory if isDir) is excluded
+func (ig *Ignorer) Ignored(p string, isDir bool) bool {
+	p = filepath.Clean(p)
+	if p == ig.root {
+		return false
+	}
+
+	if matchRules(ig.global, p, isDir) {
+		return true
+	}
+	// .dcmignore files of all directories from the root down to the parent
+	for dir := filepath.Dir(p); ig.inRoot(dir); dir = filepath.Dir(dir) {
+		if matchRules(ig.rules(dir), p, isDir) {
+			return true
+		}
+		if dir == ig.root {
+			break
+		}
+	}
+	return false
+}
+
+// excluded is like Ignored but also checks all directories between the root
+// and path, for callers that don't walk top-down
+func (ig *Ignorer) excluded(p string, isDir bool) bool {
+	p = filepath.Clean(p)
+	for dir := filepath.Dir(p); dir != ig.root && ig.inRoot(dir); dir = filepath.Dir(dir) {
+		if ig.Ignored(dir, true) {
+			return true
+		}
+	}
+	return ig.Ignored(p, isDir)
+}
+
+// inRoot reports whether dir is the search root or below it
+func (ig *Ignorer) inRoot(dir string) bool {
+	_, ok := relativePath(ig.root, dir)
+	return ok
+}
+
+// relativePath returns p relative to base, false if p is not base or below it
+func relativePath(base, p string) (string, bool) {
+	rel, err := filepath.Rel(base, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Hidden reports whether the project in dir is hidden by a marker file
+func (ig *Ignorer) Hidden(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, HideMarkerName))
+	return err == nil
+}
+
+// Reset forgets all parsed .dcmignore files, e.g. after one changed
+func (ig *Ignorer) Reset() {
+	ig.mu.Lock()
+	ig.perDir = make(map[string][]ignoreRule)
+	ig.mu.Unlock()
+}
+
+// rules returns the parsed .dcmignore of dir (nil if there is none)
+func (ig *Ignorer) rules(dir string) []ignoreRule {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	if rules, ok := ig.perDir[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	if f, err := os.Open(filepath.Join(dir, IgnoreFileName)); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+	}
+	ig.perDir[dir] = rules
+	return rules
+}
+
+// parseIgnoreRule parses one line of an ignore file
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	pattern := strings.TrimSpace(line)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(pattern, "/")
+	return rule, true
+}
+
+// matchRules reports whether any rule matches path
+func matchRules(rules []ignoreRule, p string, isDir bool) bool {
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := relativePath(rule.base, p)
+		if !ok || rel == "." {
+			continue
+		}
+
+		if !rule.anchored {
+			if ok, _ := path.Match(rule.segments[0], filepath.Base(p)); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(rule.segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" pattern segment matches zero or more path segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern []string
+		path    []string
+		want    bool
+	}{
+		{[]string{"archive"}, []string{"archive"}, true},
+		{[]string{"archive"}, []string{"archive", "app"}, false},
+		{[]string{"stacks", "*"}, []string{"stacks", "web"}, true},
+		{[]string{"stacks", "**", "test"}, []string{"stacks", "test"}, true},
+		{[]string{"stacks", "**", "test"}, []string{"stacks", "a", "b", "test"}, true},
+		{[]string{"stacks", "**", "test"}, []string{"stacks", "a", "test", "x"}, false},
+		{[]string{"**", "old"}, []string{"x", "old"}, true},
+		{[]string{"stacks"}, []string{"other"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchSegments(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want ignoreRule
+	}{
+		{"", false, ignoreRule{}},
+		{"  # comment", false, ignoreRule{}},
+		{"/", false, ignoreRule{}},
+		{"backups/", true, ignoreRule{base: "/srv", segments: []string{"backups"}, dirOnly: true}},
+		{"*.old", true, ignoreRule{base: "/srv", segments: []string{"*.old"}}},
+		{"/archive", true, ignoreRule{base: "/srv", segments: []string{"archive"}, anchored: true}},
+		{"stacks/**/test/", true, ignoreRule{base: "/srv", segments: []string{"stacks", "**", "test"}, anchored: true, dirOnly: true}},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreRule("/srv", tt.line)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIgnoreRule(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeFiles creates files (with parent directories) below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnorer(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "apps")
+	writeFiles(t, base, map[string]string{
+		"apps/.dcmignore":           "# skipped everywhere\nbackups/\n*.old\n/archive\nstacks/**/test\n",
+		"apps/team/.dcmignore":      "/wip\n",
+		"apps/team/wip/compose.yml": "",
+		"apps2/.dcmignore":          "*\n",
+		"apps2/web/compose.yml":     "",
+	})
+
+	ig := NewIgnorer(root, []string{"tmp/", "/legacy/*"})
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"", true, false}, // The root itself
+		{"web", true, false},
+		{"backups", true, true},
+		{"team/backups", true, true},
+		{"backups", false, false}, // A file, the rule is for directories
+		{"web.old", true, true},
+		{"team/web.old", true, true},
+		{"archive", true, true},
+		{"team/archive", true, false}, // Anchored at the root
+		{"stacks/test", true, true},
+		{"stacks/a/b/test", true, true},
+		{"stacks/a/test2", true, false},
+		{"team/wip", true, true}, // .dcmignore of team
+		{"wip", true, false},
+		{"tmp", true, true}, // Config excludes
+		{"team/tmp", true, true},
+		{"legacy/app", true, true},
+		{"legacy", true, false},
+		{".git", true, true}, // Default excludes
+		{"web/node_modules", true, true},
+	}
+	for _, tt := range tests {
+		if got := ig.Ignored(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Everything below an ignored directory is excluded
+	if !ig.excluded(filepath.Join(root, "backups", "db", "compose.yml"), false) {
+		t.Errorf("excluded: file below an ignored directory not excluded")
+	}
+	if ig.excluded(filepath.Join(root, "web", "compose.yml"), false) {
+		t.Errorf("excluded: web/compose.yml excluded")
+	}
+
+	// A sibling whose name starts with the root's is not below the root,
+	// its .dcmignore doesn't apply
+	sibling := filepath.Join(base, "apps2", "web")
+	if ig.Ignored(sibling, true) || ig.excluded(filepath.Join(sibling, "compose.yml"), false) {
+		t.Errorf("rules of %s applied to a sibling of the root", filepath.Join(base, "apps2"))
+	}
+}
+
+func TestIgnorerReset(t *testing.T) {
+	root := t.TempDir()
+	ig := NewIgnorer(root, nil)
+	if ig.Ignored(filepath.Join(root, "old"), true) {
+		t.Fatal("ignored without rules")
+	}
+	writeFiles(t, root, map[string]string{".dcmignore": "old/\n"})
+	if ig.Ignored(filepath.Join(root, "old"), true) {
+		t.Fatal("parsed .dcmignore not cached")
+	}
+	ig.Reset()
+	if !ig.Ignored(filepath.Join(root, "old"), true) {
+		t.Errorf("new .dcmignore not read after Reset")
+	}
+}
+
+func TestIgnorerHidden(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"hidden/" + HideMarkerName: "",
+		"shown/compose.yml":        "",
+	})
+	ig := NewIgnorer(root, nil)
+	if !ig.Hidden(filepath.Join(root, "hidden")) {
+		t.Errorf("project with %s not hidden", HideMarkerName)
+	}
+	if ig.Hidden(filepath.Join(root, "shown")) {
+		t.Errorf("project without %s hidden", HideMarkerName)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		base, path string
+		want       string
+		ok         bool
+	}{
+		{"/srv/apps", "/srv/apps", ".", true},
+		{"/srv/apps", "/srv/apps/web", "web", true},
+		{"/srv/apps", "/srv/apps2", "", false},
+		{"/srv/apps", "/srv", "", false},
+		{"/srv/apps", "/srv/apps/..data", "..data", true},
+		{"/", "/srv", "srv", true},
+	}
+	for _, tt := range tests {
+		got, ok := relativePath(tt.base, tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relativePath(%q, %q) = %q, %v; want %q, %v", tt.base, tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LockState tells whether the images of a project are pinned to digests
+// (image: name:tag@sha256:...) and whether the containers run them
+type LockState string
+
+const (
+	LockNone    LockState = ""        // No image pinned
+	LockPartial LockState = "partial" // Some images pinned, others follow their tags
+	LockLocked  LockState = "locked"  // All images pinned and the containers run them
+	LockStale   LockState = "stale"   // All images pinned, containers still run other images
+)
+
+// imageLine matches an image: line of a compose file: the key with its
+// indentation, the reference with optional quotes and a trailing comment
+var imageLine = regexp.MustCompile(`^(\s*image:\s*)(["']?)([^"'\s#]+)(["']?)(\s*(?:#.*)?)$`)
+
+// LockedImage is an image: line of the compose file handled by Lock
+type LockedImage struct {
+	Line    int    // Line number in the compose file
+	Old     string // Reference before
+	New     string // Reference written, empty if skipped
+	Skipped string // Why the image wasn't pinned
+}
+
+// Lock pins every image: of the compose file to its registry digest, so
+// updates and restarts always get the same images. Unpinned images are
+// pinned to what the containers run (or the local image if not running);
+// pinned ones are left alone unless update is set, which pulls the tags and
+// pins the new digests. The compose file is only written if a pin changed.
+func (p *Project) Lock(ctx context.Context, update bool) ([]LockedImage, error) {
+	data, err := p.readComposeFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := p.snapshotImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, locked, err := pinImages(data, update, func(name string) (string, error) {
+		return p.lockDigest(ctx, name, snapshot[name], update)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	for _, img := range locked {
+		changed = changed || (img.New != "" && img.New != img.Old)
+	}
+	if !changed {
+		return locked, nil
+	}
+	return locked, p.writeComposeFile(ctx, data)
+}
+
+// pinImages pins the image: lines of a compose file to the digests resolve
+// returns for their names (empty if there is none). Pinned images are kept
+// unless update is set; resolve is called once per name.
+func pinImages(data []byte, update bool, resolve func(name string) (string, error)) ([]byte, []LockedImage, error) {
+	var locked []LockedImage
+	digests := make(map[string]string) // Resolved digests by image name
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		m := imageLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		img := LockedImage{Line: i + 1, Old: m[3]}
+		name, digest, _ := strings.Cut(m[3], "@")
+		switch {
+		case strings.Contains(name, "$"):
+			img.Skipped = "uses variables"
+		case digest != "" && !update:
+			img.New = img.Old
+		default:
+			if _, ok := digests[name]; !ok {
+				digest, err := resolve(name)
+				if err != nil {
+					return nil, nil, err
+				}
+				digests[name] = digest
+			}
+			if digests[name] == "" {
+				img.Skipped = "no registry digest, built locally?"
+				break
+			}
+			img.New = name + "@" + digests[name]
+			lines[i] = m[1] + m[2] + img.New + m[4] + m[5]
+		}
+		locked = append(locked, img)
+	}
+	return []byte(strings.Join(lines, "\n")), locked, nil
+}
+
+// lockDigest resolves the registry digest to pin image name to: the
+// running image (ID, may be empty), else the local image of the tag. With
+// update, or if the tag was never pulled, the tag is pulled first.
+func (p *Project) lockDigest(ctx context.Context, name, running string, update bool) (string, error) {
+	source := running
+	if update || source == "" {
+		source = name
+		_, err := p.host.engine(ctx, "image", "inspect", "--format", "{{.Id}}", name).Output()
+		if update || err != nil {
+			if output, err := p.host.engine(ctx, "pull", "--quiet", name).CombinedOutput(); err != nil {
+				if err := cancelled(ctx, "lock"); err != nil {
+					return "", err
+				}
+				return "", cleanDockerError("pull "+name, output, err)
+			}
+		}
+	}
+	if dryRun(ctx) && source == name {
+		return "<digest of the pulled image>", nil // Nothing was pulled
+	}
+	digest, _, err := repoDigest(ctx, p.host, source, name)
+	return digest, err
+}
+
+// readComposeFile returns the contents of the compose file
+func (p *Project) readComposeFile(ctx context.Context) ([]byte, error) {
+	if !p.host.SSH() {
+		return os.ReadFile(p.ComposeFile)
+	}
+
+	// Reading doesn't change anything, so it runs in dry runs too
+	data, err := p.command(withoutDryRun(ctx), "cat", p.ComposeFile).Output()
+	if err != nil {
+		if err := cancelled(ctx, "lock"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", p.ComposeFile, err)
+	}
+	return data, nil
+}
+
+// writeComposeFile replaces the contents of the compose file
+func (p *Project) writeComposeFile(ctx context.Context, data []byte) error {
+	if p.host.SSH() {
+		cmd := p.command(ctx, "sh", "-c", `cat > "$1"`, "sh", p.ComposeFile)
+		cmd.Stdin = bytes.NewReader(data)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return cleanDockerError("write "+p.ComposeFile, output, err)
+		}
+		return nil
+	}
+
+	if dryRun(ctx) {
+		notef(ctx, "write %s", p.ComposeFile)
+		return nil
+	}
+	info, err := os.Stat(p.ComposeFile)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.ComposeFile, data, info.Mode().Perm())
+}
+
+// lockState returns the lock state of a rendered compose config and the
+// containers of the project
+func lockState(cfg *composeConfig, containers []composeContainer) LockState {
+	pinned, total := 0, 0
+	for _, svc := range cfg.Services {
+		if svc.Image == "" {
+			continue // Built, not pulled
+		}
+		total++
+		if strings.Contains(svc.Image, "@") {
+			pinned++
+		}
+	}
+	switch {
+	case pinned == 0:
+		return LockNone
+	case pinned < total:
+		return LockPartial
+	}
+
+	for _, c := range containers {
+		image := cfg.Services[c.Service].Image
+		if c.label(labelOneOff) != "True" && image != "" && normalizeImageRef(c.Image) != normalizeImageRef(image) {
+			return LockStale
+		}
+	}
+	return LockLocked
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package docker
+
+// lockFile is a no-op on platforms without flock; cache writes are still
+// atomic thanks to write-and-rename
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
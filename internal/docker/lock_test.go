@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPinImages(t *testing.T) {
+	const digestA = "sha256:aaaa"
+	const digestB = "sha256:bbbb"
+
+	tests := []struct {
+		name   string
+		input  string
+		update bool
+		want   string
+		locked []LockedImage
+	}{
+		{
+			name:  "plain",
+			input: "services:\n  web:\n    image: nginx:1.25\n",
+			want:  "services:\n  web:\n    image: nginx:1.25@" + digestA + "\n",
+			locked: []LockedImage{
+				{Line: 3, Old: "nginx:1.25", New: "nginx:1.25@" + digestA},
+			},
+		},
+		{
+			name:  "quotes and comment kept",
+			input: "    image: \"nginx:1.25\"  # web server",
+			want:  "    image: \"nginx:1.25@" + digestA + "\"  # web server",
+			locked: []LockedImage{
+				{Line: 1, Old: "nginx:1.25", New: "nginx:1.25@" + digestA},
+			},
+		},
+		{
+			name:  "pinned kept",
+			input: "    image: nginx:1.25@sha256:old",
+			want:  "    image: nginx:1.25@sha256:old",
+			locked: []LockedImage{
+				{Line: 1, Old: "nginx:1.25@sha256:old", New: "nginx:1.25@sha256:old"},
+			},
+		},
+		{
+			name:   "pinned updated",
+			input:  "    image: 'nginx:1.25@sha256:old'",
+			update: true,
+			want:   "    image: 'nginx:1.25@" + digestA + "'",
+			locked: []LockedImage{
+				{Line: 1, Old: "nginx:1.25@sha256:old", New: "nginx:1.25@" + digestA},
+			},
+		},
+		{
+			name:  "variables skipped",
+			input: "    image: ${REGISTRY}/app:latest",
+			want:  "    image: ${REGISTRY}/app:latest",
+			locked: []LockedImage{
+				{Line: 1, Old: "${REGISTRY}/app:latest", Skipped: "uses variables"},
+			},
+		},
+		{
+			name:  "no digest skipped",
+			input: "    image: local/app",
+			want:  "    image: local/app",
+			locked: []LockedImage{
+				{Line: 1, Old: "local/app", Skipped: "no registry digest, built locally?"},
+			},
+		},
+		{
+			name:  "same name twice",
+			input: "  a:\n    image: redis:7\n  b:\n    image: redis:7",
+			want:  "  a:\n    image: redis:7@" + digestB + "\n  b:\n    image: redis:7@" + digestB,
+			locked: []LockedImage{
+				{Line: 2, Old: "redis:7", New: "redis:7@" + digestB},
+				{Line: 4, Old: "redis:7", New: "redis:7@" + digestB},
+			},
+		},
+		{
+			name:  "other keys untouched",
+			input: "    # image: nginx\n    container_name: image\n",
+			want:  "    # image: nginx\n    container_name: image\n",
+		},
+	}
+
+	digests := map[string]string{"nginx:1.25": digestA, "redis:7": digestB}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := make(map[string]int)
+			got, locked, err := pinImages([]byte(tt.input), tt.update, func(name string) (string, error) {
+				calls[name]++
+				return digests[name], nil
+			})
+			if err != nil {
+				t.Fatalf("pinImages: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("pinImages wrote %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(locked, tt.locked) {
+				t.Errorf("pinImages = %+v, want %+v", locked, tt.locked)
+			}
+			for name, n := range calls {
+				if n > 1 {
+					t.Errorf("%s resolved %d times", name, n)
+				}
+			}
+		})
+	}
+}
+
+func TestPinImagesError(t *testing.T) {
+	errPull := errors.New("pull failed")
+	_, _, err := pinImages([]byte("image: nginx"), false, func(string) (string, error) {
+		return "", errPull
+	})
+	if !errors.Is(err, errPull) {
+		t.Errorf("pinImages error = %v, want %v", err, errPull)
+	}
+}
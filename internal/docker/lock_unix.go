@@ -0,0 +1,39 @@
+//go:build unix
+
+package docker
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory flock on path (created if missing) and returns
+// the function releasing it. exclusive selects a write lock, otherwise a
+// shared read lock is taken. Blocks until the lock is available.
+func lockFile(path string, exclusive bool) (func(), error) {
+	// flock doesn't need write access, so a lock file created by another
+	// user (e.g. root's cron job) can still be locked
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		// Read-only cache directory: reading is still safe without a
+		// lock since writers only ever rename complete files into place
+		if !exclusive && os.IsPermission(err) {
+			return func() {}, nil
+		}
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
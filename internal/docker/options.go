@@ -0,0 +1,64 @@
+package docker
+
+import (
+	"fmt"
+	"path"
+)
+
+// Options are the settings from the config that operations on a project
+// follow. They are given to each project with SetOptions; projects without
+// use the defaults.
+type Options struct {
+	Policies   map[string]UpdatePolicy   // By project name or "project/service", over dcm.update.policy labels
+	Strategies map[string]UpdateStrategy // By project name, over dcm.update.strategy labels
+	Hooks      map[string]Hooks          // By project name, for each event they replace the x-dcm-hooks ones
+	Verify     VerifyOptions
+	Backup     BackupOptions
+}
+
+// defaultOptions are the options of projects without SetOptions
+var defaultOptions, _ = NewOptions(Options{})
+
+// NewOptions checks o and fills in the defaults for zero timeouts and
+// empty backup settings
+func NewOptions(o Options) (*Options, error) {
+	for name, hooks := range o.Hooks {
+		if err := hooks.validate(); err != nil {
+			return nil, fmt.Errorf("hooks: %s: %w", name, err)
+		}
+	}
+
+	if o.Verify.HealthTimeout <= 0 {
+		o.Verify.HealthTimeout = DefaultHealthTimeout
+	}
+	if o.Verify.GracePeriod <= 0 {
+		o.Verify.GracePeriod = DefaultGracePeriod
+	}
+
+	if o.Backup.Dir == "" {
+		o.Backup.Dir = DefaultBackupDir
+	}
+	if !path.IsAbs(o.Backup.Dir) {
+		return nil, fmt.Errorf("backup: dir must be an absolute path: %q", o.Backup.Dir)
+	}
+	if o.Backup.Keep <= 0 {
+		o.Backup.Keep = DefaultBackupKeep
+	}
+	if o.Backup.Image == "" {
+		o.Backup.Image = DefaultBackupImage
+	}
+	return &o, nil
+}
+
+// SetOptions sets the options the project's operations follow
+func (p *Project) SetOptions(o *Options) {
+	p.opts = o
+}
+
+// options returns the options of the project
+func (p *Project) options() *Options {
+	if p.opts == nil {
+		return defaultOptions
+	}
+	return p.opts
+}
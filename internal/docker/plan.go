@@ -0,0 +1,315 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// planVersion is the format version of plan files
+const planVersion = 1
+
+// ErrPlanOutdated is returned by ApplyPlan when the project or the
+// registry changed since the plan was made
+var ErrPlanOutdated = errors.New("plan is outdated")
+
+// planHookEvents are the hook events of an update, in order
+var planHookEvents = []HookEvent{HookPreUpdate, HookPrePull, HookPostPull, HookPostUpdate}
+
+// Plan is a reviewed batch of updates: written by `plan`, executed as is
+// by `apply`
+type Plan struct {
+	Version  int             `json:"version"`
+	Created  time.Time       `json:"created"`
+	Projects []PlannedUpdate `json:"projects"`
+}
+
+// PlannedUpdate is the update of one project in a plan
+type PlannedUpdate struct {
+	Project     string         `json:"project"`
+	Host        string         `json:"host,omitempty"` // Empty for the local daemon
+	ComposeFile string         `json:"compose_file"`
+	Strategy    UpdateStrategy `json:"strategy"`
+	Services    []string       `json:"services,omitempty"` // Services pulled and recreated, empty = all
+	Backup      bool           `json:"backup"`             // Back up volumes before pulling
+	Images      []PlannedImage `json:"images"`
+	Hooks       Hooks          `json:"hooks,omitempty"` // Hooks that will run, by event
+}
+
+// PlannedImage is an image of a planned update
+type PlannedImage struct {
+	Name           string       `json:"name"`
+	Policy         UpdatePolicy `json:"policy"`
+	CurrentVersion string       `json:"current_version"` // Versions as shown in the TUI
+	TargetVersion  string       `json:"target_version"`
+	Current        string       `json:"current,omitempty"` // ID of the image the containers run, empty if stopped
+	Target         string       `json:"target"`            // Registry digest to update to
+}
+
+// LoadPlan reads a plan file
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("plan %s has version %d, expected %d", path, plan.Version, planVersion)
+	}
+	return &plan, nil
+}
+
+// Save writes the plan to path
+func (plan *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// NewPlan plans the updates the last update check found, for every
+// project that has updates and services that may be updated
+func NewPlan(ctx context.Context, projects []*Project) (*Plan, error) {
+	plan := &Plan{Version: planVersion, Created: time.Now()}
+	for _, p := range projects {
+		if !p.HasUpdates {
+			continue
+		}
+		planned, err := p.PlanUpdate(ctx)
+		if err != nil {
+			if err := cancelled(ctx, "plan"); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		plan.Projects = append(plan.Projects, *planned)
+	}
+	return plan, nil
+}
+
+// PlanUpdate describes the update Update would run now. Target digests are
+// resolved by pulling the images; the tags are then pointed back at the
+// images the last update check found, from before its own pull, so neither
+// the check nor planning changes what the containers get on the next start.
+func (p *Project) PlanUpdate(ctx context.Context) (*PlannedUpdate, error) {
+	ctx = p.withComposeConfig(ctx)
+	services, all, err := p.updatableServices(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	images, err := p.plannedImages(ctx, services)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := p.snapshotImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := &PlannedUpdate{
+		Project:     p.Name,
+		Host:        p.Host,
+		ComposeFile: p.ComposeFile,
+		Strategy:    p.UpdateStrategy(ctx),
+		Backup:      p.options().Backup.BeforeUpdate,
+		Hooks:       p.updateHooks(ctx),
+	}
+	if !all {
+		planned.Services = services
+	}
+
+	policyOf := p.imagePolicies(ctx)
+	for _, name := range images {
+		info := p.ImageInfo[name]
+		before := info.CurrentID // From before the check pulled
+		if before == "" {
+			before = localImageID(ctx, p.host, name)
+		}
+		if output, err := p.host.engine(ctx, "pull", "--quiet", name).CombinedOutput(); err != nil {
+			if err := cancelled(ctx, "plan"); err != nil {
+				return nil, err
+			}
+			return nil, cleanDockerError("pull "+name, output, err)
+		}
+		target, err := imageDigest(ctx, p.host, name)
+		if err != nil {
+			return nil, err
+		}
+		if before != "" {
+			p.host.engine(ctx, "tag", before, name).Run()
+		}
+
+		planned.Images = append(planned.Images, PlannedImage{
+			Name:           name,
+			Policy:         policyOf(name),
+			CurrentVersion: info.CurrentVersion,
+			TargetVersion:  info.LatestVersion,
+			Current:        snapshot[name],
+			Target:         target,
+		})
+	}
+	return planned, nil
+}
+
+// ApplyPlan runs a planned update. It refuses with ErrPlanOutdated if the
+// services, hooks or running images of the project, or the images in the
+// registry, are not the ones in the plan; the containers are left alone then.
+func (p *Project) ApplyPlan(ctx context.Context, planned PlannedUpdate) error {
+	ctx = p.withComposeConfig(ctx)
+	services, all, err := p.updatableServices(ctx, false)
+	if err != nil {
+		return err
+	}
+	if all {
+		services = nil
+	}
+	if !reflect.DeepEqual(services, planned.Services) {
+		return fmt.Errorf("%w: services to update are now %s", ErrPlanOutdated, strings.Join(services, ", "))
+	}
+	if hooks := p.updateHooks(ctx); !reflect.DeepEqual(hooks, planned.Hooks) {
+		return fmt.Errorf("%w: hooks changed", ErrPlanOutdated)
+	}
+
+	snapshot, err := p.snapshotImages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, img := range planned.Images {
+		if img.Current != "" && snapshot[img.Name] != img.Current {
+			return fmt.Errorf("%w: %s runs %s instead of %s", ErrPlanOutdated, img.Name, shortDigest(snapshot[img.Name]), shortDigest(img.Current))
+		}
+	}
+
+	// Pull first, so a moved tag is noticed before any hook or backup runs
+	for _, img := range planned.Images {
+		if output, err := p.host.engine(ctx, "pull", "--quiet", img.Name).CombinedOutput(); err != nil {
+			if err := cancelled(ctx, "apply"); err != nil {
+				return err
+			}
+			return cleanDockerError("pull "+img.Name, output, err)
+		}
+	}
+	if err := p.checkPlanTargets(ctx, &planned); err != nil {
+		return err
+	}
+
+	return p.update(ctx, updateOptions{strategy: planned.Strategy, backup: planned.Backup, pulled: true})
+}
+
+// checkPlanTargets checks that the tags point at the planned digests. In a
+// dry run nothing was pulled, so it only notes the check.
+func (p *Project) checkPlanTargets(ctx context.Context, planned *PlannedUpdate) error {
+	for _, img := range planned.Images {
+		if dryRun(ctx) {
+			notef(ctx, "check that %s is %s", img.Name, img.Target)
+			continue
+		}
+		digest, err := imageDigest(ctx, p.host, img.Name)
+		if err != nil {
+			return err
+		}
+		if digest != img.Target {
+			if img.Current != "" {
+				p.host.engine(ctx, "tag", img.Current, img.Name).Run()
+			}
+			return fmt.Errorf("%w: %s is now %s instead of %s", ErrPlanOutdated, img.Name, shortDigest(digest), shortDigest(img.Target))
+		}
+	}
+	return nil
+}
+
+// plannedImages returns the images of services (all if none could be
+// rendered) in name order
+func (p *Project) plannedImages(ctx context.Context, services []string) ([]string, error) {
+	_, byService, err := p.servicePolicies(ctx)
+	if err != nil {
+		return p.GetImages(ctx) // docker-compose v1
+	}
+	seen := make(map[string]bool)
+	var images []string
+	for _, service := range services {
+		if name := byService[service]; name != "" && !seen[name] {
+			seen[name] = true
+			images = append(images, name)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// updateHooks returns the hooks an update runs, by event
+func (p *Project) updateHooks(ctx context.Context) Hooks {
+	hooks := make(Hooks)
+	for _, event := range planHookEvents {
+		if list := p.hooksFor(ctx, event); len(list) > 0 {
+			hooks[event] = list
+		}
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	return hooks
+}
+
+// imageDigest returns the registry digest (sha256:...) the tag ref points
+// at, or the image ID for images that don't come from a registry
+func imageDigest(ctx context.Context, h *Host, ref string) (string, error) {
+	digest, id, err := repoDigest(ctx, h, ref, ref)
+	if digest == "" {
+		return id, err
+	}
+	return digest, err
+}
+
+// repoDigest returns the registry digest of image (a reference or an ID) in
+// the repository of ref, empty if it has none, and the image ID
+func repoDigest(ctx context.Context, h *Host, image, ref string) (digest, id string, err error) {
+	output, err := h.engine(ctx, "image", "inspect", "--format", "{{.Id}}{{range .RepoDigests}} {{.}}{{end}}", image).Output()
+	fields := strings.Fields(string(output))
+	if err != nil || len(fields) == 0 {
+		if err := cancelled(ctx, "inspect"); err != nil {
+			return "", "", err
+		}
+		return "", "", fmt.Errorf("failed to inspect %s", image)
+	}
+
+	repo := imageRepo(ref)
+	for _, d := range fields[1:] {
+		if name, digest, ok := strings.Cut(d, "@"); ok && imageRepo(name) == repo {
+			return digest, fields[0], nil
+		}
+	}
+	return "", fields[0], nil
+}
+
+// imageRepo returns the normalized repository of an image reference,
+// without tag or digest
+func imageRepo(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	ref = normalizeImageRef(ref)
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// shortDigest shortens an image ID or digest for messages
+func shortDigest(id string) string {
+	if id == "" {
+		return "nothing"
+	}
+	if _, hex, ok := strings.Cut(id, ":"); ok && len(hex) > 12 {
+		return hex[:12]
+	}
+	return id
+}
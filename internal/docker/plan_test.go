@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanSaveLoad(t *testing.T) {
+	plan := &Plan{
+		Version: planVersion,
+		Created: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Projects: []PlannedUpdate{{
+			Project:     "app",
+			Host:        "nas",
+			ComposeFile: "/srv/app/docker-compose.yml",
+			Strategy:    StrategyRolling,
+			Services:    []string{"web"},
+			Backup:      true,
+			Images: []PlannedImage{{
+				Name:           "nginx:1.25",
+				Policy:         PolicyPatch,
+				CurrentVersion: "1.25.3",
+				TargetVersion:  "1.25.4",
+				Current:        "sha256:1111111111111111",
+				Target:         "sha256:2222222222222222",
+			}},
+			Hooks: Hooks{HookPreUpdate: {{Command: "echo before", Service: "web", Timeout: 5 * time.Minute}}},
+		}},
+	}
+
+	path := filepath.Join(t.TempDir(), "updates.json")
+	if err := plan.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, plan) {
+		t.Errorf("LoadPlan = %+v, want %+v", loaded, plan)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*.tmp")); len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestLoadPlanVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.json")
+	if err := (&Plan{Version: planVersion + 1}).Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := LoadPlan(path); err == nil {
+		t.Errorf("LoadPlan accepted version %d", planVersion+1)
+	}
+}
+
+func TestImageRepo(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"nginx", "docker.io/library/nginx"},
+		{"nginx:1.25", "docker.io/library/nginx"},
+		{"library/nginx:latest", "docker.io/library/nginx"},
+		{"docker.io/grafana/grafana:10.0", "docker.io/grafana/grafana"},
+		{"ghcr.io/org/app:v1", "ghcr.io/org/app"},
+		{"localhost:5000/app", "localhost:5000/app"},
+		{"localhost:5000/app:dev", "localhost:5000/app"},
+		{"nginx@sha256:abcd", "docker.io/library/nginx"},
+	}
+	for _, tt := range tests {
+		if got := imageRepo(tt.ref); got != tt.want {
+			t.Errorf("imageRepo(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"", "nothing"},
+		{"sha256:0123456789abcdef0123", "0123456789ab"},
+		{"sha256:0123", "sha256:0123"},
+		{"abc", "abc"},
+	}
+	for _, tt := range tests {
+		if got := shortDigest(tt.id); got != tt.want {
+			t.Errorf("shortDigest(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
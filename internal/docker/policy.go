@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UpdatePolicy controls how updates of a service's image are checked and applied
+type UpdatePolicy string
+
+const (
+	PolicyAuto   UpdatePolicy = "auto"        // Checked and updated normally (default)
+	PolicyPatch  UpdatePolicy = "patch-only"  // Only updates within the same major.minor version
+	PolicyNotify UpdatePolicy = "notify-only" // Checked and shown, only updated when selected by hand
+	PolicyPinned UpdatePolicy = "pinned"      // Never checked, pulled or updated
+)
+
+// ParseUpdatePolicy parses a policy from a label or the config.
+// "ignore" is accepted for pinned, empty for auto.
+func ParseUpdatePolicy(s string) (UpdatePolicy, error) {
+	switch UpdatePolicy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", PolicyAuto:
+		return PolicyAuto, nil
+	case PolicyPatch:
+		return PolicyPatch, nil
+	case PolicyNotify:
+		return PolicyNotify, nil
+	case PolicyPinned, "ignore":
+		return PolicyPinned, nil
+	}
+	return "", fmt.Errorf("unknown update policy %q", s)
+}
+
+// ParseUpdatePolicies parses the policies from the config, keyed by project
+// name or "project/service" (see Options.Policies)
+func ParseUpdatePolicies(policies map[string]string) (map[string]UpdatePolicy, error) {
+	parsed := make(map[string]UpdatePolicy, len(policies))
+	for key, value := range policies {
+		policy, err := ParseUpdatePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		parsed[key] = policy
+	}
+	return parsed, nil
+}
+
+// rank orders policies from most permissive to strictest
+func (u UpdatePolicy) rank() int {
+	switch u {
+	case PolicyPatch:
+		return 1
+	case PolicyNotify:
+		return 2
+	case PolicyPinned:
+		return 3
+	}
+	return 0
+}
+
+// policyOf returns the policy of a service of the project: from the config
+// ("project/service", then "project"), then the service's label
+func (p *Project) policyOf(service, label string) UpdatePolicy {
+	if policy, ok := p.options().Policies[p.Name+"/"+service]; ok && service != "" {
+		return policy
+	}
+	if policy, ok := p.options().Policies[p.Name]; ok {
+		return policy
+	}
+	if policy, err := ParseUpdatePolicy(label); err == nil {
+		return policy
+	}
+	return PolicyAuto // Typos in labels don't block updates
+}
+
+// UpdatePolicy returns the most permissive policy of the project's images as
+// of the last update check, or the project's policy from the config.
+// Projects are only pinned as a whole if every image is.
+func (p *Project) UpdatePolicy() UpdatePolicy {
+	if policy, ok := p.options().Policies[p.Name]; ok {
+		return policy
+	}
+
+	policy, found := PolicyAuto, false
+	for _, info := range p.ImageInfo {
+		if info.Policy == "" {
+			return PolicyAuto // Checked before policies existed
+		}
+		if !found || info.Policy.rank() < policy.rank() {
+			policy, found = info.Policy, true
+		}
+	}
+	return policy
+}
+
+// servicePolicies returns the policy of every service of the project by
+// service name, and the image each service uses
+func (p *Project) servicePolicies(ctx context.Context) (map[string]UpdatePolicy, map[string]string, error) {
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policies := make(map[string]UpdatePolicy, len(cfg.Services))
+	images := make(map[string]string, len(cfg.Services))
+	for name, svc := range cfg.Services {
+		policies[name] = p.policyOf(name, svc.Labels[LabelUpdatePolicy])
+		images[name] = svc.Image
+	}
+	return policies, images, nil
+}
+
+// imagePolicies returns the policy for each image of the project. An image
+// used by several services gets the strictest of their policies. Without a
+// rendered config (docker-compose v1) only the config's project policy applies.
+func (p *Project) imagePolicies(ctx context.Context) func(image string) UpdatePolicy {
+	policies, images, err := p.servicePolicies(ctx)
+	if err != nil {
+		policy := p.policyOf("", "")
+		return func(string) UpdatePolicy { return policy }
+	}
+
+	byImage := make(map[string]UpdatePolicy)
+	for service, image := range images {
+		if current, ok := byImage[image]; !ok || policies[service].rank() > current.rank() {
+			byImage[image] = policies[service]
+		}
+	}
+	return func(image string) UpdatePolicy {
+		if policy, ok := byImage[image]; ok {
+			return policy
+		}
+		return PolicyAuto
+	}
+}
+
+// updatableServices returns the services an update may pull: all but pinned
+// ones and patch-only ones whose update was held back, and in unattended
+// mode notify-only ones. all is true if that is every service. It fails if
+// nothing may be updated.
+func (p *Project) updatableServices(ctx context.Context, unattended bool) (services []string, all bool, err error) {
+	policies, images, err := p.servicePolicies(ctx)
+	if err != nil {
+		// docker-compose v1: only the project policy from the config is known
+		switch policy := p.policyOf("", ""); {
+		case policy == PolicyPinned:
+			return nil, false, fmt.Errorf("%s is pinned, updates are disabled", p.Name)
+		case policy == PolicyNotify && unattended:
+			return nil, false, fmt.Errorf("%s is notify-only, it is only updated by hand", p.Name)
+		}
+		return nil, true, nil
+	}
+
+	for service, policy := range policies {
+		if policy == PolicyPinned || p.ImageInfo[images[service]].HeldBack {
+			continue
+		}
+		if policy == PolicyNotify && unattended {
+			continue
+		}
+		services = append(services, service)
+	}
+	if len(services) == 0 {
+		if unattended {
+			return nil, false, fmt.Errorf("%s has no services that may be updated unattended", p.Name)
+		}
+		return nil, false, fmt.Errorf("%s is pinned, updates are disabled", p.Name)
+	}
+	sort.Strings(services)
+	return services, len(services) == len(policies), nil
+}
+
+// holdBackImages points the tags of images whose update may not be applied
+// back at the image the last check compared with, so the next `up` keeps
+// running it: held back patch-only updates and, with notify, notify-only
+// ones. Update checks leave the tags where their pull put them.
+func (p *Project) holdBackImages(ctx context.Context, notify bool) {
+	for name, info := range p.ImageInfo {
+		if info.CurrentID == "" || !(info.HeldBack || (notify && info.HasUpdate && info.Policy == PolicyNotify)) {
+			continue
+		}
+		if localImageID(ctx, p.host, name) != info.CurrentID {
+			p.host.engine(ctx, "tag", info.CurrentID, name).Run()
+		}
+	}
+}
+
+// isPatchUpdate reports whether to differs from from in the patch version
+// only, e.g. 15.3 -> 15.3.1 or 1.2.3 -> 1.2.7. Versions without at least a
+// major and minor number never qualify.
+func isPatchUpdate(from, to string) bool {
+	a, b := versionNumbers(from), versionNumbers(to)
+	return len(a) >= 2 && len(b) >= 2 && a[0] == b[0] && a[1] == b[1]
+}
+
+// versionNumbers returns the leading numeric components of a version,
+// e.g. "v15.3.1-alpine" -> [15 3 1]
+func versionNumbers(version string) []int {
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(part[:end])
+		numbers = append(numbers, n)
+		if end < len(part) {
+			break // Suffix like "-alpine" ends the version
+		}
+	}
+	return numbers
+}
+
+// imageVersion returns the version of an image (by reference or ID): its
+// OCI version label, or what getRealVersion finds
+func imageVersion(ctx context.Context, h *Host, ref, tag string) string {
+	output, err := h.engine(ctx, "image", "inspect", ref, "--format",
+		`{{index .Config.Labels "org.opencontainers.image.version"}}`).Output()
+	if version := strings.TrimSpace(string(output)); err == nil && version != "" && version != "<no value>" {
+		return version
+	}
+	return getRealVersion(ctx, h, ref, tag)
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVersionNumbers(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []int
+	}{
+		{"15", []int{15}},
+		{"15.3", []int{15, 3}},
+		{"v15.3.1-alpine", []int{15, 3, 1}},
+		{"V2.0", []int{2, 0}},
+		{"1.2.3.4", []int{1, 2, 3, 4}},
+		{"1.2rc1.3", []int{1, 2}},
+		{"1.x", []int{1}},
+		{"latest", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := versionNumbers(tt.version); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("versionNumbers(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestIsPatchUpdate(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"15.3", "15.3.1", true},
+		{"1.2.3", "1.2.7", true},
+		{"v1.2.3", "1.2.4-alpine", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.3.0", false},
+		{"1.2.3", "2.0.0", false},
+		{"15", "15.1", false},
+		{"latest", "latest", false},
+		{"1.2.3", "", false},
+	}
+	for _, tt := range tests {
+		if got := isPatchUpdate(tt.from, tt.to); got != tt.want {
+			t.Errorf("isPatchUpdate(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseUpdatePolicy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    UpdatePolicy
+		wantErr bool
+	}{
+		{"", PolicyAuto, false},
+		{"auto", PolicyAuto, false},
+		{" Patch-Only ", PolicyPatch, false},
+		{"notify-only", PolicyNotify, false},
+		{"pinned", PolicyPinned, false},
+		{"ignore", PolicyPinned, false},
+		{"sometimes", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUpdatePolicy(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseUpdatePolicy(%q) = %q, %v, want %q, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicyOf(t *testing.T) {
+	options, err := NewOptions(Options{Policies: map[string]UpdatePolicy{
+		"app":    PolicyNotify,
+		"app/db": PolicyPinned,
+		"web/db": PolicyPatch,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		project, service, label string
+		want                    UpdatePolicy
+	}{
+		{"app", "db", "auto", PolicyPinned},    // Service from the config first
+		{"app", "cache", "auto", PolicyNotify}, // Then the project from the config
+		{"web", "db", "", PolicyPatch},
+		{"web", "cache", "pinned", PolicyPinned}, // Then the label
+		{"web", "cache", "typo", PolicyAuto},
+		{"other", "", "", PolicyAuto},
+	}
+	for _, tt := range tests {
+		p := &Project{Name: tt.project}
+		p.SetOptions(options)
+		if got := p.policyOf(tt.service, tt.label); got != tt.want {
+			t.Errorf("policyOf(%s/%s, %q) = %q, want %q", tt.project, tt.service, tt.label, got, tt.want)
+		}
+	}
+}
@@ -3,20 +3,25 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
 // ImageInfo stores version information for an image
 type ImageInfo struct {
-	Name           string `json:"name"`
-	CurrentVersion string `json:"current_version"` // Current local image ID
-	LatestVersion  string `json:"latest_version"`  // Latest available image ID
-	HasUpdate      bool   `json:"has_update"`
+	Name           string       `json:"name"`
+	CurrentVersion string       `json:"current_version"` // Current local image ID
+	LatestVersion  string       `json:"latest_version"`  // Latest available image ID
+	HasUpdate      bool         `json:"has_update"`
+	CheckedAt      time.Time    `json:"checked_at"`          // When the registry was last checked (zero if never)
+	Policy         UpdatePolicy `json:"policy,omitempty"`    // Update policy of the services using the image
+	HeldBack       bool         `json:"held_back,omitempty"` // patch-only: LatestVersion is a minor/major update, not applied
+	CurrentID      string       `json:"current_id,omitempty"` // Image the check compared with: the running one, else the local one
 }
 
 // Project represents a Docker Compose project
@@ -30,6 +35,19 @@ type Project struct {
 	ImageInfo         map[string]ImageInfo  `json:"image_info"` // Map of image name to version info
 	HasUpdates        bool                  `json:"has_updates"`
 	LastUpdated       time.Time             `json:"last_updated"`
+	ComposeModTime    time.Time             `json:"compose_mod_time"` // Compose file mtime at discovery
+	ComposeChanged    bool                  `json:"compose_changed"`  // Compose file is newer than the containers
+	Drift             []ServiceDrift        `json:"-"`                // Services differing from the compose file (see CheckDrift)
+	DriftChecked      bool                  `json:"-"`                // Drift is known (not cached, always checked live)
+	Unhealthy         []string              `json:"-"`                // Services whose healthcheck fails (live, see CollectStatuses)
+	LockState         LockState             `json:"-"`                // Whether the images are pinned to digests (see CheckDrift)
+	Warnings          []string              `json:"warnings,omitempty"` // Discovery problems, e.g. ignored compose files
+	Host              string                `json:"host,omitempty"`     // Name of the host, empty for the local daemon
+	HookLog           []string              `json:"-"`                  // Output of the hooks of the last operation
+	UpdateWarning     string                `json:"-"`                  // Problems of the last update, which still succeeded (e.g. a failed post_update hook)
+
+	host *Host    // Daemon the project runs on (nil = local), see SetHost
+	opts *Options // Settings from the config, see SetOptions
 }
 
 // IsRunning checks if the project has running containers
@@ -37,6 +55,21 @@ func (p *Project) IsRunning() bool {
 	return strings.HasPrefix(p.Status, "running:")
 }
 
+// OldestCheck returns when the least recently checked image was checked
+// for updates (zero if any image has never been checked)
+func (p *Project) OldestCheck() time.Time {
+	var oldest time.Time
+	for _, info := range p.ImageInfo {
+		if info.CheckedAt.IsZero() {
+			return time.Time{}
+		}
+		if oldest.IsZero() || info.CheckedAt.Before(oldest) {
+			oldest = info.CheckedAt
+		}
+	}
+	return oldest
+}
+
 // StatusDisplay returns a human-readable status
 func (p *Project) StatusDisplay() string {
 	if p.IsRunning() {
@@ -45,80 +78,17 @@ func (p *Project) StatusDisplay() string {
 	return "Stopped"
 }
 
-// FindProjects searches for docker-compose projects in a directory
-func FindProjects(searchDir string, maxDepth int) ([]*Project, error) {
-	var projects []*Project
-
-	err := filepath.Walk(searchDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip directories we can't access
-		}
-
-		// Check depth
-		relPath, _ := filepath.Rel(searchDir, path)
-		depth := len(strings.Split(relPath, string(os.PathSeparator)))
-		if depth > maxDepth {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Look for docker-compose files (support both old and new naming)
-		if !info.IsDir() {
-			name := info.Name()
-			if name == "docker-compose.yml" || name == "docker-compose.yaml" || name == "compose.yml" || name == "compose.yaml" {
-				// Skip control files
-				if strings.Contains(name, "control") {
-					return nil
-				}
-
-				projectDir := filepath.Dir(path)
-				projectName := filepath.Base(projectDir)
-
-				project := &Project{
-					Name:        projectName,
-					Path:        projectDir,
-					ComposeFile: path,
-					LastUpdated: time.Now(),
-				}
-
-				// Get container status
-				if err := project.UpdateStatus(); err == nil {
-					projects = append(projects, project)
-				}
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to search for projects: %w", err)
-	}
-
-	if len(projects) == 0 {
-		return nil, fmt.Errorf("no docker-compose projects found in %s", searchDir)
-	}
-
-	return projects, nil
-}
-
 // UpdateStatus updates the container status for this project
-func (p *Project) UpdateStatus() error {
-	cmd := exec.Command("docker", "compose", "ps", "--quiet")
-	cmd.Dir = p.Path
+func (p *Project) UpdateStatus(ctx context.Context) error {
+	cmd := p.compose(ctx, "ps", "--quiet")
 	output, err := cmd.Output()
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "ps", "--quiet")
-		cmd.Dir = p.Path
-		output, err = cmd.Output()
-		if err != nil {
-			p.Status = "stopped"
-			p.RunningContainers = 0
-			return nil
+		if err := cancelled(ctx, "status"); err != nil {
+			return err
 		}
+		p.Status = "stopped"
+		p.RunningContainers = 0
+		return nil
 	}
 
 	// Count running containers
@@ -126,17 +96,13 @@ func (p *Project) UpdateStatus() error {
 	if len(lines) == 1 && lines[0] == "" {
 		p.Status = "stopped"
 		p.RunningContainers = 0
+		p.ComposeChanged = false
 		return nil
 	}
+	p.checkComposeChanged(ctx, lines)
 
-	cmd = exec.Command("docker", "compose", "ps", "--services", "--filter", "status=running")
-	cmd.Dir = p.Path
-	output, err = cmd.Output()
-	if err != nil {
-		cmd = exec.Command("docker-compose", "ps", "--services", "--filter", "status=running")
-		cmd.Dir = p.Path
-		output, _ = cmd.Output()
-	}
+	cmd = p.compose(ctx, "ps", "--services", "--filter", "status=running")
+	output, _ = cmd.Output()
 
 	running := 0
 	lines = strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -154,78 +120,129 @@ func (p *Project) UpdateStatus() error {
 	return nil
 }
 
-// Start starts the containers
-func (p *Project) Start() error {
-	cmd := exec.Command("docker", "compose", "up", "-d")
-	cmd.Dir = p.Path
-	output, err := cmd.CombinedOutput()
+// checkComposeChanged flags the project if its compose file was modified
+// after the oldest of the given containers was created, i.e. the running
+// containers don't reflect the current configuration
+func (p *Project) checkComposeChanged(ctx context.Context, containerIDs []string) {
+	if p.host.SSH() {
+		return // The compose file is on another machine
+	}
+	info, err := os.Stat(p.ComposeFile)
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "up", "-d")
-		cmd.Dir = p.Path
-		output, err = cmd.CombinedOutput()
+		return
+	}
+
+	args := append([]string{"inspect", "--format", "{{.Created}}"}, containerIDs...)
+	output, err := p.host.engine(ctx, args...).Output()
+	if err != nil {
+		return
+	}
+
+	changed := false
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		created, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(line))
 		if err != nil {
-			return fmt.Errorf("failed to start: %s", string(output))
+			// podman prints Go's default time format
+			created, err = time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", strings.TrimSpace(line))
+		}
+		if err == nil && info.ModTime().After(created) {
+			changed = true
+			break
 		}
 	}
+	p.ComposeChanged = changed
+}
+
+// Start starts the containers, running the pre_start and post_start hooks
+func (p *Project) Start(ctx context.Context) error {
+	ctx = p.withComposeConfig(ctx)
+	p.HookLog = nil
+	if err := p.runHooks(ctx, HookPreStart); err != nil {
+		return err
+	}
+
+	p.holdBackImages(ctx, true)
+	cmd := p.compose(ctx, "up", "-d")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if err := cancelled(ctx, "start"); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to start: %s", string(output))
+	}
 
 	// Update status
-	return p.UpdateStatus()
+	if err := p.UpdateStatus(ctx); err != nil {
+		return err
+	}
+	return p.runHooks(ctx, HookPostStart)
 }
 
-// Stop stops the containers
-func (p *Project) Stop() error {
-	cmd := exec.Command("docker", "compose", "down")
-	cmd.Dir = p.Path
+// Stop stops the containers, running the pre_stop and post_stop hooks
+func (p *Project) Stop(ctx context.Context) error {
+	ctx = p.withComposeConfig(ctx)
+	p.HookLog = nil
+	if err := p.runHooks(ctx, HookPreStop); err != nil {
+		return err
+	}
+
+	cmd := p.compose(ctx, "down")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "down")
-		cmd.Dir = p.Path
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("failed to stop: %s", string(output))
+		if err := cancelled(ctx, "stop"); err != nil {
+			return err
 		}
+		return fmt.Errorf("failed to stop: %s", string(output))
 	}
 
 	// Update status
-	p.Status = "stopped"
-	p.RunningContainers = 0
-	return nil
+	if !dryRun(ctx) {
+		p.Status = "stopped"
+		p.RunningContainers = 0
+	}
+	return p.runHooks(ctx, HookPostStop)
 }
 
 // Restart restarts the containers
-func (p *Project) Restart() error {
-	cmd := exec.Command("docker", "compose", "restart")
-	cmd.Dir = p.Path
+func (p *Project) Restart(ctx context.Context) error {
+	cmd := p.compose(ctx, "restart")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "restart")
-		cmd.Dir = p.Path
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("failed to restart: %s", string(output))
+		if err := cancelled(ctx, "restart"); err != nil {
+			return err
 		}
+		return fmt.Errorf("failed to restart: %s", string(output))
 	}
 
 	// Update status
-	return p.UpdateStatus()
+	return p.UpdateStatus(ctx)
 }
 
 // GetImages returns the list of images used by this project
-func (p *Project) GetImages() ([]string, error) {
-	cmd := exec.Command("docker", "compose", "config", "--images")
-	cmd.Dir = p.Path
+func (p *Project) GetImages(ctx context.Context) ([]string, error) {
+	images, err := p.composeImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.Images = images
+	return images, nil
+}
+
+// composeImages lists the images of the compose file without changing p
+func (p *Project) composeImages(ctx context.Context) ([]string, error) {
+	cmd := p.compose(ctx, "config", "--images")
 	output, err := cmd.Output()
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "config", "--images")
-		cmd.Dir = p.Path
-		output, err = cmd.Output()
-		if err != nil {
+		if err := cancelled(ctx, "config"); err != nil {
 			return nil, err
 		}
+		// Output keeps stderr in the exit error
+		var stderr []byte
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr = exitErr.Stderr
+		}
+		return nil, cleanDockerError("config", stderr, err)
 	}
 
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -235,8 +252,6 @@ func (p *Project) GetImages() ([]string, error) {
 			images = append(images, line)
 		}
 	}
-
-	p.Images = images
 	return images, nil
 }
 
@@ -300,7 +315,8 @@ func parseVersionFromOutput(output string, tagVersion string) string {
 }
 
 // getRealVersion attempts to get the actual version for images tagged as "latest" or similar
-func getRealVersion(imageName string, tagVersion string) string {
+// (running the image on host h)
+func getRealVersion(ctx context.Context, h *Host, imageName string, tagVersion string) string {
 	// Check if tag is generic (exact match or starts with a generic prefix)
 	genericPrefixes := []string{"latest", "stable", "edge", "main", "master", "production", "nightly", "dev", "rc", "develop"}
 	isGeneric := false
@@ -365,17 +381,17 @@ func getRealVersion(imageName string, tagVersion string) string {
 	// Try image-specific commands if available
 	if cmds, ok := imageSpecificCommands[imageBaseName]; ok {
 		for _, cfg := range cmds {
-			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			cmdCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 			var cmd *exec.Cmd
 			if cfg.entrypoint == "" {
 				// Use empty entrypoint
 				args := append([]string{"run", "--rm", "--entrypoint=", imageName}, cfg.args...)
-				cmd = exec.CommandContext(ctx, "docker", args...)
+				cmd = h.engine(cmdCtx, args...)
 			} else {
 				// Use default entrypoint
 				args := append([]string{"run", "--rm", imageName}, cfg.args...)
-				cmd = exec.CommandContext(ctx, "docker", args...)
+				cmd = h.engine(cmdCtx, args...)
 			}
 
 			output, err := cmd.CombinedOutput() // Use CombinedOutput to capture stderr too
@@ -399,8 +415,8 @@ func getRealVersion(imageName string, tagVersion string) string {
 	}
 
 	for _, cmdArgs := range versionCommands {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		cmd := exec.CommandContext(ctx, "docker", append([]string{"run", "--rm", imageName}, cmdArgs...)...)
+		cmdCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		cmd := h.engine(cmdCtx, append([]string{"run", "--rm", imageName}, cmdArgs...)...)
 		output, err := cmd.Output()
 		cancel()
 
@@ -413,7 +429,7 @@ func getRealVersion(imageName string, tagVersion string) string {
 	}
 
 	// Fallback: Try to get version from image labels
-	cmd := exec.Command("docker", "image", "inspect", imageName, "--format", "{{json .Config.Labels}}")
+	cmd := h.engine(ctx, "image", "inspect", imageName, "--format", "{{json .Config.Labels}}")
 	output, err := cmd.Output()
 	if err == nil {
 		var labels map[string]string
@@ -446,14 +462,14 @@ func min(a, b int) int {
 
 // GetRunningContainerInfo gets version info from currently running containers
 // This is used for display purposes when ImageInfo cache is not available
-func (p *Project) GetRunningContainerInfo() error {
+func (p *Project) GetRunningContainerInfo(ctx context.Context) error {
 	if p.ImageInfo == nil {
 		p.ImageInfo = make(map[string]ImageInfo)
 	}
 
 	// Get running containers for this project using docker ps
 	// Format: container_name, image
-	cmd := exec.Command("docker", "ps", "--filter", fmt.Sprintf("label=com.docker.compose.project=%s", p.Name), "--format", "{{.Image}}")
+	cmd := p.host.engine(ctx, "ps", "--filter", fmt.Sprintf("label=com.docker.compose.project=%s", p.Name), "--format", "{{.Image}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return err
@@ -476,7 +492,7 @@ func (p *Project) GetRunningContainerInfo() error {
 		}
 
 		// Try to get real version for generic tags
-		currentVersion := getRealVersion(imageName, tagVersion)
+		currentVersion := getRealVersion(ctx, p.host, imageName, tagVersion)
 
 		// Store in ImageInfo (without checking for updates)
 		p.ImageInfo[imageName] = ImageInfo{
@@ -490,21 +506,65 @@ func (p *Project) GetRunningContainerInfo() error {
 	return nil
 }
 
-// UpdateImageInfo updates the image version information for this project
-func (p *Project) UpdateImageInfo() error {
-	if p.ImageInfo == nil {
-		p.ImageInfo = make(map[string]ImageInfo)
+// CheckResult is the outcome of the update check of a project. Checks run
+// on worker goroutines and don't touch the project; the goroutine owning it
+// applies the result with ApplyCheck.
+type CheckResult struct {
+	images     []string
+	imageInfo  map[string]ImageInfo
+	hasUpdates bool
+	complete   bool // false if the check stopped early, HasUpdates is kept then
+}
+
+// ApplyCheck stores the result of an update check in p. A nil result
+// (the images couldn't be listed) changes nothing.
+func (p *Project) ApplyCheck(r *CheckResult) {
+	if r == nil {
+		return
 	}
+	p.Images = r.images
+	p.ImageInfo = r.imageInfo
+	if r.complete {
+		p.HasUpdates = r.hasUpdates
+	}
+}
 
+// UpdateImageInfo updates the image version information for this project
+func (p *Project) UpdateImageInfo(ctx context.Context) error {
+	result, err := p.checkImages(ctx, copyImageInfo(p.ImageInfo), nil, 0)
+	p.ApplyCheck(result)
+	return err
+}
+
+// checkImages checks all images of the project for updates, starting from
+// the results of the last check in imageInfo, which it takes over and
+// updates (pass a copy of the project's). acquire (optional) is
+// called before each registry pull and returns the function releasing the
+// pull slot again. Images checked less than maxAge ago keep their result
+// (0 = check everything). Stopping early returns what was checked so far.
+func (p *Project) checkImages(ctx context.Context, imageInfo map[string]ImageInfo, acquire func(imageName string) func(), maxAge time.Duration) (*CheckResult, error) {
+	ctx = p.withComposeConfig(ctx)
 	// Get images from compose file
-	images, err := p.GetImages()
+	images, err := p.composeImages(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	if imageInfo == nil {
+		imageInfo = make(map[string]ImageInfo)
+	}
+	result := &CheckResult{images: images, imageInfo: imageInfo}
+
 	hasUpdates := false
+	policyOf := p.imagePolicies(ctx)
+	running, _ := p.snapshotImages(ctx) // Not running if it fails
 
 	for _, imageName := range images {
+		if err := cancelled(ctx, "update check"); err != nil {
+			return result, err
+		}
+		policy := policyOf(imageName)
+
 		// Extract tag from image name (e.g., "postgres:15" -> "15")
 		currentTag := "latest"
 		if strings.Contains(imageName, ":") {
@@ -512,18 +572,40 @@ func (p *Project) UpdateImageInfo() error {
 			currentTag = parts[len(parts)-1]
 		}
 
-		// Get current local image ID (to compare if update available)
-		cmd := exec.Command("docker", "images", imageName, "--format", "{{.ID}}")
-		output, err := cmd.Output()
-		currentID := strings.TrimSpace(string(output))
+		// Pinned images are never pulled, not even to check
+		if policy == PolicyPinned {
+			current := imageInfo[imageName].CurrentVersion
+			if current == "" {
+				current = currentTag
+			}
+			imageInfo[imageName] = ImageInfo{
+				Name:           imageName,
+				CurrentVersion: current,
+				LatestVersion:  "pinned",
+				CheckedAt:      time.Now(),
+				Policy:         policy,
+			}
+			continue
+		}
+
+		if info, ok := imageInfo[imageName]; ok && info.Policy == policy && maxAge > 0 && !info.CheckedAt.IsZero() && time.Since(info.CheckedAt) < maxAge {
+			if info.HasUpdate {
+				hasUpdates = true
+			}
+			continue
+		}
 
-		if err != nil || currentID == "" {
+		// Get current image ID (to compare if update available)
+		currentID := p.checkedImageID(ctx, running, imageName, imageInfo[imageName].CurrentID)
+		if currentID == "" {
 			// Image not pulled yet
-			p.ImageInfo[imageName] = ImageInfo{
+			imageInfo[imageName] = ImageInfo{
 				Name:           imageName,
-				CurrentVersion: getRealVersion(imageName, currentTag),
+				CurrentVersion: getRealVersion(ctx, p.host, imageName, currentTag),
 				LatestVersion:  "not pulled",
 				HasUpdate:      true,
+				CheckedAt:      time.Now(),
+				Policy:         policy,
 			}
 			hasUpdates = true
 			continue
@@ -531,26 +613,33 @@ func (p *Project) UpdateImageInfo() error {
 
 		// Get latest image ID from registry (pull with timeout)
 		// Use a 2-minute timeout per image to prevent hanging
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
+		release := func() {}
+		if acquire != nil {
+			release = acquire(imageName)
+		}
+		pullCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 
-		cmd = exec.CommandContext(ctx, "docker", "pull", "--quiet", imageName)
-		cmd.Dir = p.Path
-		output, err = cmd.CombinedOutput()
+		err := p.host.engine(pullCtx, "pull", "--quiet", imageName).Run()
+		cancel()
+		release()
+		if err := cancelled(ctx, "update check"); err != nil {
+			return result, err
+		}
 		latestID := ""
 
 		if err == nil {
 			// Get the ID of the pulled image
-			cmd = exec.Command("docker", "images", imageName, "--format", "{{.ID}}")
-			output, _ = cmd.Output()
-			latestID = strings.TrimSpace(string(output))
-		} else if ctx.Err() == context.DeadlineExceeded {
+			latestID = localImageID(ctx, p.host, imageName)
+		} else if pullCtx.Err() == context.DeadlineExceeded {
 			// Timeout occurred
-			p.ImageInfo[imageName] = ImageInfo{
+			imageInfo[imageName] = ImageInfo{
 				Name:           imageName,
-				CurrentVersion: getRealVersion(imageName, currentTag),
+				CurrentVersion: getRealVersion(ctx, p.host, imageName, currentTag),
 				LatestVersion:  "timeout",
 				HasUpdate:      false,
+				CheckedAt:      time.Now(),
+				Policy:         policy,
+				CurrentID:      currentID,
 			}
 			continue
 		}
@@ -558,28 +647,76 @@ func (p *Project) UpdateImageInfo() error {
 		hasUpdate := currentID != latestID && latestID != ""
 
 		// Get real versions for generic tags (latest, stable, etc.)
-		currentVersion := getRealVersion(imageName, currentTag)
+		currentVersion := getRealVersion(ctx, p.host, imageName, currentTag)
 		latestVersion := currentVersion
 
 		if hasUpdate {
 			// Try to get real version of the newly pulled (latest) image
-			latestVersion = getRealVersion(imageName, currentTag)
+			latestVersion = getRealVersion(ctx, p.host, imageName, currentTag)
 		}
 
-		p.ImageInfo[imageName] = ImageInfo{
+		heldBack := false
+		if hasUpdate && policy == PolicyPatch {
+			// The pull moved the tag, the old image is still there by ID
+			currentVersion = imageVersion(ctx, p.host, currentID, currentTag)
+			latestVersion = imageVersion(ctx, p.host, imageName, currentTag)
+			heldBack = !isPatchUpdate(currentVersion, latestVersion)
+		}
+		// The tag is left on the pulled image, holdBackImages points it at
+		// CurrentID again before anything recreates containers
+
+		imageInfo[imageName] = ImageInfo{
 			Name:           imageName,
 			CurrentVersion: currentVersion,
 			LatestVersion:  latestVersion,
-			HasUpdate:      hasUpdate,
+			HasUpdate:      hasUpdate && !heldBack,
+			CheckedAt:      time.Now(),
+			Policy:         policy,
+			HeldBack:       heldBack,
+			CurrentID:      currentID,
 		}
+		hasUpdate = hasUpdate && !heldBack
 
 		if hasUpdate {
 			hasUpdates = true
 		}
 	}
 
-	p.HasUpdates = hasUpdates
-	return nil
+	result.hasUpdates = hasUpdates
+	result.complete = true
+	return result, nil
+}
+
+// copyImageInfo returns a copy of the image info of a project
+func copyImageInfo(imageInfo map[string]ImageInfo) map[string]ImageInfo {
+	cp := make(map[string]ImageInfo, len(imageInfo))
+	for name, info := range imageInfo {
+		cp[name] = info
+	}
+	return cp
+}
+
+// checkedImageID returns the ID of the image an update check compares the
+// registry with: the one the containers run, else the one the last check
+// compared with (a pull may have moved the tag since), else the local image.
+// Empty if there is none.
+func (p *Project) checkedImageID(ctx context.Context, running imageSnapshot, imageName, last string) string {
+	if id := running[imageName]; id != "" {
+		return id
+	}
+	if last != "" && p.host.engine(ctx, "image", "inspect", last).Run() == nil {
+		return last
+	}
+	return localImageID(ctx, p.host, imageName)
+}
+
+// localImageID returns the ID of a local image, empty if it doesn't exist
+func localImageID(ctx context.Context, h *Host, ref string) string {
+	output, err := h.engine(ctx, "image", "inspect", "--format", "{{.Id}}", ref).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
 }
 
 // CheckForUpdates checks if updates are available for this project (lightweight)
@@ -587,79 +724,188 @@ func (p *Project) CheckForUpdates() (bool, error) {
 	return p.HasUpdates, nil
 }
 
-// PullOnly pulls latest images without restarting containers
-func (p *Project) PullOnly() error {
-	// Pull latest images
-	cmd := exec.Command("docker", "compose", "pull", "--quiet")
-	cmd.Dir = p.Path
-	cmd.Stdin = nil // Prevent docker from detecting TTY
-	cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0") // Disable ANSI and buildkit output
-	output, err := cmd.CombinedOutput()
+// PullOnly pulls latest images without restarting containers. Images of
+// pinned services, and held back patch-only ones, are not pulled.
+func (p *Project) PullOnly(ctx context.Context) error {
+	ctx = p.withComposeConfig(ctx)
+	services, _, err := p.updatableServices(ctx, false)
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "pull", "--quiet")
-		cmd.Dir = p.Path
-		cmd.Stdin = nil
-		cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0")
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return cleanDockerError("pull", output, err)
-		}
+		return err
+	}
+	p.HookLog = nil
+	p.noteImageChanges(ctx, false)
+	if err := p.pull(ctx, services); err != nil {
+		return err
 	}
 
 	// Update status
-	return p.UpdateStatus()
+	if err := p.UpdateStatus(ctx); err != nil {
+		return err
+	}
+	return p.runHooks(ctx, HookPostPull)
 }
 
-// Update performs a pull and recreate for this project
-func (p *Project) Update() error {
+// pull runs the pre_pull hooks and pulls the images of services (nil = all).
+// The post_pull hooks are left to the caller.
+func (p *Project) pull(ctx context.Context, services []string) error {
+	if err := p.runHooks(ctx, HookPrePull); err != nil {
+		return err
+	}
+
 	// Pull latest images
-	cmd := exec.Command("docker", "compose", "pull", "--quiet")
-	cmd.Dir = p.Path
+	cmd := p.compose(ctx, append([]string{"pull", "--quiet"}, services...)...)
 	cmd.Stdin = nil // Prevent docker from detecting TTY
-	cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0") // Disable ANSI and buildkit output
+	p.host.withEnv(cmd, "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0") // Disable ANSI and buildkit output
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "pull", "--quiet")
-		cmd.Dir = p.Path
-		cmd.Stdin = nil
-		cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0")
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return cleanDockerError("pull", output, err)
+		if err := cancelled(ctx, "pull"); err != nil {
+			return err
 		}
+		return cleanDockerError("pull", output, err)
 	}
+	return nil
+}
 
-	// Remove orphaned containers first (prevents KeyError: 'ContainerConfig')
-	cmd = exec.Command("docker", "compose", "down", "--remove-orphans")
-	cmd.Dir = p.Path
-	cmd.Stdin = nil
-	cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never")
-	cmd.Run() // Ignore errors, this is cleanup
+// Update performs a pull and recreate for this project with the project's
+// update strategy. If update policies exclude some services, only the
+// others are pulled and recreated. With verification enabled (see
+// VerifyOptions) the result is checked and rolled back on failure.
+func (p *Project) Update(ctx context.Context) error {
+	return p.update(ctx, updateOptions{})
+}
 
-	// Recreate containers with new images
-	cmd = exec.Command("docker", "compose", "up", "-d", "--force-recreate", "--remove-orphans")
-	cmd.Dir = p.Path
-	cmd.Stdin = nil // Prevent docker from detecting TTY
-	cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0") // Disable ANSI and buildkit output
-	output, err = cmd.CombinedOutput()
+// AutoUpdate is Update for unattended runs (daemon mode): notify-only
+// services are left alone too, they are only updated by hand. Volumes are
+// backed up first if the project's BackupOptions.BeforeUpdate is set.
+func (p *Project) AutoUpdate(ctx context.Context) error {
+	return p.update(ctx, updateOptions{unattended: true, backup: p.options().Backup.BeforeUpdate})
+}
+
+// updateOptions are the settings of a single update
+type updateOptions struct {
+	unattended bool              // Leave notify-only services alone
+	strategy   UpdateStrategy    // Empty: the project's strategy
+	backup     bool              // Back up volumes after the pre_update hooks
+	phase      func(UpdateState) // Called when the backup or verification starts
+	pulled     bool              // The images were pulled already, only run the pre_pull hooks
+}
+
+// update pulls and recreates the services the policies allow
+func (p *Project) update(ctx context.Context, opts updateOptions) error {
+	ctx = p.withComposeConfig(ctx)
+	services, all, err := p.updatableServices(ctx, opts.unattended)
 	if err != nil {
-		// Try docker-compose (v1)
-		cmd = exec.Command("docker-compose", "up", "-d", "--force-recreate", "--remove-orphans")
-		cmd.Dir = p.Path
-		cmd.Stdin = nil
-		cmd.Env = append(os.Environ(), "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0")
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return cleanDockerError("recreate", output, err)
+		return err
+	}
+	strategy := opts.strategy
+	if strategy == "" {
+		strategy = p.UpdateStrategy(ctx)
+	}
+
+	var snapshot imageSnapshot
+	if p.options().Verify.Enabled && p.options().Verify.Rollback {
+		if snapshot, err = p.snapshotImages(ctx); err != nil {
+			return err
+		}
+	}
+
+	p.HookLog = nil
+	p.UpdateWarning = ""
+	p.noteImageChanges(ctx, opts.unattended)
+	if err := p.runHooks(ctx, HookPreUpdate); err != nil {
+		return err
+	}
+	if opts.backup {
+		if opts.phase != nil {
+			opts.phase(UpdateBackingUp)
+		}
+		if _, err := p.Backup(ctx); err != nil && err != ErrNothingToBackUp {
+			return fmt.Errorf("backup failed, not updated: %w", err)
+		}
+	}
+	if opts.pulled {
+		err = p.runHooks(ctx, HookPrePull)
+	} else {
+		err = p.pull(ctx, services)
+	}
+	if err != nil {
+		return err
+	}
+	p.holdBackImages(ctx, opts.unattended)
+	if err := p.runHooks(ctx, HookPostPull); err != nil {
+		return err // Nothing recreated yet, the containers still run the old images
+	}
+
+	recreated, err := p.recreate(ctx, strategy, services, all)
+	if err != nil {
+		if ctx.Err() != nil || snapshot == nil || (recreated != nil && len(recreated) == 0) {
+			return err // Nothing recreated yet
+		}
+		// Containers may be half recreated, go back to the old images
+		rbErr := &RollbackError{Err: err}
+		if rbErr.RollbackErr = p.rollback(ctx, recreated, snapshot); rbErr.RollbackErr == nil {
+			rbErr.RolledBack = true
+		}
+		p.UpdateStatus(ctx)
+		return rbErr
+	}
+
+	if p.options().Verify.Enabled && (recreated == nil || len(recreated) > 0) {
+		if opts.phase != nil {
+			opts.phase(UpdateVerifying)
+		}
+		if err := p.verifyUpdate(ctx, recreated, snapshot); err != nil {
+			return err
 		}
 	}
 
 	// Update status
-	p.HasUpdates = false
-	p.LastUpdated = time.Now()
-	return p.UpdateStatus()
+	if !dryRun(ctx) {
+		p.HasUpdates = false
+		p.LastUpdated = time.Now()
+	}
+	if err := p.UpdateStatus(ctx); err != nil {
+		return err
+	}
+	// The containers run the new images, a failing hook doesn't undo that
+	if err := p.runHooks(ctx, HookPostUpdate); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		p.warn(err.Error())
+	}
+	return nil
+}
+
+// warn adds a problem of the running update to UpdateWarning
+func (p *Project) warn(warning string) {
+	if p.UpdateWarning != "" {
+		warning = p.UpdateWarning + "; " + warning
+	}
+	p.UpdateWarning = warning
+}
+
+// noteImageChanges records in a dry run which images the last update check
+// found updates for; unattended leaves out notify-only images
+func (p *Project) noteImageChanges(ctx context.Context, unattended bool) {
+	if !dryRun(ctx) {
+		return
+	}
+	names := make([]string, 0, len(p.ImageInfo))
+	for name, info := range p.ImageInfo {
+		if info.HasUpdate && info.Policy != PolicyPinned && !(unattended && info.Policy == PolicyNotify) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		notef(ctx, "no image updates known from the last check")
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := p.ImageInfo[name]
+		notef(ctx, "image %s: %s -> %s", name, info.CurrentVersion, info.LatestVersion)
+	}
 }
 
 // cleanDockerError cleans up docker error messages for display
@@ -750,42 +996,3 @@ func cleanDockerError(operation string, output []byte, err error) error {
 	}
 	return fmt.Errorf("%s failed: %v", operation, err)
 }
-
-// SaveToCache saves projects to cache file
-func SaveToCache(projects []*Project, cacheFile string) error {
-	data, err := json.MarshalIndent(projects, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal projects: %w", err)
-	}
-
-	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
-	}
-
-	return nil
-}
-
-// LoadFromCache loads projects from cache file
-func LoadFromCache(cacheFile string, maxAge time.Duration) ([]*Project, error) {
-	info, err := os.Stat(cacheFile)
-	if err != nil {
-		return nil, fmt.Errorf("cache file not found")
-	}
-
-	// Check if cache is too old
-	if time.Since(info.ModTime()) > maxAge {
-		return nil, fmt.Errorf("cache expired")
-	}
-
-	data, err := os.ReadFile(cacheFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read cache: %w", err)
-	}
-
-	var projects []*Project
-	if err := json.Unmarshal(data, &projects); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal cache: %w", err)
-	}
-
-	return projects, nil
-}
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultMaxParallelUpdates is the number of projects updated at the same time
+	DefaultMaxParallelUpdates = 3
+	// DefaultUpdatePriority is used for projects without a dcm.update.priority label
+	DefaultUpdatePriority = 100
+)
+
+// UpdateState is the state of a scheduled update
+type UpdateState int
+
+const (
+	UpdateQueued  UpdateState = iota // Waiting for a free slot
+	UpdateRunning                    // Pull/recreate in progress
+	UpdateBackingUp                  // Backing up volumes before the pull (see UpdateJob.Backup)
+	UpdateVerifying                  // Checking the new containers (see VerifyOptions)
+	UpdateDone                       // Finished (see Err)
+)
+
+// UpdateJob is a single project update handled by the UpdateScheduler
+type UpdateJob struct {
+	Index    int            // Caller-defined index, reported back in events
+	Project  *Project       // Project to update
+	Restart  bool           // Recreate containers after pulling (false = pull only)
+	Strategy UpdateStrategy // How to recreate them, empty for the project's strategy
+	Backup   bool           // Back up volumes before pulling (only with Restart)
+}
+
+// UpdateEvent reports progress of a scheduled update
+type UpdateEvent struct {
+	Index    int              // Index of the job
+	Project  *Project         // Project the event belongs to
+	State    UpdateState      // New state of the job
+	Position int              // Position in the queue (only set for UpdateQueued)
+	Priority int              // Resolved priority (only set for UpdateQueued)
+	Err      error            // Error of the update (only set for UpdateDone)
+	Plan     []PlannedCommand // Commands of a dry run (only set for UpdateDone)
+}
+
+// UpdateScheduler runs project updates with a limited number of parallel jobs.
+// Jobs are dispatched ordered by priority; in serial mode they run strictly
+// one after another in that order.
+type UpdateScheduler struct {
+	MaxParallel int  // Max number of updates running at the same time
+	Serial      bool // Run one update at a time, ordered by priority
+	DryRun      bool // Record the commands of each update instead of running them
+
+	// run runs a job, UpdateJob.run if nil (replaced in tests)
+	run func(ctx context.Context, job UpdateJob, phase func(UpdateState)) error
+}
+
+// NewUpdateScheduler creates a scheduler, falling back to the default for maxParallel <= 0
+func NewUpdateScheduler(maxParallel int, serial bool) *UpdateScheduler {
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallelUpdates
+	}
+	return &UpdateScheduler{
+		MaxParallel: maxParallel,
+		Serial:      serial,
+	}
+}
+
+// Run executes all jobs and streams their state changes.
+// The returned channel is closed once every job is done. After ctx is
+// cancelled, running jobs are interrupted and queued jobs finish with
+// the cancellation error without being started.
+func (s *UpdateScheduler) Run(ctx context.Context, jobs []UpdateJob) <-chan UpdateEvent {
+	// At most five events per job, workers never block on a slow reader
+	events := make(chan UpdateEvent, len(jobs)*5)
+	run := s.run
+	if run == nil {
+		run = func(ctx context.Context, job UpdateJob, phase func(UpdateState)) error {
+			return job.run(ctx, phase)
+		}
+	}
+
+	workers := s.MaxParallel
+	if s.Serial {
+		workers = 1
+	}
+
+	go func() {
+		// Resolving priorities renders every compose file, so do it here
+		// instead of blocking the caller
+		priorities := make(map[int]int, len(jobs))
+		ordered := make([]UpdateJob, len(jobs))
+		copy(ordered, jobs)
+		for _, job := range ordered {
+			priorities[job.Index] = job.Project.UpdatePriority(ctx)
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return priorities[ordered[i].Index] < priorities[ordered[j].Index]
+		})
+
+		for pos, job := range ordered {
+			events <- UpdateEvent{
+				Index:    job.Index,
+				Project:  job.Project,
+				State:    UpdateQueued,
+				Position: pos + 1,
+				Priority: priorities[job.Index],
+			}
+		}
+
+		queue := make(chan UpdateJob)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range queue {
+					if err := cancelled(ctx, "update"); err != nil {
+						events <- UpdateEvent{Index: job.Index, Project: job.Project, State: UpdateDone, Err: err}
+						continue
+					}
+					events <- UpdateEvent{Index: job.Index, Project: job.Project, State: UpdateRunning}
+					jobCtx, recorder := ctx, (*Recorder)(nil)
+					if s.DryRun {
+						recorder = &Recorder{}
+						jobCtx = WithDryRun(ctx, recorder)
+					}
+					err := run(jobCtx, job, func(state UpdateState) {
+						events <- UpdateEvent{Index: job.Index, Project: job.Project, State: state}
+					})
+					done := UpdateEvent{Index: job.Index, Project: job.Project, State: UpdateDone, Err: err}
+					if recorder != nil {
+						done.Plan = recorder.Steps()
+					}
+					events <- done
+				}
+			}()
+		}
+
+		for _, job := range ordered {
+			queue <- job
+		}
+		close(queue)
+		wg.Wait()
+		close(events)
+	}()
+
+	return events
+}
+
+// run performs the update described by the job, calling phase when the
+// backup or the check of the new containers starts
+func (j UpdateJob) run(ctx context.Context, phase func(UpdateState)) error {
+	if j.Restart {
+		return j.Project.update(ctx, updateOptions{strategy: j.Strategy, backup: j.Backup, phase: phase})
+	}
+	return j.Project.PullOnly(ctx)
+}
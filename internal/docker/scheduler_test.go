@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// priorityConfig is a compose config with the given dcm.update.priority
+func priorityConfig(priority int) string {
+	return fmt.Sprintf(`{"services":{"web":{"image":"nginx:1.25","labels":{%q:"%d"}}}}`, LabelUpdatePriority, priority)
+}
+
+// schedulerJobs returns jobs of projects with the given priorities
+// (negative: no label)
+func schedulerJobs(t *testing.T, priorities ...int) []UpdateJob {
+	jobs := make([]UpdateJob, len(priorities))
+	for i, priority := range priorities {
+		config := ""
+		if priority >= 0 {
+			config = priorityConfig(priority)
+		}
+		jobs[i] = UpdateJob{Index: i, Project: fakeProject(t, fmt.Sprintf("app%d", i), []string{"nginx:1.25"}, config), Restart: true}
+	}
+	return jobs
+}
+
+func TestUpdateSchedulerParallel(t *testing.T) {
+	fakeDocker(t)
+	tests := []struct {
+		maxParallel int
+		serial      bool
+		want        int // Max updates at the same time
+	}{
+		{1, false, 1},
+		{3, false, 3},
+		{4, true, 1},
+	}
+	for _, tt := range tests {
+		var c concurrency
+		s := NewUpdateScheduler(tt.maxParallel, tt.serial)
+		s.run = func(ctx context.Context, job UpdateJob, phase func(UpdateState)) error {
+			defer c.enter()()
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+
+		done := 0
+		for ev := range s.Run(context.Background(), schedulerJobs(t, 5, 5, 5, 5, 5, 5, 5, 5)) {
+			if ev.State == UpdateDone {
+				done++
+			}
+		}
+		max, _ := c.stats()
+		if done != 8 || max != tt.want {
+			t.Errorf("max %d, serial %v: %d done, %d at the same time; want 8, %d", tt.maxParallel, tt.serial, done, max, tt.want)
+		}
+	}
+}
+
+func TestUpdateSchedulerPriority(t *testing.T) {
+	fakeDocker(t)
+	s := NewUpdateScheduler(3, true)
+	s.run = func(context.Context, UpdateJob, func(UpdateState)) error { return nil }
+
+	// No label is DefaultUpdatePriority, equal priorities keep their order
+	jobs := schedulerJobs(t, 200, -1, 10, 50, 10)
+	var queued, running []int
+	positions := make(map[int]int)
+	for ev := range s.Run(context.Background(), jobs) {
+		switch ev.State {
+		case UpdateQueued:
+			queued = append(queued, ev.Priority)
+			positions[ev.Index] = ev.Position
+		case UpdateRunning:
+			running = append(running, ev.Index)
+		}
+	}
+
+	if want := []int{10, 10, 50, DefaultUpdatePriority, 200}; !reflect.DeepEqual(queued, want) {
+		t.Errorf("queued priorities %v, want %v", queued, want)
+	}
+	if want := []int{2, 4, 3, 1, 0}; !reflect.DeepEqual(running, want) {
+		t.Errorf("run order %v, want %v", running, want)
+	}
+	if want := map[int]int{2: 1, 4: 2, 3: 3, 1: 4, 0: 5}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("queue positions %v, want %v", positions, want)
+	}
+}
+
+func TestUpdateSchedulerCancel(t *testing.T) {
+	fakeDocker(t)
+	jobs := schedulerJobs(t, 1, 2, 3, 4, 5, 6)
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewUpdateScheduler(2, false)
+	s.run = func(ctx context.Context, job UpdateJob, phase func(UpdateState)) error {
+		<-ctx.Done()
+		return cancelled(ctx, "update")
+	}
+
+	events := s.Run(ctx, jobs)
+	started, errs := 0, 0
+	timeout := time.After(5 * time.Second)
+	for open := true; open; {
+		select {
+		case ev, ok := <-events:
+			open = ok
+			if !ok {
+				break
+			}
+			switch ev.State {
+			case UpdateRunning:
+				if started++; started == 2 {
+					cancel()
+				}
+			case UpdateDone:
+				if errors.Is(ev.Err, context.Canceled) {
+					errs++
+				}
+			}
+		case <-timeout:
+			t.Fatal("event channel not closed after cancel")
+		}
+	}
+	if started != 2 || errs != len(jobs) {
+		t.Errorf("%d started, %d cancelled; want 2 and all %d", started, errs, len(jobs))
+	}
+	waitGoroutines(t, before)
+}
+
+func TestUpdateSchedulerUnreadEvents(t *testing.T) {
+	fakeDocker(t)
+	jobs := schedulerJobs(t, 1, 2, 3, 4, 5, 6)
+	before := runtime.NumGoroutine()
+
+	var c concurrency
+	s := NewUpdateScheduler(3, false)
+	s.run = func(ctx context.Context, job UpdateJob, phase func(UpdateState)) error {
+		defer c.enter()()
+		phase(UpdateBackingUp)
+		phase(UpdateVerifying)
+		return nil
+	}
+
+	// Nobody reads until every job finished: the buffer holds all events
+	events := s.Run(context.Background(), jobs)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, finished := c.stats(); finished == len(jobs) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("updates blocked on unread events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitGoroutines(t, before)
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != 5*len(jobs) {
+		t.Errorf("%d events, want %d", n, 5*len(jobs))
+	}
+}
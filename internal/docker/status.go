@@ -0,0 +1,268 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compose labels used to assign containers to projects
+const (
+	labelProject    = "com.docker.compose.project"
+	labelWorkingDir = "com.docker.compose.project.working_dir"
+	labelService    = "com.docker.compose.service"
+)
+
+// psTimeLayout is the format of CreatedAt in `docker ps` output
+const psTimeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// ProjectStatus is the live container state of a project
+type ProjectStatus struct {
+	Status            string   // "stopped" or "running:N"
+	RunningContainers int      // Number of running services
+	ComposeChanged    bool     // Compose file is newer than the oldest container
+	Unhealthy         []string // Services with a failing healthcheck
+}
+
+// psContainer is a container as listed by `ps`, parsed by the backend
+type psContainer struct {
+	ID      string
+	Names   string
+	State   string // e.g. "running", "exited"
+	Status  string // e.g. "Up 2 hours (unhealthy)"
+	Created time.Time
+	Labels  labelSet
+}
+
+// label returns the value of a label of the container
+func (c psContainer) label(key string) string {
+	return c.Labels[key]
+}
+
+// ApplyStatus sets the status fields of the project
+func (p *Project) ApplyStatus(s ProjectStatus) {
+	p.Status = s.Status
+	p.RunningContainers = s.RunningContainers
+	p.ComposeChanged = s.ComposeChanged
+	p.Unhealthy = s.Unhealthy
+}
+
+// CollectStatuses returns the status of all projects by ID without
+// modifying them. All compose containers of a host are listed with a single
+// `docker ps` call and assigned to projects by their working_dir label
+// (or the project name for containers without one). If that fails, every
+// project of the host is queried on its own like UpdateStatus does.
+func CollectStatuses(ctx context.Context, projects []*Project) map[string]ProjectStatus {
+	statuses := make(map[string]ProjectStatus, len(projects))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for h, group := range groupByHost(projects) {
+		wg.Add(1)
+		go func(h *Host, group []*Project) {
+			defer wg.Done()
+			result, err := collectStatusesBatched(ctx, h, group)
+			if err != nil {
+				result = collectStatusesPerProject(ctx, group)
+			}
+			mu.Lock()
+			for id, s := range result {
+				statuses[id] = s
+			}
+			mu.Unlock()
+		}(h, group)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// RefreshStatuses queries the live container status of all projects
+func RefreshStatuses(ctx context.Context, projects []*Project) {
+	statuses := CollectStatuses(ctx, projects)
+	for _, p := range projects {
+		if s, ok := statuses[p.ID()]; ok {
+			p.ApplyStatus(s)
+		}
+	}
+}
+
+// listComposeContainers lists all containers created by compose on h with one `ps -a`
+func listComposeContainers(ctx context.Context, h *Host) ([]psContainer, error) {
+	b := h.Backend()
+	output, err := h.engine(ctx, b.listArgs()...).Output()
+	if err != nil {
+		if err := cancelled(ctx, "status"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	all, err := b.parseContainers(output)
+	if err != nil {
+		return nil, err
+	}
+	containers := all[:0]
+	for _, c := range all {
+		if c.label(labelOneOff) == "True" {
+			continue // `compose run` containers
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// projectMatcher assigns compose containers to known projects
+type projectMatcher struct {
+	byDir  map[string]*Project
+	byName map[string]*Project
+}
+
+// newProjectMatcher indexes projects of one host by directory and compose
+// project name. Containers record the absolute directory compose ran in;
+// discovery may have reached the project through a symlink, so real paths
+// match too (only for local directories).
+func newProjectMatcher(projects []*Project) projectMatcher {
+	m := projectMatcher{
+		byDir:  make(map[string]*Project, len(projects)),
+		byName: make(map[string]*Project, len(projects)),
+	}
+	for _, p := range projects {
+		m.byDir[p.Path] = p
+		m.byName[composeProjectName(p.Name)] = p
+		if p.host.SSH() {
+			continue
+		}
+		if abs, err := filepath.Abs(p.Path); err == nil {
+			m.byDir[abs] = p
+		}
+		if real, err := filepath.EvalSymlinks(p.Path); err == nil {
+			if abs, err := filepath.Abs(real); err == nil {
+				m.byDir[abs] = p
+			}
+		}
+	}
+	return m
+}
+
+// match returns the project a container belongs to, nil if none. Containers
+// without a working_dir label (old compose versions) match by project name.
+func (m projectMatcher) match(c psContainer) *Project {
+	if dir := c.label(labelWorkingDir); dir != "" {
+		return m.byDir[dir]
+	}
+	return m.byName[c.label(labelProject)]
+}
+
+// collectStatusesBatched derives the statuses of all projects on h from one `docker ps -a`
+func collectStatusesBatched(ctx context.Context, h *Host, projects []*Project) (map[string]ProjectStatus, error) {
+	containers, err := listComposeContainers(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newProjectMatcher(projects)
+
+	type projectContainers struct {
+		running   map[string]bool // Services with a running container
+		unhealthy map[string]bool // Services with an unhealthy container
+		oldest    time.Time       // Creation time of the oldest container
+	}
+	found := make(map[*Project]*projectContainers)
+
+	for _, c := range containers {
+		p := matcher.match(c)
+		if p == nil {
+			continue // Not one of ours
+		}
+
+		pc := found[p]
+		if pc == nil {
+			pc = &projectContainers{running: make(map[string]bool), unhealthy: make(map[string]bool)}
+			found[p] = pc
+		}
+		if c.State == "running" {
+			pc.running[c.label(labelService)] = true
+			if strings.Contains(c.Status, "(unhealthy)") {
+				pc.unhealthy[c.label(labelService)] = true
+			}
+		}
+		if !c.Created.IsZero() && (pc.oldest.IsZero() || c.Created.Before(pc.oldest)) {
+			pc.oldest = c.Created
+		}
+	}
+
+	statuses := make(map[string]ProjectStatus, len(projects))
+	for _, p := range projects {
+		s := ProjectStatus{Status: "stopped"}
+		if pc := found[p]; pc != nil {
+			s.RunningContainers = len(pc.running)
+			if s.RunningContainers > 0 {
+				s.Status = fmt.Sprintf("running:%d", s.RunningContainers)
+				for service := range pc.unhealthy {
+					s.Unhealthy = append(s.Unhealthy, service)
+				}
+				sort.Strings(s.Unhealthy)
+
+				if info, err := os.Stat(p.ComposeFile); err == nil && !pc.oldest.IsZero() && !h.SSH() {
+					// docker ps only has second precision
+					s.ComposeChanged = info.ModTime().Truncate(time.Second).After(pc.oldest)
+				}
+			}
+		}
+		statuses[p.ID()] = s
+	}
+	return statuses, nil
+}
+
+// collectStatusesPerProject runs UpdateStatus on copies of the projects with
+// a bounded worker pool
+func collectStatusesPerProject(ctx context.Context, projects []*Project) map[string]ProjectStatus {
+	statuses := make(map[string]ProjectStatus, len(projects))
+	jobs := make(chan *Project)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < statusWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				cp := *p
+				if err := cp.UpdateStatus(ctx); err != nil {
+					continue
+				}
+				mu.Lock()
+				statuses[p.ID()] = ProjectStatus{
+					Status:            cp.Status,
+					RunningContainers: cp.RunningContainers,
+					ComposeChanged:    cp.ComposeChanged,
+					Unhealthy:         cp.Unhealthy, // Not known without the batch, keep the last result
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, p := range projects {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	return statuses
+}
+
+// composeProjectName normalizes a directory name the way compose derives the
+// default project name from it (lowercase, only a-z, 0-9, "-" and "_")
+func composeProjectName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// psLine returns a `docker ps --format '{{json .}}'` line of a compose container
+func psLine(project, dir, service, state, status, created string) string {
+	labels := fmt.Sprintf("%s=%s,%s=%s,%s=%s", labelProject, project, labelService, service, labelWorkingDir, dir)
+	if dir == "" {
+		labels = fmt.Sprintf("%s=%s,%s=%s", labelProject, project, labelService, service)
+	}
+	return fmt.Sprintf(`{"ID":"%s-%s","Names":"%s-%s-1","State":"%s","Status":"%s","CreatedAt":"%s","Labels":"%s"}`,
+		project, service, project, service, state, status, created, labels)
+}
+
+func TestParseContainerLines(t *testing.T) {
+	output := strings.Join([]string{
+		psLine("web", "/srv/web", "app", "running", "Up 2 hours (unhealthy)", "2024-01-02 10:00:00 +0000 UTC"),
+		// nerdctl has no State column
+		`{"ID":"abc","Names":"db-1","Status":"Up 5 minutes","CreatedAt":"bad","Labels":"` + labelProject + `=db,com.example.files=a.yml,b.yml"}`,
+		`{"ID":"def","Names":"db-2","Status":"Exited (0) 1 minute ago","Labels":""}`,
+	}, "\n") + "\n"
+
+	containers, err := parseContainerLines([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 3 {
+		t.Fatalf("got %d containers, want 3", len(containers))
+	}
+	web := containers[0]
+	if web.State != "running" || web.label(labelWorkingDir) != "/srv/web" || web.label(labelService) != "app" {
+		t.Errorf("web: %+v", web)
+	}
+	if want := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC); !web.Created.Equal(want) {
+		t.Errorf("web created %v, want %v", web.Created, want)
+	}
+	db := containers[1]
+	if db.State != "running" || !db.Created.IsZero() {
+		t.Errorf("db: state %q, created %v", db.State, db.Created)
+	}
+	if got := db.label("com.example.files"); got != "a.yml,b.yml" {
+		t.Errorf("label with a comma = %q", got)
+	}
+	if containers[2].State != "" {
+		t.Errorf("exited container state %q", containers[2].State)
+	}
+
+	if containers, err := parseContainerLines(nil); err != nil || len(containers) != 0 {
+		t.Errorf("empty output: %v, %v", containers, err)
+	}
+	if _, err := parseContainerLines([]byte("[{}]\n")); err == nil {
+		t.Errorf("JSON array accepted as lines")
+	}
+}
+
+func TestPodmanParseContainers(t *testing.T) {
+	output := `[
+  {"Id":"abc","Names":["web-app-1"],"State":"running","Status":"Up 2 hours","Created":1704189600,
+   "Labels":{"` + labelProject + `":"web","` + labelWorkingDir + `":"/srv/web","` + labelService + `":"app"}},
+  {"Id":"def","Names":["a","b"],"State":"exited","Created":0,"Labels":null}
+]`
+	containers, err := podmanCompose{}.parseContainers([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []psContainer{
+		{ID: "abc", Names: "web-app-1", State: "running", Status: "Up 2 hours", Created: time.Unix(1704189600, 0),
+			Labels: labelSet{labelProject: "web", labelWorkingDir: "/srv/web", labelService: "app"}},
+		{ID: "def", Names: "a,b", State: "exited", Created: time.Unix(0, 0)},
+	}
+	if !reflect.DeepEqual(containers, want) {
+		t.Errorf("got %+v\nwant %+v", containers, want)
+	}
+
+	if containers, err := (podmanCompose{}).parseContainers([]byte("\n")); err != nil || containers != nil {
+		t.Errorf("empty output: %v, %v", containers, err)
+	}
+	// docker's JSON lines are not podman's array
+	if _, err := (podmanCompose{}).parseContainers([]byte(psLine("web", "/srv/web", "app", "running", "Up", "") + "\n")); err == nil {
+		t.Errorf("JSON lines accepted as array")
+	}
+}
+
+func TestProjectMatcher(t *testing.T) {
+	base := t.TempDir()
+	real := filepath.Join(base, "real")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	web := &Project{Name: "web", Path: "/srv/web"}
+	linked := &Project{Name: "linked", Path: link}
+	old := &Project{Name: "My.App", Path: "/srv/My.App"}
+	m := newProjectMatcher([]*Project{web, linked, old})
+
+	tests := []struct {
+		name   string
+		labels labelSet
+		want   *Project
+	}{
+		{"working dir", labelSet{labelProject: "renamed", labelWorkingDir: "/srv/web"}, web},
+		{"real path of a symlink", labelSet{labelProject: "linked", labelWorkingDir: real}, linked},
+		{"other dir with our name", labelSet{labelProject: "web", labelWorkingDir: "/opt/web"}, nil},
+		{"project name without dir", labelSet{labelProject: "myapp"}, old},
+		{"unknown name", labelSet{labelProject: "other"}, nil},
+	}
+	for _, tt := range tests {
+		if got := m.match(psContainer{Labels: tt.labels}); got != tt.want {
+			t.Errorf("%s: matched %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectStatuses(t *testing.T) {
+	dir := fakeDocker(t)
+	web := fakeProject(t, "web", nil, "")
+	db := fakeProject(t, "db", nil, "")
+	idle := fakeProject(t, "idle", nil, "")
+	if err := os.WriteFile(web.ComposeFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-time.Hour).UTC().Format(psTimeLayout)
+	ps := strings.Join([]string{
+		psLine("web", web.Path, "app", "running", "Up 1 hour (unhealthy)", old),
+		psLine("web", web.Path, "app", "running", "Up 1 hour", old), // Scaled, counted once
+		psLine("web", web.Path, "worker", "running", "Up 1 hour (healthy)", old),
+		psLine("web", web.Path, "init", "exited", "Exited (0) 1 hour ago", old),
+		psLine("db", "", "postgres", "running", "Up 1 hour", old),
+		psLine("idle", idle.Path, "app", "exited", "Exited (137) 1 hour ago", old),
+		psLine("other", "/srv/other", "app", "running", "Up 1 hour", old),
+		`{"ID":"run","State":"running","Status":"Up 1 second","Labels":"` + labelProject + `=db,` + labelService + `=shell,` + labelOneOff + `=True"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ps"), []byte(ps), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := CollectStatuses(context.Background(), []*Project{web, db, idle})
+	want := map[string]ProjectStatus{
+		web.ID():  {Status: "running:2", RunningContainers: 2, ComposeChanged: true, Unhealthy: []string{"app"}},
+		db.ID():   {Status: "running:1", RunningContainers: 1},
+		idle.ID(): {Status: "stopped"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses %+v\nwant %+v", got, want)
+	}
+	if calls := fakeDockerCalls(t, dir); len(calls) != 1 {
+		t.Errorf("want a single ps call, got %q", calls)
+	}
+}
+
+func TestComposeProjectName(t *testing.T) {
+	tests := map[string]string{
+		"web":        "web",
+		"My.App":     "myapp",
+		"app_2-test": "app_2-test",
+		"Ünïcode ok": "ncodeok",
+	}
+	for name, want := range tests {
+		if got := composeProjectName(name); got != want {
+			t.Errorf("composeProjectName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UpdateStrategy controls how containers are recreated after the pull
+type UpdateStrategy string
+
+const (
+	StrategyFull    UpdateStrategy = "full"    // down, then up --force-recreate of everything (default)
+	StrategyChanged UpdateStrategy = "changed" // Recreate only services whose image changed, no down
+	StrategyRolling UpdateStrategy = "rolling" // Like changed, one service at a time, waiting until it is healthy
+)
+
+// LabelUpdateStrategy sets the update strategy of a project on any of its services
+const LabelUpdateStrategy = "dcm.update.strategy"
+
+// Strategies lists the strategies in the order the TUI cycles through them
+var Strategies = []UpdateStrategy{StrategyFull, StrategyChanged, StrategyRolling}
+
+// ParseUpdateStrategy parses a strategy from a label or the config, empty is full
+func ParseUpdateStrategy(s string) (UpdateStrategy, error) {
+	switch UpdateStrategy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", StrategyFull:
+		return StrategyFull, nil
+	case StrategyChanged:
+		return StrategyChanged, nil
+	case StrategyRolling:
+		return StrategyRolling, nil
+	}
+	return "", fmt.Errorf("unknown update strategy %q", s)
+}
+
+// ParseUpdateStrategies parses the strategies from the config by project
+// name (see Options.Strategies)
+func ParseUpdateStrategies(strategies map[string]string) (map[string]UpdateStrategy, error) {
+	parsed := make(map[string]UpdateStrategy, len(strategies))
+	for name, value := range strategies {
+		strategy, err := ParseUpdateStrategy(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		parsed[name] = strategy
+	}
+	return parsed, nil
+}
+
+// UpdateStrategy returns the strategy of the project: from the config, else
+// the dcm.update.strategy label of a service (the first by name), else full
+func (p *Project) UpdateStrategy(ctx context.Context) UpdateStrategy {
+	if strategy, ok := p.options().Strategies[p.Name]; ok {
+		return strategy
+	}
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return StrategyFull
+	}
+	for _, name := range sortedServices(cfg) {
+		if label := cfg.Services[name].Labels[LabelUpdateStrategy]; label != "" {
+			if strategy, err := ParseUpdateStrategy(label); err == nil {
+				return strategy
+			}
+		}
+	}
+	return StrategyFull
+}
+
+// recreate recreates the containers of services (nil = all) after a pull
+// with the given strategy and returns the services it recreated (nil = all),
+// also on failure
+func (p *Project) recreate(ctx context.Context, strategy UpdateStrategy, services []string, all bool) ([]string, error) {
+	if strategy == StrategyFull {
+		return services, p.recreateFull(ctx, services, all)
+	}
+
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		// docker-compose v1: compose finds the changed containers itself,
+		// but can't go one service at a time
+		p.warn(fmt.Sprintf("%s update not possible without a rendered compose config, recreated with up -d", strategy))
+		upArgs := []string{"up", "-d", "--remove-orphans"}
+		if !all {
+			upArgs = append(upArgs, services...)
+		}
+		return services, p.up(ctx, "recreate", upArgs...)
+	}
+	changed, err := p.changedServices(ctx, cfg, services)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	if strategy == StrategyChanged {
+		return changed, p.up(ctx, "recreate", append([]string{"up", "-d", "--no-deps", "--force-recreate"}, changed...)...)
+	}
+
+	// Rolling: dependencies first, each service must be up before the next
+	ordered := dependencyOrder(cfg, changed)
+	for i, service := range ordered {
+		if err := p.up(ctx, "recreate "+service, "up", "-d", "--no-deps", "--force-recreate", service); err != nil {
+			return ordered[:i+1], err
+		}
+		if err := p.waitForService(ctx, service); err != nil {
+			return ordered[:i+1], err
+		}
+	}
+	return ordered, nil
+}
+
+// recreateFull is the full strategy: if every service may be updated the
+// project is taken down first, otherwise the allowed services are recreated
+// and compose leaves the others alone
+func (p *Project) recreateFull(ctx context.Context, services []string, all bool) error {
+	upArgs := []string{"up", "-d", "--remove-orphans"}
+	if all {
+		// Remove orphaned containers first (prevents KeyError: 'ContainerConfig')
+		cmd := p.compose(ctx, "down", "--remove-orphans")
+		cmd.Stdin = nil
+		p.host.withEnv(cmd, "COMPOSE_ANSI=never")
+		cmd.Run() // Ignore errors, this is cleanup
+		if err := cancelled(ctx, "update"); err != nil {
+			return err
+		}
+		upArgs = append(upArgs, "--force-recreate")
+	} else {
+		upArgs = append(upArgs, services...)
+	}
+
+	// Recreate containers with new images; without --force-recreate
+	// compose leaves containers whose image didn't change (pinned) alone
+	return p.up(ctx, "recreate", upArgs...)
+}
+
+// up runs a compose command that creates containers
+func (p *Project) up(ctx context.Context, operation string, args ...string) error {
+	cmd := p.compose(ctx, args...)
+	cmd.Stdin = nil // Prevent docker from detecting TTY
+	p.host.withEnv(cmd, "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0") // Disable ANSI and buildkit output
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if err := cancelled(ctx, operation); err != nil {
+			return err
+		}
+		return cleanDockerError(operation, output, err)
+	}
+	return nil
+}
+
+// changedServices returns those of services (nil = all) whose containers run
+// another image than their image reference now points at, or that have no
+// container yet
+func (p *Project) changedServices(ctx context.Context, cfg *composeConfig, services []string) ([]string, error) {
+	if services == nil {
+		services = sortedServices(cfg)
+	}
+	running, err := p.runningImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, service := range services {
+		ref := cfg.Services[service].Image
+		if ref == "" {
+			continue // Built locally, not pulled
+		}
+		output, err := p.host.engine(ctx, "image", "inspect", "--format", "{{.Id}}", ref).Output()
+		if err != nil {
+			if err := cancelled(ctx, "update"); err != nil {
+				return nil, err
+			}
+			continue // Not pulled (e.g. pull_policy: never)
+		}
+		ids, ok := running[service]
+		if !ok || !ids[strings.TrimSpace(string(output))] || len(ids) > 1 {
+			changed = append(changed, service)
+		}
+	}
+	return changed, nil
+}
+
+// runningImages returns the image IDs the containers of each service run
+func (p *Project) runningImages(ctx context.Context) (map[string]map[string]bool, error) {
+	containers, err := listComposeContainers(ctx, p.host)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newProjectMatcher([]*Project{p})
+	var ids []string
+	for _, c := range containers {
+		if matcher.match(c) == p {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	running := make(map[string]map[string]bool)
+	if len(ids) == 0 {
+		return running, nil
+	}
+	args := append([]string{"inspect", "--format", `{{index .Config.Labels "` + labelService + `"}} {{.Image}}`}, ids...)
+	output, err := p.host.engine(ctx, args...).Output()
+	if err != nil {
+		if err := cancelled(ctx, "update"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to inspect containers: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		service, id, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if running[service] == nil {
+			running[service] = make(map[string]bool)
+		}
+		running[service][id] = true
+	}
+	return running, nil
+}
+
+// waitForService waits until the containers of a service are running and
+// healthy (see VerifyOptions.HealthTimeout)
+func (p *Project) waitForService(ctx context.Context, service string) error {
+	if dryRun(ctx) {
+		notef(ctx, "wait until %s is running and healthy", service)
+		return nil
+	}
+	timeout := p.options().Verify.HealthTimeout
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		_, pending, err := p.containerHealth(ctx, service)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not healthy after %s", service, timeout)
+		}
+		if err := sleepCtx(ctx, verifyPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// dependencyOrder sorts services so that every service comes after the
+// services it depends on, otherwise by name. Cycles are broken by name.
+func dependencyOrder(cfg *composeConfig, services []string) []string {
+	wanted := make(map[string]bool, len(services))
+	for _, s := range services {
+		wanted[s] = true
+	}
+
+	var ordered []string
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var visit func(string)
+	visit = func(service string) {
+		if done[service] || visiting[service] {
+			return
+		}
+		visiting[service] = true
+		deps := make([]string, 0, len(cfg.Services[service].DependsOn))
+		for dep := range cfg.Services[service].DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		visiting[service] = false
+		done[service] = true
+		if wanted[service] {
+			ordered = append(ordered, service)
+		}
+	}
+
+	sorted := append([]string(nil), services...)
+	sort.Strings(sorted)
+	for _, s := range sorted {
+		visit(s)
+	}
+	return ordered
+}
+
+// sortedServices returns the service names of cfg sorted
+func sortedServices(cfg *composeConfig) []string {
+	names := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
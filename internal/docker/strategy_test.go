@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUpdateStrategy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    UpdateStrategy
+		wantErr bool
+	}{
+		{"", StrategyFull, false},
+		{"full", StrategyFull, false},
+		{" Changed ", StrategyChanged, false},
+		{"ROLLING", StrategyRolling, false},
+		{"blue-green", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUpdateStrategy(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseUpdateStrategy(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	// config returns a compose config of services and their depends_on
+	config := func(deps map[string][]string) *composeConfig {
+		cfg := &composeConfig{Services: make(map[string]composeService)}
+		for name, list := range deps {
+			svc := composeService{DependsOn: make(map[string]json.RawMessage)}
+			for _, dep := range list {
+				svc.DependsOn[dep] = json.RawMessage(`{"condition":"service_started"}`)
+			}
+			cfg.Services[name] = svc
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name     string
+		deps     map[string][]string
+		services []string
+		want     []string
+	}{
+		{
+			name:     "independent sorted",
+			deps:     map[string][]string{"web": nil, "db": nil, "cache": nil},
+			services: []string{"web", "db", "cache"},
+			want:     []string{"cache", "db", "web"},
+		},
+		{
+			name:     "dependencies first",
+			deps:     map[string][]string{"web": {"api"}, "api": {"db", "cache"}, "db": nil, "cache": nil},
+			services: []string{"web", "api", "db", "cache"},
+			want:     []string{"cache", "db", "api", "web"},
+		},
+		{
+			name:     "through services not updated",
+			deps:     map[string][]string{"web": {"api"}, "api": {"db"}, "db": nil},
+			services: []string{"web", "db"},
+			want:     []string{"db", "web"},
+		},
+		{
+			name:     "cycle",
+			deps:     map[string][]string{"a": {"b"}, "b": {"a"}},
+			services: []string{"a", "b"},
+			want:     []string{"b", "a"},
+		},
+		{
+			name:     "unknown dependency",
+			deps:     map[string][]string{"web": {"missing"}},
+			services: []string{"web"},
+			want:     []string{"web"},
+		},
+	}
+	for _, tt := range tests {
+		if got := dependencyOrder(config(tt.deps), tt.services); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dependencyOrder = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecreateServices(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy UpdateStrategy
+		services []string
+		all      bool
+		want     []string
+	}{
+		{
+			name:     "full, all services",
+			strategy: StrategyFull,
+			services: []string{"db", "web"},
+			all:      true,
+			want:     []string{"compose down --remove-orphans", "compose up -d --remove-orphans --force-recreate"},
+		},
+		{
+			name:     "full, allowed services",
+			strategy: StrategyFull,
+			services: []string{"web"},
+			want:     []string{"compose up -d --remove-orphans web"},
+		},
+		{
+			name:     "changed without config",
+			strategy: StrategyChanged,
+			services: []string{"web", "worker"},
+			want:     []string{"compose config --format json", "compose up -d --remove-orphans web worker"},
+		},
+		{
+			name:     "rolling without config, all services",
+			strategy: StrategyRolling,
+			services: []string{"db", "web"},
+			all:      true,
+			want:     []string{"compose config --format json", "compose up -d --remove-orphans"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeDocker(t)
+			p := fakeProject(t, "app", []string{"nginx:1.25"}, "")
+			if _, err := p.recreate(context.Background(), tt.strategy, tt.services, tt.all); err != nil {
+				t.Fatalf("recreate: %v", err)
+			}
+			var got []string
+			for _, call := range fakeDockerCalls(t, dir) {
+				_, args, _ := strings.Cut(call, "|")
+				got = append(got, args)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commands %q, want %q", got, tt.want)
+			}
+			// Only changed and rolling need the config, they can't keep their promise
+			if warned := p.UpdateWarning != ""; warned != (tt.strategy != StrategyFull) {
+				t.Errorf("warning %q", p.UpdateWarning)
+			}
+		})
+	}
+}
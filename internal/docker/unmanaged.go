@@ -0,0 +1,221 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// labelConfigFiles lists the compose files a container was created from
+const labelConfigFiles = "com.docker.compose.project.config_files"
+
+// UnmanagedStack is a compose project that has containers but isn't one of
+// the managed projects, e.g. started from outside the search directory or
+// from a directory that was deleted since
+type UnmanagedStack struct {
+	Name        string   // Compose project name
+	WorkingDir  string   // Directory compose ran in (empty for old compose versions)
+	ConfigFiles []string // Compose files the containers were created from
+	Containers  []string // Container IDs
+	Names       []string // Container names
+	Running     int      // Number of running containers
+	Reason      string   // Why the stack isn't managed
+	Host        string   // Name of the host, empty for the local daemon
+
+	host       *Host
+	sharedName bool // Another stack on the host uses the same project name
+}
+
+// FindUnmanagedStacks lists compose projects on hosts with containers that
+// don't belong to any of projects. searchRoots (of the local daemon) are only
+// used to explain why. Hosts that can't be reached are reported in the
+// error, the stacks of the others are returned anyway.
+func FindUnmanagedStacks(ctx context.Context, hosts []*Host, projects []*Project, searchRoots []string) ([]UnmanagedStack, error) {
+	groups := groupByHost(projects)
+
+	var stacks []UnmanagedStack
+	var errs []error
+	for _, h := range hosts {
+		roots := searchRoots
+		if h != nil {
+			roots = nil
+			if h.SearchDir != "" {
+				roots = []string{h.SearchDir}
+			}
+		}
+
+		found, err := findUnmanagedOn(ctx, h, groups[h], roots)
+		if err != nil {
+			if h != nil {
+				err = fmt.Errorf("%s: %w", h.Name, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		stacks = append(stacks, found...)
+	}
+	return stacks, errors.Join(errs...)
+}
+
+// findUnmanagedOn lists the unmanaged stacks of one host
+func findUnmanagedOn(ctx context.Context, h *Host, projects []*Project, searchRoots []string) ([]UnmanagedStack, error) {
+	containers, err := listComposeContainers(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newProjectMatcher(projects)
+
+	// One stack per project name and directory; compose itself only knows
+	// project names, so count them to protect same-named stacks on remove
+	byKey := make(map[string]*UnmanagedStack)
+	dirsByName := make(map[string]map[string]bool)
+	var keys []string
+	for _, c := range containers {
+		name, dir := c.label(labelProject), c.label(labelWorkingDir)
+		if dirsByName[name] == nil {
+			dirsByName[name] = make(map[string]bool)
+		}
+		if p := matcher.match(c); p != nil {
+			dirsByName[name][p.Path] = true
+			continue
+		}
+		dirsByName[name][dir] = true
+
+		key := name + "\x00" + dir
+		s := byKey[key]
+		if s == nil {
+			s = &UnmanagedStack{Name: name, WorkingDir: dir, Reason: unmanagedReason(h, searchRoots, dir), host: h}
+			if h != nil {
+				s.Host = h.Name
+			}
+			if files := c.label(labelConfigFiles); files != "" {
+				s.ConfigFiles = strings.Split(files, ",")
+			}
+			byKey[key] = s
+			keys = append(keys, key)
+		}
+		s.Containers = append(s.Containers, c.ID)
+		s.Names = append(s.Names, c.Names)
+		if c.State == "running" {
+			s.Running++
+		}
+	}
+
+	sort.Strings(keys)
+	stacks := make([]UnmanagedStack, 0, len(keys))
+	for _, key := range keys {
+		s := byKey[key]
+		s.sharedName = len(dirsByName[s.Name]) > 1
+		sort.Strings(s.Names)
+		stacks = append(stacks, *s)
+	}
+	return stacks, nil
+}
+
+// unmanagedReason explains why a stack started from dir on h isn't managed
+func unmanagedReason(h *Host, searchRoots []string, dir string) string {
+	if dir == "" {
+		return "no working directory label (old compose version)"
+	}
+	// Directories of ssh hosts are on the remote machine
+	if _, err := os.Stat(dir); err != nil && !h.SSH() {
+		return "directory no longer exists"
+	}
+	for _, root := range searchRoots {
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return "not discovered (excluded, hidden or too deep)"
+		}
+	}
+	return "outside the search directory"
+}
+
+// CanAdopt reports whether the stack's directory still has a compose file.
+// Only stacks of the local daemon can be adopted.
+func (s UnmanagedStack) CanAdopt() bool {
+	if s.host != nil {
+		return false
+	}
+	_, err := adoptableComposeFile(s.WorkingDir)
+	return err == nil
+}
+
+// Stop stops all containers of the stack
+func (s UnmanagedStack) Stop(ctx context.Context) error {
+	args := append([]string{"stop"}, s.Containers...)
+	if output, err := s.host.engine(ctx, args...).CombinedOutput(); err != nil {
+		if err := cancelled(ctx, "stop"); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to stop %s: %s", s.Name, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Remove removes all containers of the stack and its networks. Volumes are
+// kept. Networks are only touched if no other stack has the same project
+// name, compose labels networks by name only.
+func (s UnmanagedStack) Remove(ctx context.Context) error {
+	args := append([]string{"rm", "--force"}, s.Containers...)
+	if output, err := s.host.engine(ctx, args...).CombinedOutput(); err != nil {
+		if err := cancelled(ctx, "remove"); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to remove %s: %s", s.Name, strings.TrimSpace(string(output)))
+	}
+
+	if s.sharedName {
+		return nil
+	}
+	output, err := s.host.engine(ctx, "network", "ls", "--quiet",
+		"--filter", "label="+labelProject+"="+s.Name).Output()
+	if err != nil {
+		return nil // Containers are gone, leftover networks are harmless
+	}
+	if networks := strings.Fields(string(output)); len(networks) > 0 {
+		s.host.engine(ctx, append([]string{"network", "rm"}, networks...)...).Run()
+	}
+	return nil
+}
+
+// Adopt loads the stack's directory as a project
+func (s UnmanagedStack) Adopt() (*Project, error) {
+	return AdoptProject(s.WorkingDir)
+}
+
+// AdoptProject loads the project in dir, which is outside the discovered
+// set (see Discovery.Adopt). Ignore rules don't apply to adopted projects.
+func AdoptProject(dir string) (*Project, error) {
+	composeFile, err := adoptableComposeFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewProject(composeFile)
+}
+
+// adoptableComposeFile returns the compose file compose would use in dir
+func adoptableComposeFile(dir string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("directory unknown")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && IsComposeFile(e.Name()) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no compose file in %s", dir)
+	}
+	used, _ := preferredComposeFile(files)
+	return used, nil
+}
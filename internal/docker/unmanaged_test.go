@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindUnmanagedStacks(t *testing.T) {
+	dir := fakeDocker(t)
+	search := t.TempDir()
+	writeFiles(t, search, map[string]string{
+		"web/compose.yml":       "",
+		"hidden/compose.yml":    "",
+		"elsewhere/compose.yml": "",
+	})
+	outside := t.TempDir()
+	web := &Project{Name: "web", Path: filepath.Join(search, "web")}
+	hidden := filepath.Join(search, "hidden")
+	gone := filepath.Join(search, "gone")
+
+	ps := strings.Join([]string{
+		psLine("web", web.Path, "app", "running", "Up 1 hour", ""),
+		psLine("hidden", hidden, "app", "running", "Up 1 hour", ""),
+		psLine("hidden", hidden, "db", "exited", "Exited (0) 1 hour ago", ""),
+		psLine("gone", gone, "app", "exited", "Exited (0) 1 hour ago", ""),
+		psLine("old", "", "app", "running", "Up 1 hour", ""),
+		// Same project name as a managed project, started elsewhere
+		psLine("web", outside, "app", "running", "Up 1 hour", ""),
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ps"), []byte(ps), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stacks, err := FindUnmanagedStacks(context.Background(), []*Host{nil}, []*Project{web}, []string{search})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type stack struct {
+		name, dir, reason string
+		running           int
+		names             []string
+		shared            bool
+	}
+	var got []stack
+	for _, s := range stacks {
+		got = append(got, stack{s.Name, s.WorkingDir, s.Reason, s.Running, s.Names, s.sharedName})
+	}
+	want := []stack{
+		{"gone", gone, "directory no longer exists", 0, []string{"gone-app-1"}, false},
+		{"hidden", hidden, "not discovered (excluded, hidden or too deep)", 1, []string{"hidden-app-1", "hidden-db-1"}, false},
+		{"old", "", "no working directory label (old compose version)", 1, []string{"old-app-1"}, false},
+		{"web", outside, "outside the search directory", 1, []string{"web-app-1"}, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stacks\n%+v\nwant\n%+v", got, want)
+	}
+
+	if !stacks[1].CanAdopt() || stacks[0].CanAdopt() || stacks[2].CanAdopt() {
+		t.Errorf("CanAdopt wrong for hidden, gone or old")
+	}
+	p, err := stacks[1].Adopt()
+	if err != nil || p.ComposeFile != filepath.Join(hidden, "compose.yml") {
+		t.Errorf("Adopt = %+v, %v", p, err)
+	}
+}
+
+func TestUnmanagedStackRemove(t *testing.T) {
+	for _, shared := range []bool{false, true} {
+		dir := fakeDocker(t)
+		s := UnmanagedStack{Name: "web", Containers: []string{"a", "b"}, sharedName: shared}
+		if err := s.Remove(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, call := range fakeDockerCalls(t, dir) {
+			_, args, _ := strings.Cut(call, "|")
+			got = append(got, args)
+		}
+		want := []string{"rm --force a b"}
+		if !shared {
+			// Networks are labeled by project name only
+			want = append(want, "network ls --quiet --filter label="+labelProject+"=web")
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("shared %v: commands %q, want %q", shared, got, want)
+		}
+	}
+}
+
+func TestDiscoveryAdopt(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"known/compose.yml": "", "adopted/docker-compose.yml": "", "empty/.keep": ""})
+	known := &Project{Path: filepath.Join(root, "known")}
+	d := &Discovery{Projects: []*Project{known}, Directories: make(map[string]time.Time)}
+
+	d.Adopt([]string{known.Path, filepath.Join(root, "adopted"), filepath.Join(root, "empty")})
+	if len(d.Projects) != 2 || d.Projects[0] != known || d.Projects[1].Name != "adopted" {
+		t.Fatalf("projects %+v", d.Projects)
+	}
+	if _, ok := d.Directories[filepath.Join(root, "adopted")]; !ok {
+		t.Errorf("adopted directory not tracked")
+	}
+	if len(d.Diagnostics) != 1 || d.Diagnostics[0].Path != filepath.Join(root, "empty") {
+		t.Errorf("diagnostics %+v", d.Diagnostics)
+	}
+}
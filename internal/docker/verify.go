@@ -0,0 +1,352 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LabelUpdateProbe is a URL that must answer after an update (see VerifyOptions)
+const LabelUpdateProbe = "dcm.update.probe"
+
+const (
+	// DefaultHealthTimeout is how long containers may take to become healthy
+	DefaultHealthTimeout = 2 * time.Minute
+	// DefaultGracePeriod is how long containers must keep running afterwards
+	DefaultGracePeriod = 30 * time.Second
+
+	verifyPollInterval = 2 * time.Second
+	probeTimeout       = 10 * time.Second
+)
+
+// VerifyOptions configures the checks after an update: containers must
+// become running and healthy, keep running for the grace period and the
+// project's HTTP probe must answer. On failure the previous images are
+// restored if Rollback is set.
+type VerifyOptions struct {
+	Enabled       bool
+	HealthTimeout time.Duration     // Time to become running and healthy, and for the probe to answer
+	GracePeriod   time.Duration     // Time containers must then keep running without restarts
+	Rollback      bool              // Restore the previous images when a check fails
+	Probes        map[string]string // Probe URL by project name, overrides dcm.update.probe
+}
+
+// RollbackError is returned by Update when the verification or recreating
+// the containers failed. Err is the failure; RollbackErr is set if restoring
+// the old images failed too.
+type RollbackError struct {
+	Err         error
+	RolledBack  bool
+	RollbackErr error
+}
+
+func (e *RollbackError) Error() string {
+	switch {
+	case e.RollbackErr != nil:
+		return fmt.Sprintf("%v; rollback failed: %v", e.Err, e.RollbackErr)
+	case e.RolledBack:
+		return fmt.Sprintf("rolled back: %v", e.Err)
+	}
+	return fmt.Sprintf("verification failed: %v", e.Err)
+}
+
+func (e *RollbackError) Unwrap() error { return e.Err }
+
+// IsRolledBack reports whether err is an update that was rolled back
+func IsRolledBack(err error) bool {
+	var rb *RollbackError
+	return errors.As(err, &rb) && rb.RolledBack
+}
+
+// imageSnapshot maps the image references of the project's containers to
+// the image IDs they run, taken before an update so it can be rolled back.
+// The tags themselves may already point at new images after an update check.
+type imageSnapshot map[string]string
+
+// snapshotImages records the image every container of the project runs
+func (p *Project) snapshotImages(ctx context.Context) (imageSnapshot, error) {
+	containers, err := listComposeContainers(ctx, p.host)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newProjectMatcher([]*Project{p})
+	var ids []string
+	for _, c := range containers {
+		if matcher.match(c) == p {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	snapshot := make(imageSnapshot, len(ids))
+	if len(ids) == 0 {
+		return snapshot, nil // Not running, nothing to go back to
+	}
+	args := append([]string{"inspect", "--format", "{{.Config.Image}} {{.Image}}"}, ids...)
+	output, err := p.host.engine(ctx, args...).Output()
+	if err != nil {
+		if err := cancelled(ctx, "snapshot"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to inspect containers for rollback: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if ref, id, ok := strings.Cut(strings.TrimSpace(line), " "); ok && ref != "" && id != "" {
+			snapshot[ref] = id
+		}
+	}
+	return snapshot, nil
+}
+
+// verifyUpdate runs the checks of VerifyOptions after an update and rolls
+// back to snapshot on failure. services are the recreated services (nil = all).
+func (p *Project) verifyUpdate(ctx context.Context, services []string, snapshot imageSnapshot) error {
+	if dryRun(ctx) {
+		notef(ctx, "verify: healthy within %s, running for %s without restarts", p.options().Verify.HealthTimeout, p.options().Verify.GracePeriod)
+		if url := p.probeURL(ctx); url != "" {
+			notef(ctx, "verify: probe %s", url)
+		}
+		return nil
+	}
+	err := p.verify(ctx)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return cancelled(ctx, "verify")
+	}
+
+	rbErr := &RollbackError{Err: err}
+	if !p.options().Verify.Rollback || snapshot == nil {
+		return rbErr
+	}
+	if err := p.rollback(ctx, services, snapshot); err != nil {
+		rbErr.RollbackErr = err
+	} else {
+		rbErr.RolledBack = true
+	}
+	p.UpdateStatus(ctx)
+	return rbErr
+}
+
+// verify waits for the project's containers to become running and healthy,
+// watches them for the grace period and calls the probe
+func (p *Project) verify(ctx context.Context) error {
+	deadline := time.Now().Add(p.options().Verify.HealthTimeout)
+	for {
+		ids, pending, err := p.containerHealth(ctx, "")
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			if err := p.watchGracePeriod(ctx, ids); err != nil {
+				return err
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s: %s", p.options().Verify.HealthTimeout, strings.Join(pending, ", "))
+		}
+		if err := sleepCtx(ctx, verifyPollInterval); err != nil {
+			return err
+		}
+	}
+
+	if url := p.probeURL(ctx); url != "" {
+		return probe(ctx, url, time.Now().Add(p.options().Verify.HealthTimeout))
+	}
+	return nil
+}
+
+// containerHealth lists the project's containers, or only those of service.
+// It fails on the first crashed, restarting or unhealthy one; pending are
+// the services still starting. Containers that exited with code 0 (init
+// jobs) are fine.
+func (p *Project) containerHealth(ctx context.Context, service string) (ids, pending []string, err error) {
+	containers, err := listComposeContainers(ctx, p.host)
+	if err != nil {
+		return nil, nil, err
+	}
+	matcher := newProjectMatcher([]*Project{p})
+
+	found := false
+	for _, c := range containers {
+		if matcher.match(c) != p || (service != "" && c.label(labelService) != service) {
+			continue
+		}
+		found = true
+		service := c.label(labelService)
+
+		switch {
+		case c.State == "restarting":
+			return nil, nil, fmt.Errorf("%s is restarting", service)
+		case c.State == "exited" || c.State == "dead":
+			if !strings.HasPrefix(c.Status, "Exited (0)") {
+				return nil, nil, fmt.Errorf("%s stopped: %s", service, c.Status)
+			}
+		case c.State != "running":
+			pending = append(pending, service)
+		case strings.Contains(c.Status, "(unhealthy)"):
+			return nil, nil, fmt.Errorf("%s is unhealthy", service)
+		case strings.Contains(c.Status, "starting)"):
+			pending = append(pending, service) // "(health: starting)", podman "(starting)"
+		default:
+			ids = append(ids, c.ID)
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("no containers running")
+	}
+	sort.Strings(pending)
+	return ids, pending, nil
+}
+
+// watchGracePeriod checks that the running containers ids stay up without
+// restarting for the grace period
+func (p *Project) watchGracePeriod(ctx context.Context, ids []string) error {
+	before, err := p.restartCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now().Add(p.options().Verify.GracePeriod)
+	for time.Now().Before(end) {
+		if err := sleepCtx(ctx, verifyPollInterval); err != nil {
+			return err
+		}
+		if _, _, err := p.containerHealth(ctx, ""); err != nil {
+			return err
+		}
+	}
+
+	after, err := p.restartCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for id, n := range after {
+		if n > before[id] {
+			return fmt.Errorf("container %s restarted %d times", shortID(id), n-before[id])
+		}
+	}
+	return nil
+}
+
+// restartCounts returns the restart count of the containers by ID
+func (p *Project) restartCounts(ctx context.Context, ids []string) (map[string]int, error) {
+	counts := make(map[string]int, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	args := append([]string{"inspect", "--format", "{{.Id}} {{.RestartCount}}"}, ids...)
+	output, err := p.host.engine(ctx, args...).Output()
+	if err != nil {
+		if err := cancelled(ctx, "verify"); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("container gone: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		id, n, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		counts[id], _ = strconv.Atoi(n)
+	}
+	return counts, nil
+}
+
+// probeURL returns the probe of the project: from the config, else the
+// dcm.update.probe label of a service (the first by name)
+func (p *Project) probeURL(ctx context.Context) string {
+	if url, ok := p.options().Verify.Probes[p.Name]; ok {
+		return url
+	}
+	cfg, err := p.loadComposeConfig(ctx)
+	if err != nil {
+		return ""
+	}
+	for _, name := range sortedServices(cfg) {
+		if url := cfg.Services[name].Labels[LabelUpdateProbe]; url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// probe requests url until it answers with a status below 400, or fails
+// once deadline has passed. The request is made from this machine, also
+// for projects on other hosts.
+func probe(ctx context.Context, url string, deadline time.Time) error {
+	client := &http.Client{Timeout: probeTimeout}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("invalid probe %s: %w", url, err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 400 {
+				return nil
+			}
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("probe %s failed: %v", url, err)
+		}
+		if err := sleepCtx(ctx, verifyPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// rollback points the image references back at the snapshot and recreates
+// the services with them
+func (p *Project) rollback(ctx context.Context, services []string, snapshot imageSnapshot) error {
+	refs := make([]string, 0, len(snapshot))
+	for ref := range snapshot {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		if output, err := p.host.engine(ctx, "tag", snapshot[ref], ref).CombinedOutput(); err != nil {
+			return cleanDockerError("tag", output, err)
+		}
+	}
+
+	cmd := p.compose(ctx, append([]string{"up", "-d", "--remove-orphans", "--force-recreate"}, services...)...)
+	cmd.Stdin = nil
+	p.host.withEnv(cmd, "COMPOSE_ANSI=never", "DOCKER_BUILDKIT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if err := cancelled(ctx, "rollback"); err != nil {
+			return err
+		}
+		return cleanDockerError("rollback", output, err)
+	}
+	return nil
+}
+
+// sleepCtx waits for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return cancelled(ctx, "verify")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// shortID shortens a container ID for messages
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
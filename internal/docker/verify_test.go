@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writePS sets the containers the fake docker lists
+func writePS(t *testing.T, dir string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "ps"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContainerHealth(t *testing.T) {
+	dir := fakeDocker(t)
+	p := fakeProject(t, "app", nil, "")
+	other := psLine("other", "/srv/other", "web", "restarting", "Restarting (1) 2 seconds ago", "")
+
+	tests := []struct {
+		name    string
+		ps      []string
+		ids     []string
+		pending []string
+		err     string
+	}{
+		{"healthy", []string{
+			psLine("app", p.Path, "web", "running", "Up 1 minute (healthy)", ""),
+			psLine("app", p.Path, "init", "exited", "Exited (0) 1 minute ago", ""),
+			other,
+		}, []string{"app-web"}, nil, ""},
+		{"starting", []string{
+			psLine("app", p.Path, "web", "running", "Up 5 seconds (health: starting)", ""),
+			psLine("app", p.Path, "db", "created", "Created", ""),
+			psLine("app", p.Path, "cache", "running", "Up 5 seconds", ""),
+		}, []string{"app-cache"}, []string{"db", "web"}, ""},
+		{"unhealthy", []string{psLine("app", p.Path, "web", "running", "Up 1 minute (unhealthy)", "")}, nil, nil, "web is unhealthy"},
+		{"crashed", []string{psLine("app", p.Path, "web", "exited", "Exited (1) 5 seconds ago", "")}, nil, nil, "web stopped: Exited (1) 5 seconds ago"},
+		{"restarting", []string{psLine("app", p.Path, "web", "restarting", "Restarting (1) 2 seconds ago", "")}, nil, nil, "web is restarting"},
+		{"gone", []string{other}, nil, nil, "no containers running"},
+	}
+	for _, tt := range tests {
+		writePS(t, dir, tt.ps...)
+		ids, pending, err := p.containerHealth(context.Background(), "")
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(ids, tt.ids) || !reflect.DeepEqual(pending, tt.pending) {
+			t.Errorf("%s: %q, %q, %v; want %q, %q", tt.name, ids, pending, err, tt.ids, tt.pending)
+		}
+	}
+
+	// Only the given service
+	writePS(t, dir, psLine("app", p.Path, "web", "running", "Up 1 minute (unhealthy)", ""), psLine("app", p.Path, "db", "running", "Up 1 minute", ""))
+	if ids, _, err := p.containerHealth(context.Background(), "db"); err != nil || !reflect.DeepEqual(ids, []string{"app-db"}) {
+		t.Errorf("db only: %q, %v", ids, err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	if err := probe(context.Background(), server.URL, time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("probe answering 200: %v", err)
+	}
+	status.Store(http.StatusServiceUnavailable)
+	err := probe(context.Background(), server.URL, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("probe answering 503 = %v", err)
+	}
+
+	// Cancelled while waiting for the next attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := probe(ctx, server.URL, time.Now().Add(time.Minute)); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled probe = %v", err)
+	}
+}
+
+func TestProbeURL(t *testing.T) {
+	fakeDocker(t)
+	config := `{"services":{"web":{"labels":{"` + LabelUpdateProbe + `":"http://localhost:8080/health"}},"db":{}}}`
+	p := fakeProject(t, "app", nil, config)
+
+	if got := p.probeURL(context.Background()); got != "http://localhost:8080/health" {
+		t.Errorf("probe from label = %q", got)
+	}
+	opts, err := NewOptions(Options{Verify: VerifyOptions{Probes: map[string]string{"app": "http://localhost/ready"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetOptions(opts)
+	if got := p.probeURL(context.Background()); got != "http://localhost/ready" {
+		t.Errorf("probe from config = %q", got)
+	}
+}
+
+func TestVerifyUpdateRollback(t *testing.T) {
+	dir := fakeDocker(t)
+	p := fakeProject(t, "app", nil, "")
+	writePS(t, dir, psLine("app", p.Path, "web", "exited", "Exited (1) 1 second ago", ""))
+	snapshot := imageSnapshot{"nginx:1.25": "sha256:old", "redis:7": "sha256:older"}
+
+	for _, rollback := range []bool{false, true} {
+		os.Remove(filepath.Join(dir, "calls"))
+		opts, err := NewOptions(Options{Verify: VerifyOptions{Enabled: true, Rollback: rollback}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.SetOptions(opts)
+
+		err = p.verifyUpdate(context.Background(), []string{"web"}, snapshot)
+		var rb *RollbackError
+		if !errors.As(err, &rb) || rb.RolledBack != rollback || rb.RollbackErr != nil {
+			t.Fatalf("rollback %v: error %v", rollback, err)
+		}
+		if IsRolledBack(err) != rollback {
+			t.Errorf("rollback %v: IsRolledBack = %v", rollback, !rollback)
+		}
+
+		var got []string
+		for _, call := range fakeDockerCalls(t, dir) {
+			if _, args, _ := strings.Cut(call, "|"); !strings.HasPrefix(args, "ps ") && !strings.HasPrefix(args, "compose ps") {
+				got = append(got, args)
+			}
+		}
+		var want []string
+		if rollback {
+			want = []string{"tag sha256:old nginx:1.25", "tag sha256:older redis:7", "compose up -d --remove-orphans --force-recreate web"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("rollback %v: commands %q, want %q", rollback, got, want)
+		}
+	}
+}
+
+func TestRollbackError(t *testing.T) {
+	cause := errors.New("web is unhealthy")
+	tests := []struct {
+		err  *RollbackError
+		want string
+	}{
+		{&RollbackError{Err: cause}, "verification failed: web is unhealthy"},
+		{&RollbackError{Err: cause, RolledBack: true}, "rolled back: web is unhealthy"},
+		{&RollbackError{Err: cause, RollbackErr: errors.New("tag failed")}, "web is unhealthy; rollback failed: tag failed"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+		if !errors.Is(tt.err, cause) {
+			t.Errorf("%q doesn't wrap the cause", tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryWorkers is the number of directories read in parallel during discovery
+const discoveryWorkers = 8
+
+// walkResult is everything a tree walk found
+type walkResult struct {
+	composeFiles map[string]os.FileInfo // Compose file used per directory (not excluded or hidden)
+	ignored      map[string][]string    // Other compose files next to a used one
+	dirs         map[string]time.Time   // Entered directories and their mtimes
+	ignoreFiles  map[string]time.Time   // .dcmignore files and their mtimes
+	diagnostics  []Diagnostic           // Skipped and unreadable paths
+}
+
+// treeWalker searches a directory tree for compose files in parallel.
+// Symlinked directories are followed; every real directory is entered only
+// once, which also breaks symlink loops. Real directories are walked first,
+// symlinks found meanwhile are entered afterwards in sorted order, so which
+// of several paths to the same directory wins doesn't depend on timing.
+type treeWalker struct {
+	root     string
+	maxDepth int
+	ignorer  *Ignorer
+	sem      chan struct{} // Bounds concurrent directory reads
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	visited map[string]string // Real path of entered directories -> path entered as
+	links   []string        // Symlinked directories for the next round
+	result  walkResult
+}
+
+// walkTree walks start (root or a directory below it) up to maxDepth levels below root
+func walkTree(root, start string, maxDepth int, ignorer *Ignorer) walkResult {
+	w := &treeWalker{
+		root:     root,
+		maxDepth: maxDepth,
+		ignorer:  ignorer,
+		sem:      make(chan struct{}, discoveryWorkers),
+		visited:  make(map[string]string),
+		result: walkResult{
+			composeFiles: make(map[string]os.FileInfo),
+			ignored:      make(map[string][]string),
+			dirs:         make(map[string]time.Time),
+			ignoreFiles:  make(map[string]time.Time),
+		},
+	}
+
+	pending := []string{start}
+	for len(pending) > 0 {
+		for _, dir := range pending {
+			w.enter(dir)
+		}
+		w.wg.Wait()
+
+		pending, w.links = w.links, nil
+		sort.Strings(pending)
+	}
+
+	sort.Slice(w.result.diagnostics, func(i, j int) bool {
+		return w.result.diagnostics[i].Path < w.result.diagnostics[j].Path
+	})
+	return w.result
+}
+
+// diagnose records a diagnostic
+func (w *treeWalker) diagnose(kind DiagnosticKind, path, message string) {
+	w.mu.Lock()
+	w.result.diagnostics = append(w.result.diagnostics, Diagnostic{Kind: kind, Path: path, Message: message})
+	w.mu.Unlock()
+}
+
+// enter claims dir and reads it in the background, unless its real path
+// was entered before (duplicate path or symlink loop)
+func (w *treeWalker) enter(dir string) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		w.diagnose(DiagnosticUnreadable, dir, err.Error())
+		return
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		w.diagnose(DiagnosticUnreadable, dir, err.Error())
+		return
+	}
+
+	w.mu.Lock()
+	if first, ok := w.visited[real]; ok {
+		w.mu.Unlock()
+		if strings.HasPrefix(dir, first+string(os.PathSeparator)) {
+			w.diagnose(DiagnosticSkipped, dir, fmt.Sprintf("symlink loop back to %s", first))
+		} else {
+			w.diagnose(DiagnosticSkipped, dir, fmt.Sprintf("same directory as %s", first))
+		}
+		return
+	}
+	w.visited[real] = dir
+	w.result.dirs[dir] = info.ModTime()
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go w.read(dir)
+}
+
+// read processes the entries of one directory
+func (w *treeWalker) read(dir string) {
+	defer w.wg.Done()
+
+	w.sem <- struct{}{}
+	entries, err := os.ReadDir(dir)
+	<-w.sem
+	if err != nil {
+		w.diagnose(DiagnosticUnreadable, dir, err.Error())
+		return
+	}
+
+	hidden := w.ignorer.Hidden(dir)
+	if hidden {
+		w.diagnose(DiagnosticSkipped, dir, "project hidden by "+HideMarkerName)
+	}
+
+	var composeFiles []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		isLink := e.Type()&os.ModeSymlink != 0
+		isDir := e.IsDir()
+		if isLink {
+			target, err := os.Stat(path)
+			if err != nil {
+				w.diagnose(DiagnosticSkipped, path, "broken symlink")
+				continue
+			}
+			isDir = target.IsDir()
+		}
+
+		if isDir {
+			// Only directories that can contain compose files within the limit
+			if entryDepth(w.root, path)+1 > w.maxDepth {
+				continue
+			}
+			if w.ignorer.Ignored(path, true) {
+				if !isDefaultExclude(e.Name()) {
+					w.diagnose(DiagnosticSkipped, path, "excluded")
+				}
+				continue
+			}
+			if isLink {
+				w.mu.Lock()
+				w.links = append(w.links, path)
+				w.mu.Unlock()
+			} else {
+				w.enter(path)
+			}
+			continue
+		}
+
+		if entryDepth(w.root, path) > w.maxDepth {
+			continue
+		}
+
+		name := e.Name()
+		if IsComposeFile(name) && w.ignorer.Ignored(path, false) {
+			w.diagnose(DiagnosticSkipped, path, "excluded")
+			continue
+		}
+
+		if name == IgnoreFileName {
+			// Edits to ignore files don't change the directory mtime
+			if info, err := os.Stat(path); err == nil {
+				w.mu.Lock()
+				w.result.ignoreFiles[path] = info.ModTime()
+				w.mu.Unlock()
+			}
+		} else if IsComposeFile(name) && !hidden {
+			composeFiles = append(composeFiles, path)
+		}
+	}
+
+	if len(composeFiles) == 0 {
+		return
+	}
+
+	// Several compose files: use the one compose itself would pick
+	used, others := preferredComposeFile(composeFiles)
+	info, err := os.Stat(used)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	w.result.composeFiles[used] = info
+	if len(others) > 0 {
+		w.result.ignored[used] = others
+	}
+	w.mu.Unlock()
+	if len(others) > 0 {
+		w.diagnose(DiagnosticAmbiguous, dir, fmt.Sprintf("using %s, ignoring %s",
+			filepath.Base(used), strings.Join(baseNames(others), ", ")))
+	}
+}
+
+// baseNames returns the file names of paths
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names
+}
+
+// entryDepth returns the depth of path below root, counting root's own
+// entries as 1 (the depth limit of FindProjects applies to this)
+func entryDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return len(strings.Split(rel, string(os.PathSeparator)))
+}
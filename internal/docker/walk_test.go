@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// symlink creates a symlink at name (below dir) pointing at target
+func symlink(t *testing.T, dir, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+// foundComposeFiles returns the compose files of a walk relative to root
+func foundComposeFiles(t *testing.T, root string, result walkResult) []string {
+	t.Helper()
+	var files []string
+	for path := range result.composeFiles {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+	return files
+}
+
+// diagnosticFor returns the message of the diagnostic for path below root
+func diagnosticFor(result walkResult, root, path string) string {
+	for _, d := range result.diagnostics {
+		if d.Path == filepath.Join(root, path) {
+			return d.Message
+		}
+	}
+	return ""
+}
+
+func TestWalkTreeSymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a/compose.yml": "", "a/b/compose.yml": ""})
+	symlink(t, filepath.Join(root, "a", "b"), root, "up")
+	symlink(t, filepath.Join(root, "a"), filepath.Join(root, "a"), "self")
+
+	result := walkTree(root, root, 10, NewIgnorer(root, nil))
+	if got, want := foundComposeFiles(t, root, result), []string{"a/b/compose.yml", "a/compose.yml"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("compose files %q, want %q", got, want)
+	}
+	for _, link := range []string{"a/b/up", "a/self"} {
+		if msg := diagnosticFor(result, root, link); !strings.HasPrefix(msg, "symlink loop back to") {
+			t.Errorf("%s: diagnostic %q, want a symlink loop", link, msg)
+		}
+	}
+}
+
+func TestWalkTreeSymlinkToVisited(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeFiles(t, base, map[string]string{
+		"root/web/compose.yml":    "",
+		"outside/db/compose.yaml": "",
+	})
+	symlink(t, root, filepath.Join(root, "web"), "alias")        // Same as a real directory
+	symlink(t, root, filepath.Join(base, "outside"), "external") // Followed out of the root
+	symlink(t, root, filepath.Join(base, "outside"), "external2")
+	symlink(t, root, filepath.Join(base, "missing"), "broken")
+
+	result := walkTree(root, root, 10, NewIgnorer(root, nil))
+	// The real path wins over a link, of two links the first in sort order
+	want := []string{"external/db/compose.yaml", "web/compose.yml"}
+	if got := foundComposeFiles(t, root, result); !reflect.DeepEqual(got, want) {
+		t.Errorf("compose files %q, want %q", got, want)
+	}
+	tests := map[string]string{
+		"alias":     "same directory as " + filepath.Join(root, "web"),
+		"external2": "same directory as " + filepath.Join(root, "external"),
+		"broken":    "broken symlink",
+	}
+	for path, want := range tests {
+		if msg := diagnosticFor(result, root, path); msg != want {
+			t.Errorf("%s: diagnostic %q, want %q", path, msg, want)
+		}
+	}
+}
+
+func TestWalkTreeDeep(t *testing.T) {
+	root := t.TempDir()
+	files := make(map[string]string)
+	var want []string
+	// Wide: many more directories than workers
+	for i := 0; i < 5*discoveryWorkers; i++ {
+		name := fmt.Sprintf("wide%02d/compose.yml", i)
+		files[name] = ""
+		want = append(want, name)
+	}
+	// Deep: a chain with a compose file at every level
+	dir := "deep"
+	for depth := 2; depth <= 12; depth++ {
+		dir += fmt.Sprintf("/d%d", depth)
+		files[dir+"/docker-compose.yml"] = ""
+		if depth <= 8 {
+			want = append(want, dir+"/docker-compose.yml")
+		}
+	}
+	writeFiles(t, root, files)
+	sort.Strings(want)
+
+	// Compose files up to 9 levels below the root, so directories up to 8
+	result := walkTree(root, root, 9, NewIgnorer(root, nil))
+	if got := foundComposeFiles(t, root, result); !reflect.DeepEqual(got, want) {
+		t.Errorf("compose files %q, want %q", got, want)
+	}
+	if len(result.diagnostics) != 0 {
+		t.Errorf("diagnostics %+v", result.diagnostics)
+	}
+}
+
+func TestWalkTreeIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".dcmignore":                   "old/\n",
+		"web/compose.yml":              "",
+		"web/docker-compose.yml":       "",
+		"old/app/compose.yml":          "",
+		"hidden/compose.yml":           "",
+		"hidden/" + HideMarkerName:     "",
+		"hidden/nested/compose.yml":    "",
+		"node_modules/pkg/compose.yml": "",
+	})
+
+	result := walkTree(root, root, 10, NewIgnorer(root, nil))
+	want := []string{"hidden/nested/compose.yml", "web/compose.yml"}
+	if got := foundComposeFiles(t, root, result); !reflect.DeepEqual(got, want) {
+		t.Errorf("compose files %q, want %q", got, want)
+	}
+	if others := result.ignored[filepath.Join(root, "web", "compose.yml")]; len(others) != 1 {
+		t.Errorf("ignored next to web/compose.yml: %q", others)
+	}
+	if _, ok := result.ignoreFiles[filepath.Join(root, IgnoreFileName)]; !ok {
+		t.Errorf("%s not recorded", IgnoreFileName)
+	}
+	if msg := diagnosticFor(result, root, "old"); msg != "excluded" {
+		t.Errorf("old: diagnostic %q", msg)
+	}
+	if msg := diagnosticFor(result, root, "node_modules"); msg != "" {
+		t.Errorf("default exclude diagnosed: %q", msg)
+	}
+}
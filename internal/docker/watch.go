@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WatchEventKind is the kind of change reported by a Watcher
+type WatchEventKind int
+
+const (
+	// ComposeFileWritten: a compose file was created, modified or moved into place
+	ComposeFileWritten WatchEventKind = iota
+	// ComposeFileRemoved: the directory has no (usable) compose file anymore
+	ComposeFileRemoved
+	// DirectoryRemoved: a directory was deleted or moved away, taking all
+	// projects at or below Path with it
+	DirectoryRemoved
+)
+
+// WatchEvent is a change below the search root that affects the project list
+type WatchEvent struct {
+	Kind        WatchEventKind
+	Path        string // Project directory (or the removed directory)
+	ComposeFile string // Compose file path, empty for DirectoryRemoved
+}
+
+// A directory with several compose files is one project using the file
+// compose prefers; events always refer to that file.
+
+// Affects reports whether the event concerns project p
+func (e WatchEvent) Affects(p *Project) bool {
+	if p.Host != "" {
+		return false // Only the local search directory is watched
+	}
+	if e.Kind == DirectoryRemoved {
+		return p.Path == e.Path || strings.HasPrefix(p.Path, e.Path+string(os.PathSeparator))
+	}
+	return p.Path == e.Path
+}
+
+// composeFilesBelow walks dir like FindProjects does and returns the compose
+// files found, along with every directory within the depth limit
+func composeFilesBelow(root, dir string, maxDepth int, ignorer *Ignorer) (files, dirs []string) {
+	result := walkTree(root, dir, maxDepth, ignorer)
+	for f := range result.composeFiles {
+		files = append(files, f)
+	}
+	for d := range result.dirs {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(files)
+	sort.Strings(dirs)
+	return files, dirs
+}
+
+// composeFilesIn returns the compose file directly in dir that discovery
+// would pick up (none or one)
+func composeFilesIn(dir string, ignorer *Ignorer) []string {
+	if ignorer.Hidden(dir) {
+		return nil
+	}
+	var files []string
+	for _, name := range composeFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil && !ignorer.Ignored(path, false) {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	used, _ := preferredComposeFile(files)
+	return []string{used}
+}
+
+// projectTracker remembers which compose file each reported project uses,
+// so watchers only report a directory as removed once no usable compose
+// file is left in it
+type projectTracker struct {
+	known map[string]string // Project directory -> compose file
+}
+
+// newProjectTracker creates a tracker for the compose files found initially
+func newProjectTracker(files []string) *projectTracker {
+	t := &projectTracker{known: make(map[string]string, len(files))}
+	for _, f := range files {
+		t.known[filepath.Dir(f)] = f
+	}
+	return t
+}
+
+// apply records an event that is about to be sent
+func (t *projectTracker) apply(ev WatchEvent) {
+	switch ev.Kind {
+	case ComposeFileWritten:
+		t.known[ev.Path] = ev.ComposeFile
+	case ComposeFileRemoved:
+		delete(t.known, ev.Path)
+	case DirectoryRemoved:
+		for dir := range t.known {
+			if ev.Affects(&Project{Path: dir}) {
+				delete(t.known, dir)
+			}
+		}
+	}
+}
+
+// reconcile returns the events turning the known projects in scope into
+// current (the compose files discovery would use now)
+func (t *projectTracker) reconcile(current []string, inScope func(dir string) bool) []WatchEvent {
+	var events []WatchEvent
+	present := make(map[string]bool, len(current))
+	for _, f := range current {
+		dir := filepath.Dir(f)
+		present[dir] = true
+		if t.known[dir] != f {
+			events = append(events, WatchEvent{Kind: ComposeFileWritten, Path: dir, ComposeFile: f})
+		}
+	}
+	for dir, f := range t.known {
+		if inScope(dir) && !present[dir] {
+			events = append(events, WatchEvent{Kind: ComposeFileRemoved, Path: dir, ComposeFile: f})
+		}
+	}
+	return events
+}
+
+// knownBelow reports whether a known project is at or below dir
+func (t *projectTracker) knownBelow(dir string) bool {
+	for d := range t.known {
+		if d == dir || strings.HasPrefix(d, dir+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+//go:build linux
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotify events we care about. Files written in place show up as
+// IN_CLOSE_WRITE, editors that write a temp file and rename it as IN_MOVED_TO.
+const watchMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// Watcher reports compose projects being added, edited and removed below a
+// search root. inotify watches aren't recursive, so every directory within
+// the depth limit gets its own watch and new directories are added as they
+// appear.
+type Watcher struct {
+	Events <-chan WatchEvent
+
+	root     string
+	maxDepth int
+	ignorer  *Ignorer
+	file     *os.File
+	fd       int
+	events   chan WatchEvent
+	done     chan struct{}
+	once     sync.Once
+
+	mu   sync.Mutex
+	dirs map[int]string // Watch descriptor -> directory
+
+	tracker *projectTracker // Projects reported so far (only used by run)
+}
+
+// NewWatcher starts watching root (up to maxDepth levels and skipping
+// excluded paths, like FindProjects)
+func NewWatcher(root string, maxDepth int, exclude []string) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Non-blocking so the fd is handled by the runtime poller and Close
+	// interrupts a pending Read
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	events := make(chan WatchEvent, 64)
+	w := &Watcher{
+		Events:   events,
+		root:     root,
+		maxDepth: maxDepth,
+		ignorer:  NewIgnorer(root, exclude),
+		file:     os.NewFile(uintptr(fd), "inotify"),
+		fd:       fd,
+		events:   events,
+		done:     make(chan struct{}),
+		dirs:     make(map[int]string),
+	}
+
+	files, dirs := composeFilesBelow(root, root, maxDepth, w.ignorer)
+	w.tracker = newProjectTracker(files)
+	for _, dir := range dirs {
+		if err := w.addWatch(dir); err != nil && dir == root {
+			w.file.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Close stops the watcher and closes Events
+func (w *Watcher) Close() error {
+	err := w.file.Close()
+	w.once.Do(func() { close(w.done) })
+	return err
+}
+
+// send delivers an event unless the watcher is closed meanwhile
+func (w *Watcher) send(event WatchEvent) {
+	w.tracker.apply(event)
+
+	select {
+	case w.events <- event:
+	case <-w.done:
+	}
+}
+
+// addWatch adds an inotify watch for dir. Failures below the root (e.g.
+// permission denied, watch limit reached) only mean changes there go unnoticed.
+func (w *Watcher) addWatch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// run reads inotify events until the watcher is closed
+func (w *Watcher) run() {
+	defer close(w.events)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(raw.Len)]), "\x00")
+			offset = nameStart + int(raw.Len)
+
+			w.handle(int(raw.Wd), raw.Mask, name)
+		}
+	}
+}
+
+// handle translates one inotify event into watch events
+func (w *Watcher) handle(wd int, mask uint32, name string) {
+	w.mu.Lock()
+	dir, ok := w.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+	}
+	w.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+
+	path := filepath.Join(dir, name)
+
+	// Symlinks to directories are followed like directories
+	isDir := mask&syscall.IN_ISDIR != 0
+	if !isDir && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			isDir = true
+		}
+	}
+
+	if isDir {
+		switch {
+		case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+			if entryDepth(w.root, path)+1 > w.maxDepth || w.ignorer.excluded(path, true) {
+				return
+			}
+			// Watch the new tree first, then report what's already in it;
+			// files created in between are reported twice at worst
+			files, dirs := composeFilesBelow(w.root, path, w.maxDepth, w.ignorer)
+			for _, d := range dirs {
+				w.addWatch(d)
+			}
+			for _, f := range files {
+				w.send(WatchEvent{Kind: ComposeFileWritten, Path: filepath.Dir(f), ComposeFile: f})
+			}
+		case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+			w.send(WatchEvent{Kind: DirectoryRemoved, Path: path})
+		}
+		return
+	}
+
+	switch name {
+	case IgnoreFileName:
+		w.ignorer.Reset()
+		w.resync()
+		return
+	case HideMarkerName:
+		w.ignorer.Reset()
+		w.resyncDir(dir)
+		return
+	}
+
+	if !IsComposeFile(name) {
+		// A removed symlink doesn't say whether it pointed to a directory
+		if mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0 && w.tracker.knownBelow(path) {
+			w.send(WatchEvent{Kind: DirectoryRemoved, Path: path})
+		}
+		return
+	}
+
+	// Adding or removing one of several compose files may switch the file
+	// the project uses; reconcile reports that and new/removed projects
+	if w.ignorer.excluded(dir, true) {
+		return
+	}
+	current := composeFilesIn(dir, w.ignorer)
+	events := w.tracker.reconcile(current, func(d string) bool { return d == dir })
+	if len(events) == 0 && len(current) == 1 && current[0] == path && mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0 {
+		// Edited in place
+		events = append(events, WatchEvent{Kind: ComposeFileWritten, Path: dir, ComposeFile: path})
+	}
+	for _, ev := range events {
+		w.send(ev)
+	}
+}
+
+// resync rescans the whole tree after ignore rules changed and reports
+// projects that became visible or were excluded
+func (w *Watcher) resync() {
+	files, dirs := composeFilesBelow(w.root, w.root, w.maxDepth, w.ignorer)
+	for _, d := range dirs {
+		w.addWatch(d) // Returns the existing watch for known directories
+	}
+	for _, ev := range w.tracker.reconcile(files, func(string) bool { return true }) {
+		w.send(ev)
+	}
+}
+
+// resyncDir re-evaluates one directory after its hide marker was added or removed
+func (w *Watcher) resyncDir(dir string) {
+	for _, ev := range w.tracker.reconcile(composeFilesIn(dir, w.ignorer), func(d string) bool { return d == dir }) {
+		w.send(ev)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build linux
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitWatchEvent waits for want and returns the events before it. A new
+// file is reported on creation and again when it's written.
+func waitWatchEvent(t *testing.T, w *Watcher, want WatchEvent) []WatchEvent {
+	t.Helper()
+	var before []WatchEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				t.Fatal("events closed")
+			}
+			if ev == want {
+				return before
+			}
+			before = append(before, ev)
+		case <-timeout:
+			t.Fatalf("no event %+v, got %+v", want, before)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"web/compose.yml": "", "tmp/.keep": ""})
+	w, err := NewWatcher(root, 3, []string{"tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	web := filepath.Join(root, "web")
+	tmp := filepath.Join(root, "tmp")
+
+	steps := []struct {
+		name   string
+		change func()
+		want   WatchEvent
+	}{
+		{"edited", func() {
+			writeFiles(t, root, map[string]string{"web/compose.yml": "services: {}\n"})
+		}, WatchEvent{Kind: ComposeFileWritten, Path: web, ComposeFile: filepath.Join(web, "compose.yml")}},
+		{"preferred file added", func() {
+			writeFiles(t, root, map[string]string{"web/compose.yaml": ""})
+		}, WatchEvent{Kind: ComposeFileWritten, Path: web, ComposeFile: filepath.Join(web, "compose.yaml")}},
+		{"excluded project added", func() {
+			writeFiles(t, root, map[string]string{"tmp/app/compose.yml": ""})
+			// The excluded one would be reported first
+			writeFiles(t, root, map[string]string{"apps/api/compose.yml": ""})
+		}, WatchEvent{Kind: ComposeFileWritten, Path: filepath.Join(root, "apps", "api"), ComposeFile: filepath.Join(root, "apps", "api", "compose.yml")}},
+		{"project in a new directory edited", func() {
+			writeFiles(t, root, map[string]string{"apps/api/compose.yml": "services: {}\n"})
+		}, WatchEvent{Kind: ComposeFileWritten, Path: filepath.Join(root, "apps", "api"), ComposeFile: filepath.Join(root, "apps", "api", "compose.yml")}},
+		{"hidden", func() {
+			writeFiles(t, root, map[string]string{"web/" + HideMarkerName: ""})
+		}, WatchEvent{Kind: ComposeFileRemoved, Path: web, ComposeFile: filepath.Join(web, "compose.yaml")}},
+		{"directory removed", func() {
+			os.RemoveAll(filepath.Join(root, "apps"))
+		}, WatchEvent{Kind: DirectoryRemoved, Path: filepath.Join(root, "apps")}},
+	}
+	for _, step := range steps {
+		step.change()
+		// Others are repeated or partial (a removed tree reports its contents)
+		for _, ev := range waitWatchEvent(t, w, step.want) {
+			if ev.Affects(&Project{Path: tmp}) || strings.HasPrefix(ev.Path, tmp) {
+				t.Errorf("%s: event for an excluded path %+v", step.name, ev)
+			}
+		}
+	}
+
+	w.Close()
+	for range w.Events {
+	}
+}
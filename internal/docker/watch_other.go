@@ -0,0 +1,125 @@
+//go:build !linux
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// watchPollInterval is how often the search root is rescanned without inotify
+const watchPollInterval = 5 * time.Second
+
+// Watcher reports compose projects being added, edited and removed below a
+// search root. Without inotify it rescans the tree periodically and compares
+// compose file mtimes.
+type Watcher struct {
+	Events <-chan WatchEvent
+
+	root     string
+	maxDepth int
+	ignorer  *Ignorer
+	events   chan WatchEvent
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewWatcher starts watching root (up to maxDepth levels and skipping
+// excluded paths, like FindProjects)
+func NewWatcher(root string, maxDepth int, exclude []string) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	events := make(chan WatchEvent, 64)
+	w := &Watcher{
+		Events:   events,
+		root:     root,
+		maxDepth: maxDepth,
+		ignorer:  NewIgnorer(root, exclude),
+		events:   events,
+		done:     make(chan struct{}),
+	}
+
+	go w.run(w.snapshot())
+	return w, nil
+}
+
+// Close stops the watcher and closes Events
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+// snapshot maps every compose file below the root to its mtime
+func (w *Watcher) snapshot() map[string]time.Time {
+	w.ignorer.Reset() // Pick up edited .dcmignore files
+	files, _ := composeFilesBelow(w.root, w.root, w.maxDepth, w.ignorer)
+	snap := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			snap[f] = info.ModTime()
+		}
+	}
+	return snap
+}
+
+// run polls until the watcher is closed
+func (w *Watcher) run(previous map[string]time.Time) {
+	defer close(w.events)
+
+	files := make([]string, 0, len(previous))
+	for f := range previous {
+		files = append(files, f)
+	}
+	tracker := newProjectTracker(files)
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot()
+		files = files[:0]
+		for f := range current {
+			files = append(files, f)
+		}
+
+		// New, removed and switched projects, then edits in place
+		events := tracker.reconcile(files, func(string) bool { return true })
+		for f, modTime := range current {
+			if old, ok := previous[f]; ok && !old.Equal(modTime) && tracker.known[filepath.Dir(f)] == f {
+				events = append(events, WatchEvent{Kind: ComposeFileWritten, Path: filepath.Dir(f), ComposeFile: f})
+			}
+		}
+
+		for _, ev := range events {
+			tracker.apply(ev)
+			if !w.send(ev) {
+				return
+			}
+		}
+		previous = current
+	}
+}
+
+// send delivers an event unless the watcher is closed meanwhile
+func (w *Watcher) send(event WatchEvent) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWatchEventAffects(t *testing.T) {
+	p := &Project{Path: "/srv/apps/web"}
+	tests := []struct {
+		ev   WatchEvent
+		want bool
+	}{
+		{WatchEvent{Kind: ComposeFileWritten, Path: "/srv/apps/web"}, true},
+		{WatchEvent{Kind: ComposeFileRemoved, Path: "/srv/apps"}, false},
+		{WatchEvent{Kind: DirectoryRemoved, Path: "/srv/apps"}, true},
+		{WatchEvent{Kind: DirectoryRemoved, Path: "/srv/apps/web"}, true},
+		{WatchEvent{Kind: DirectoryRemoved, Path: "/srv/apps/we"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.ev.Affects(p); got != tt.want {
+			t.Errorf("%+v affects %s = %v, want %v", tt.ev, p.Path, got, tt.want)
+		}
+	}
+	if (WatchEvent{Kind: DirectoryRemoved, Path: "/srv"}).Affects(&Project{Path: "/srv/web", Host: "nas"}) {
+		t.Errorf("event affects a project of another host")
+	}
+}
+
+func TestComposeFilesIn(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"both/docker-compose.yml":  "",
+		"both/compose.yaml":        "",
+		"hidden/compose.yml":       "",
+		"hidden/" + HideMarkerName: "",
+		"ignored/compose.yml":      "",
+		IgnoreFileName:             "ignored/compose.yml\n",
+	})
+	ig := NewIgnorer(root, nil)
+
+	tests := map[string][]string{
+		"both":    {filepath.Join(root, "both", "compose.yaml")},
+		"hidden":  nil,
+		"ignored": nil,
+		"missing": nil,
+	}
+	for dir, want := range tests {
+		if got := composeFilesIn(filepath.Join(root, dir), ig); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestProjectTracker(t *testing.T) {
+	tracker := newProjectTracker([]string{"/srv/web/compose.yml", "/srv/db/compose.yml", "/srv/apps/api/compose.yml"})
+
+	// A switched compose file is written, a missing one removed, only in scope
+	events := tracker.reconcile([]string{"/srv/web/compose.yaml", "/srv/new/compose.yml"}, func(dir string) bool { return dir != "/srv/apps/api" })
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	want := []WatchEvent{
+		{Kind: ComposeFileRemoved, Path: "/srv/db", ComposeFile: "/srv/db/compose.yml"},
+		{Kind: ComposeFileWritten, Path: "/srv/new", ComposeFile: "/srv/new/compose.yml"},
+		{Kind: ComposeFileWritten, Path: "/srv/web", ComposeFile: "/srv/web/compose.yaml"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("reconcile = %+v\nwant %+v", events, want)
+	}
+	for _, ev := range events {
+		tracker.apply(ev)
+	}
+	if events := tracker.reconcile([]string{"/srv/web/compose.yaml", "/srv/new/compose.yml", "/srv/apps/api/compose.yml"}, func(string) bool { return true }); len(events) != 0 {
+		t.Errorf("reconcile after apply = %+v", events)
+	}
+
+	if !tracker.knownBelow("/srv/apps") || tracker.knownBelow("/srv/app") {
+		t.Errorf("knownBelow wrong for /srv/apps or /srv/app")
+	}
+	tracker.apply(WatchEvent{Kind: DirectoryRemoved, Path: "/srv/apps"})
+	if tracker.knownBelow("/srv/apps") {
+		t.Errorf("projects below a removed directory still known")
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,15 @@ import (
 	"github.com/skpharma/docker-compose-manager/internal/docker"
 )
 
+// statusRefreshInterval is how often container statuses are refreshed
+const statusRefreshInterval = 10 * time.Second
+
+// maxHookOutputLines is how many hook output lines per project are shown after an update
+const maxHookOutputLines = 3
+
+// maxPlanLines is how many steps of a dry run plan are shown per project
+const maxPlanLines = 15
+
 // Screen represents different UI screens
 type Screen int
 
@@ -33,11 +43,17 @@ const (
 	ScreenLoading
 	ScreenHelp                  // Help & Documentation screen
 	ScreenConfirmExit
+	ScreenDiagnostics           // Discovery diagnostics (ambiguous, unreadable, skipped paths)
+	ScreenUnmanaged             // Compose stacks with containers that aren't managed projects
+	ScreenUnmanagedAction       // Stop/remove/adopt an unmanaged stack
 )
 
 // Model represents the UI state
 type Model struct {
-	projects          []*docker.Project
+	projects          []*docker.Project // Projects shown (of the selected host), indices refer to this
+	allProjects       []*docker.Project // Projects of all hosts
+	hosts             []*docker.Host    // Hosts of the projects, local (nil) first
+	hostFilter        string            // Name of the host shown, "" for all
 	screen            Screen
 	cursor            int
 	selectedProject   *docker.Project
@@ -51,13 +67,42 @@ type Model struct {
 	updateProgress       string
 	updatesTotal         int               // Total number of updates
 	updatesCompleted     int               // Number of completed updates
-	projectUpdateStatus  map[int]string    // Status for each project: "pending", "updating", "success", "failed"
+	projectUpdateStatus  map[int]string    // Status for each project: "pending", "updating", "backingup", "verifying", "success", "failed", "rolledback"
 	projectUpdateResult  map[int]string    // Result message for each project
 	currentUpdateIndex   int               // Index of project currently being updated
+	updateQueue          map[int]int       // Queue position of each project in the running batch
+	scheduler            *docker.UpdateScheduler   // Limits and orders batch updates
+	strategyOverride     docker.UpdateStrategy            // Strategy of the next batch, empty = each project's own
+	projectStrategies    map[string]docker.UpdateStrategy // Strategy of each selected project by ID, shown on the mode screen
+	backupBeforeUpdate   bool                             // Back up volumes before restarting the next batch
+	backups              []docker.Backup   // Backups of the selected project, newest first
+	loadingBackups       bool              // Backups of the selected project are being listed
+	backupsErr           error             // Listing the backups failed
+	confirmRestore       string            // Restore operation waiting for a second Enter
+	dryRun               bool                     // Show the commands of operations instead of running them
+	plan                 []docker.PlannedCommand  // Steps of the last dry run operation (action menus)
+	projectPlans         map[int][]docker.PlannedCommand // Steps of each project of a dry run batch
+	updateEvents         <-chan docker.UpdateEvent // Progress stream of the running batch
+	cancelUpdates        context.CancelFunc        // Cancels the running batch
+	cancellingUpdates    bool                      // Cancel requested, waiting for running jobs to stop
 	cacheAge             string            // How old is the cache
 	checkingUpdates      bool              // Currently checking for updates
-	currentCheckIndex    int               // Index of project currently being checked (-1 if none)
-	cacheFile            string            // Path to cache file for saving
+	checkingProjects     map[int]bool      // Projects whose check is currently running
+	checksCompleted      int               // Number of projects checked in the current run
+	checker              *docker.UpdateChecker    // Runs update checks with bounded concurrency
+	checkEvents          <-chan docker.CheckEvent // Progress stream of the running check
+	cancelCheck          context.CancelFunc       // Cancels the running update check
+	ctx                  context.Context          // Parent context of all docker operations
+	cache                *docker.Cache     // Project cache for saving
+	options              *docker.Options   // Settings from the config, given to projects added later
+	watcher              *docker.Watcher      // Reports projects added/edited/removed on disk (nil if unavailable)
+	pendingRemovals      []docker.WatchEvent  // Removals deferred until the running check/batch is done
+	refreshingStatus     bool                 // Status refresh is running
+	statusStale          bool                 // Containers changed during the running refresh
+	containerEvents      <-chan docker.ContainerEvent // Live container status changes
+	unmanaged            []docker.UnmanagedStack // Stacks shown on the unmanaged screen
+	loadingUnmanaged     bool                    // Unmanaged stacks are being listed
+	selectedStack        *docker.UnmanagedStack  // Stack of the unmanaged action menu
 	viewportOffset       int               // Scroll offset for long lists
 	width                int               // Terminal width
 	height               int               // Terminal height
@@ -138,26 +183,46 @@ func max(a, b int) int {
 }
 
 // NewModel creates a new UI model
-func NewModel(projects []*docker.Project, cacheFile string, debugMode bool) Model {
+func NewModel(ctx context.Context, projects []*docker.Project, hosts []*docker.Host, cache *docker.Cache, options *docker.Options, checker *docker.UpdateChecker, scheduler *docker.UpdateScheduler, watcher *docker.Watcher, debugMode, dryRun bool) Model {
 	return Model{
+		ctx:                 ctx,
 		projects:            projects,
+		allProjects:         projects,
+		hosts:               hosts,
 		screen:              ScreenMainMenu,
 		cursor:              0,
 		selectedUpdates:     make(map[int]bool),
 		selectedRestarts:    make(map[int]bool),
 		projectUpdateStatus: make(map[int]string),
 		projectUpdateResult: make(map[int]string),
+		projectPlans:        make(map[int][]docker.PlannedCommand),
 		currentUpdateIndex:  -1,
-		currentCheckIndex:   -1,
-		cacheFile:           cacheFile,
+		updateQueue:         make(map[int]int),
+		scheduler:           scheduler,
+		backupBeforeUpdate:  options.Backup.BeforeUpdate,
+		checkingProjects:    make(map[int]bool),
+		checker:             checker,
+		cache:               cache,
+		options:             options,
+		watcher:             watcher,
+		containerEvents:     docker.WatchContainerEvents(ctx, hosts),
 		debugMode:           debugMode,
+		dryRun:              dryRun,
 		viewRenderCount:     0,
 	}
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{
+		checkDrift(m.ctx, m.allProjects),
+		scheduleStatusRefresh(),
+		waitForContainerEvent(m.containerEvents),
+	}
+	if m.watcher != nil {
+		cmds = append(cmds, waitForWatchEvent(m.watcher.Events))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the model
@@ -166,6 +231,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
+			m.cancelRunning()
 			m.quitting = true
 			return m, tea.Quit
 
@@ -176,7 +242,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.cursor > 0 {
 				m.cursor--
 				// Adjust viewport if cursor moves above visible area
-				if (m.screen == ScreenUpdateList || m.screen == ScreenContainerList) && m.cursor < m.viewportOffset {
+				if (m.screen == ScreenUpdateList || m.screen == ScreenContainerList || m.screen == ScreenDiagnostics || m.screen == ScreenUnmanaged) && m.cursor < m.viewportOffset {
 					m.viewportOffset = m.cursor
 				}
 			}
@@ -196,6 +262,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "u", "U":
 			return m.handleRefresh()
 
+		case "s", "S":
+			return m.handleToggleSerial()
+
+		case "t", "T":
+			return m.handleToggleStrategy()
+
+		case "b", "B":
+			return m.handleToggleBackup()
+
+		case "d", "D":
+			return m.handleToggleDryRun()
+
+		case "x", "X":
+			return m.handleCancel()
+
+		case "h", "H":
+			return m.handleSwitchHost()
+
 		case "1", "2", "3", "4", "5", "6", "7", "8", "9", "0":
 			// Direct number selection for menus and lists
 			num := int(msg.String()[0] - '0') // Convert char to int
@@ -211,7 +295,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.loading = false
 		m.message = string(msg)
 		if m.selectedProject != nil {
-			m.selectedProject.UpdateStatus()
+			// Both run docker commands, in the background
+			return m, tea.Batch(m.requestStatusRefresh(), checkDrift(m.ctx, []*docker.Project{m.selectedProject}))
 		}
 		return m, nil
 
@@ -220,6 +305,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg.err
 		return m, nil
 
+	case backupDoneMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+		} else {
+			m.message = msg.message
+		}
+		if m.selectedProject == nil {
+			return m, nil
+		}
+		m.loadingBackups = true
+		return m, tea.Batch(loadBackups(m.ctx, m.selectedProject), checkDrift(m.ctx, []*docker.Project{m.selectedProject}))
+
+	case planMsg:
+		m.loading = false
+		m.plan = msg.steps
+		m.err = msg.err
+		m.message = fmt.Sprintf("Dry run: %d step(s) planned, nothing was executed", len(msg.steps))
+		return m, nil
+
+	case backupsMsg:
+		if m.selectedProject == nil || m.selectedProject.ID() != msg.projectID {
+			return m, nil // Left the project meanwhile
+		}
+		m.loadingBackups = false
+		m.backups = msg.backups
+		m.backupsErr = msg.err
+		return m, nil
+
 	case tickMsg:
 		// Refresh view during updates to override docker output
 		if m.loading {
@@ -229,13 +343,44 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case updateQueuedMsg:
+		m.updateQueue[msg.projectIndex] = msg.position
+		return m, waitForUpdateEvent(m.updateEvents)
+
+	case updateStartedMsg:
+		m.projectUpdateStatus[msg.projectIndex] = "updating"
+		return m, waitForUpdateEvent(m.updateEvents)
+
+	case updateBackingUpMsg:
+		m.projectUpdateStatus[msg.projectIndex] = "backingup"
+		m.projectUpdateResult[msg.projectIndex] = "backing up volumes..."
+		return m, waitForUpdateEvent(m.updateEvents)
+
+	case updateVerifyingMsg:
+		m.projectUpdateStatus[msg.projectIndex] = "verifying"
+		m.projectUpdateResult[msg.projectIndex] = "verifying..."
+		return m, waitForUpdateEvent(m.updateEvents)
+
 	case updateCompleteMsg:
 		m.updatesCompleted++
 
 		// Update status for this project
+		if m.dryRun {
+			m.projectPlans[msg.projectIndex] = msg.plan
+		}
 		if msg.success {
 			m.projectUpdateStatus[msg.projectIndex] = "success"
 			m.projectUpdateResult[msg.projectIndex] = "✓ OK"
+			if m.dryRun {
+				m.projectUpdateResult[msg.projectIndex] = fmt.Sprintf("✓ planned (%d steps)", len(msg.plan))
+			} else if warning := m.projects[msg.projectIndex].UpdateWarning; warning != "" {
+				m.projectUpdateResult[msg.projectIndex] = "⚠ " + warning
+			} else if log := m.projects[msg.projectIndex].HookLog; len(log) > 0 {
+				m.projectUpdateResult[msg.projectIndex] = "✓ " + log[len(log)-1]
+			}
+		} else if docker.IsRolledBack(msg.err) {
+			m.projectUpdateStatus[msg.projectIndex] = "rolledback"
+			m.projectUpdateResult[msg.projectIndex] = fmt.Sprintf("↩ %v", msg.err)
 		} else {
 			m.projectUpdateStatus[msg.projectIndex] = "failed"
 			m.projectUpdateResult[msg.projectIndex] = fmt.Sprintf("✗ %v", msg.err)
@@ -245,45 +390,289 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.updatesCompleted >= m.updatesTotal {
 			m.loading = false
 		}
-		return m, nil
+		return m, waitForUpdateEvent(m.updateEvents)
 
 	case allUpdatesCompleteMsg:
-		// This message is no longer used, but kept for compatibility
-		return m, nil
+		// Scheduler has drained its queue
+		if m.cancelUpdates != nil {
+			m.cancelUpdates()
+			m.cancelUpdates = nil
+		}
+		m.updateEvents = nil
+		m.cancellingUpdates = false
+		m.loading = false
+		m.applyPendingRemovals()
+		// Restarted projects run new containers
+		return m, checkDrift(m.ctx, m.projects)
 
 	case updatesCheckedMsg:
+		if m.cancelCheck != nil {
+			m.cancelCheck()
+			m.cancelCheck = nil
+		}
 		m.checkingUpdates = false
-		m.currentCheckIndex = -1
+		m.checkingProjects = make(map[int]bool)
+		m.checkEvents = nil
 		m.cacheAge = m.calculateCacheAge()
+		m.applyPendingRemovals()
 		// Save updated cache to disk
-		if err := docker.SaveToCache(m.projects, m.cacheFile); err != nil {
+		if err := m.cache.Save(m.allProjects); err != nil {
 			m.message = fmt.Sprintf("Warning: failed to save cache: %v", err)
 		}
 		return m, nil
 
 	case projectCheckProgressMsg:
-		m.currentCheckIndex = msg.index
-		return m, m.checkSingleProject(msg.index)
-
-	case projectCheckCompleteMsg:
-		// Move to next project
-		nextIndex := msg.index + 1
-		if nextIndex < len(m.projects) {
-			// Check next project
-			return m, func() tea.Msg {
-				return projectCheckProgressMsg{index: nextIndex}
-			}
+		if msg.done {
+			msg.project.ApplyCheck(msg.result)
+			delete(m.checkingProjects, msg.index)
+			m.checksCompleted++
 		} else {
-			// All done
-			return m, func() tea.Msg {
-				return updatesCheckedMsg{}
+			m.checkingProjects[msg.index] = true
+		}
+		// Keep listening for the next event of the running check
+		return m, waitForCheckEvent(m.checkEvents)
+
+	case watchEventMsg:
+		if msg.event.Kind == docker.ComposeFileWritten {
+			return m, tea.Batch(loadWatchedProject(m.ctx, msg.event.ComposeFile), waitForWatchEvent(m.watcher.Events))
+		}
+		m.queueRemoval(msg.event)
+		return m, waitForWatchEvent(m.watcher.Events)
+
+	case watchedProjectMsg:
+		for _, p := range m.allProjects {
+			if p.Host == "" && p.Path == msg.project.Path {
+				// Edited compose file: refresh what the edit may have changed,
+				// keep update check results
+				p.ComposeFile = msg.project.ComposeFile
+				p.ComposeModTime = msg.project.ComposeModTime
+				p.Status = msg.project.Status
+				p.RunningContainers = msg.project.RunningContainers
+				p.ComposeChanged = msg.project.ComposeChanged
+				p.Warnings = msg.project.Warnings
+				if p.ComposeChanged {
+					m.message = fmt.Sprintf("%s: compose file changed since containers were created", p.Name)
+				}
+				return m, checkDrift(m.ctx, []*docker.Project{p})
+			}
+		}
+		// New projects are appended so existing indices stay valid
+		m.addProject(msg.project)
+		m.message = fmt.Sprintf("New project found: %s", msg.project.Name)
+		return m, checkDrift(m.ctx, []*docker.Project{msg.project})
+
+	case driftCheckedMsg:
+		for _, p := range msg.projects {
+			result, ok := msg.results[p.ID()]
+			p.Drift, p.LockState, p.DriftChecked = result.Drift, result.Lock, ok
+		}
+		return m, nil
+
+	case strategiesResolvedMsg:
+		m.projectStrategies = msg.strategies
+		return m, nil
+
+	case statusTickMsg:
+		// Fallback for changes the event stream missed
+		return m, tea.Batch(m.requestStatusRefresh(), scheduleStatusRefresh())
+
+	case containerEventMsg:
+		// A burst of events (e.g. compose up) collapses into one or two refreshes
+		return m, tea.Batch(m.requestStatusRefresh(), waitForContainerEvent(m.containerEvents))
+
+	case unmanagedMsg:
+		m.loadingUnmanaged = false
+		m.unmanaged = msg.stacks
+		m.err = msg.err
+		if m.cursor >= len(m.unmanaged) {
+			m.cursor = max(0, len(m.unmanaged)-1)
+		}
+		return m, nil
+
+	case unmanagedDoneMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.message = msg.message
+		m.screen = ScreenUnmanaged
+		m.cursor = 0
+		m.viewportOffset = 0
+		m.loadingUnmanaged = true
+
+		var cmds []tea.Cmd
+		if p := msg.adopted; p != nil {
+			m.adoptProject(p)
+			cmds = append(cmds, checkDrift(m.ctx, []*docker.Project{p}))
+		}
+		cmds = append(cmds, findUnmanaged(m.ctx, m.hosts, m.allProjects, m.cache.SearchRoots))
+		return m, tea.Batch(cmds...)
+
+	case statusesMsg:
+		// Applied here rather than in the command, the view reads the projects
+		for _, p := range msg.projects {
+			if s, ok := msg.statuses[p.ID()]; ok {
+				p.ApplyStatus(s)
+			}
+		}
+		m.refreshingStatus = false
+		if m.statusStale {
+			return m, m.requestStatusRefresh()
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+// queueRemoval removes the projects affected by ev, or defers that while a
+// check or batch is running
+func (m *Model) queueRemoval(ev docker.WatchEvent) {
+	if m.checkingUpdates || m.loading {
+		// Removing shifts project indices, which the running
+		// check or batch still refers to
+		m.pendingRemovals = append(m.pendingRemovals, ev)
+		return
+	}
+	m.removeProjects(ev)
+}
+
+// addProject adds a new project; it's appended so existing indices stay valid
+func (m *Model) addProject(p *docker.Project) {
+	p.SetOptions(m.options)
+	m.allProjects = append(m.allProjects, p)
+	if m.hostVisible(p) {
+		m.projects = append(m.projects, p)
+	}
+}
+
+// hostVisible reports whether p is on the host currently shown
+func (m Model) hostVisible(p *docker.Project) bool {
+	return m.hostFilter == "" || p.HostOf().HostName() == m.hostFilter
+}
+
+// handleSwitchHost cycles through showing all hosts and each single host
+func (m Model) handleSwitchHost() (tea.Model, tea.Cmd) {
+	if len(m.hosts) < 2 || m.checkingUpdates || m.loading {
+		return m, nil
+	}
+	switch m.screen {
+	case ScreenMainMenu, ScreenContainerList, ScreenUpdateList:
+	default:
+		return m, nil
+	}
+
+	next := ""
+	if m.hostFilter == "" {
+		next = m.hosts[0].HostName()
+	} else {
+		for i, h := range m.hosts {
+			if h.HostName() == m.hostFilter && i+1 < len(m.hosts) {
+				next = m.hosts[i+1].HostName()
 			}
 		}
 	}
+	m.hostFilter = next
 
+	// Indices change, so selections start over
+	m.projects = nil
+	for _, p := range m.allProjects {
+		if m.hostVisible(p) {
+			m.projects = append(m.projects, p)
+		}
+	}
+	m.selectedUpdates = make(map[int]bool)
+	m.selectedRestarts = make(map[int]bool)
+	m.projectUpdateStatus = make(map[int]string)
+	m.projectUpdateResult = make(map[int]string)
+	m.projectPlans = make(map[int][]docker.PlannedCommand)
+	m.updateQueue = make(map[int]int)
+	if m.screen != ScreenMainMenu {
+		m.cursor = 0
+	}
+	m.viewportOffset = 0
 	return m, nil
 }
 
+// hostLabel describes the hosts currently shown
+func (m Model) hostLabel() string {
+	if m.hostFilter == "" {
+		return fmt.Sprintf("all %d hosts", len(m.hosts))
+	}
+	return m.hostFilter
+}
+
+// applyPendingRemovals applies project removals deferred during a check or batch
+func (m *Model) applyPendingRemovals() {
+	for _, ev := range m.pendingRemovals {
+		m.removeProjects(ev)
+	}
+	m.pendingRemovals = nil
+}
+
+// removeProjects drops all projects affected by a removal event and shifts
+// the index-based selections accordingly
+func (m *Model) removeProjects(ev docker.WatchEvent) {
+	var all []*docker.Project
+	var removed []string
+	for _, p := range m.allProjects {
+		if ev.Affects(p) {
+			removed = append(removed, p.Name)
+			if p == m.selectedProject {
+				m.selectedProject = nil
+			}
+			continue
+		}
+		all = append(all, p)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	m.allProjects = all
+
+	newIndex := make(map[int]int, len(m.projects))
+	var kept []*docker.Project
+	for i, p := range m.projects {
+		if ev.Affects(p) {
+			continue
+		}
+		newIndex[i] = len(kept)
+		kept = append(kept, p)
+	}
+
+	m.projects = kept
+	m.selectedUpdates = remapIndices(m.selectedUpdates, newIndex)
+	m.selectedRestarts = remapIndices(m.selectedRestarts, newIndex)
+	m.projectUpdateStatus = remapIndices(m.projectUpdateStatus, newIndex)
+	m.projectUpdateResult = remapIndices(m.projectUpdateResult, newIndex)
+	m.projectPlans = remapIndices(m.projectPlans, newIndex)
+	m.updateQueue = remapIndices(m.updateQueue, newIndex)
+
+	if m.cursor >= len(m.projects) {
+		m.cursor = max(0, len(m.projects)-1)
+	}
+	if m.viewportOffset > m.cursor {
+		m.viewportOffset = m.cursor
+	}
+	if m.selectedProject == nil && (m.screen == ScreenContainerDetail || m.screen == ScreenActionMenu) {
+		m.screen = ScreenContainerList
+	}
+	m.message = fmt.Sprintf("Project removed: %s", strings.Join(removed, ", "))
+}
+
+// remapIndices moves the entries of an index-keyed map to their new indices,
+// dropping entries of removed projects
+func remapIndices[V any](old map[int]V, newIndex map[int]int) map[int]V {
+	remapped := make(map[int]V, len(old))
+	for i, v := range old {
+		if j, ok := newIndex[i]; ok {
+			remapped[j] = v
+		}
+	}
+	return remapped
+}
+
 // handleBack handles back/escape navigation
 func (m Model) handleBack() (tea.Model, tea.Cmd) {
 	switch m.screen {
@@ -306,9 +695,14 @@ func (m Model) handleBack() (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case ScreenActionMenu:
+		if m.loading {
+			return m, nil // Backups and restores can't be interrupted
+		}
 		m.screen = ScreenContainerDetail
 		m.cursor = 0
 		m.message = ""
+		m.confirmRestore = ""
+		m.plan = nil
 		return m, nil
 
 	case ScreenHelp:
@@ -317,6 +711,31 @@ func (m Model) handleBack() (tea.Model, tea.Cmd) {
 		m.message = ""
 		return m, nil
 
+	case ScreenDiagnostics:
+		m.screen = ScreenMainMenu
+		m.cursor = 3
+		m.viewportOffset = 0
+		return m, nil
+
+	case ScreenUnmanaged:
+		m.screen = ScreenMainMenu
+		m.cursor = 4
+		m.viewportOffset = 0
+		m.message = ""
+		m.err = nil
+		return m, nil
+
+	case ScreenUnmanagedAction:
+		if m.loading {
+			return m, nil
+		}
+		m.screen = ScreenUnmanaged
+		m.cursor = 0
+		m.viewportOffset = 0
+		m.err = nil
+		m.plan = nil
+		return m, nil
+
 	case ScreenUpdateList:
 		m.screen = ScreenMainMenu
 		m.cursor = 0
@@ -359,10 +778,31 @@ func (m Model) handleBack() (tea.Model, tea.Cmd) {
 func (m Model) handleDown() (tea.Model, tea.Cmd) {
 	switch m.screen {
 	case ScreenMainMenu:
-		if m.cursor < 2 {
+		if m.cursor < 4 {
 			m.cursor++
 		}
 
+	case ScreenUnmanaged:
+		if m.cursor < len(m.unmanaged)-1 {
+			m.cursor++
+			if m.cursor >= m.viewportOffset+maxVisibleItems {
+				m.viewportOffset = m.cursor - maxVisibleItems + 1
+			}
+		}
+
+	case ScreenUnmanagedAction:
+		if m.cursor < len(m.unmanagedOptions())-1 {
+			m.cursor++
+		}
+
+	case ScreenDiagnostics:
+		if m.cursor < len(m.cache.Diagnostics)-1 {
+			m.cursor++
+			if m.cursor >= m.viewportOffset+maxVisibleItems {
+				m.viewportOffset = m.cursor - maxVisibleItems + 1
+			}
+		}
+
 	case ScreenContainerList:
 		if m.cursor < len(m.projects)-1 {
 			m.cursor++
@@ -373,11 +813,7 @@ func (m Model) handleDown() (tea.Model, tea.Cmd) {
 		}
 
 	case ScreenActionMenu:
-		maxOptions := 1
-		if m.selectedProject != nil && m.selectedProject.IsRunning() {
-			maxOptions = 2
-		}
-		if m.cursor < maxOptions {
+		if m.cursor < len(m.actionOptions())-1 {
 			m.cursor++
 		}
 
@@ -442,15 +878,48 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 			m.screen = ScreenHelp
 			m.cursor = 0
 			return m, nil
+
+		case 3: // Discovery Diagnostics
+			m.screen = ScreenDiagnostics
+			m.cursor = 0
+			m.viewportOffset = 0
+			return m, nil
+
+		case 4: // Unmanaged Stacks
+			m.screen = ScreenUnmanaged
+			m.cursor = 0
+			m.viewportOffset = 0
+			m.message = ""
+			m.err = nil
+			m.unmanaged = nil
+			m.loadingUnmanaged = true
+			return m, findUnmanaged(m.ctx, m.hosts, m.allProjects, m.cache.SearchRoots)
 		}
 
+	case ScreenUnmanaged:
+		if m.cursor < len(m.unmanaged) {
+			stack := m.unmanaged[m.cursor]
+			m.selectedStack = &stack
+			m.screen = ScreenUnmanagedAction
+			m.cursor = 0
+			m.message = ""
+			m.err = nil
+			return m, nil
+		}
+
+	case ScreenUnmanagedAction:
+		return m.handleUnmanagedAction()
+
 	case ScreenContainerList:
 		if m.cursor < len(m.projects) {
 			m.selectedProject = m.projects[m.cursor]
 			m.screen = ScreenContainerDetail
 			m.cursor = 0
 			m.message = ""
-			return m, nil
+			m.backups = nil
+			m.backupsErr = nil
+			m.loadingBackups = true
+			return m, loadBackups(m.ctx, m.selectedProject)
 		}
 
 	case ScreenContainerDetail:
@@ -467,7 +936,8 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 		if len(m.selectedUpdates) > 0 {
 			m.screen = ScreenUpdateModeSelect
 			m.cursor = 0
-			return m, nil
+			m.projectStrategies = nil
+			return m, resolveStrategies(m.ctx, m.selectedProjects())
 		}
 
 	case ScreenUpdateModeSelect:
@@ -488,7 +958,8 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 				m.projectUpdateResult[idx] = ""
 			}
 			m.currentUpdateIndex = -1
-			return m, m.performUpdates()
+			cmd := m.performUpdates()
+			return m, cmd
 		} else {
 			// Pull & Restart - go to restart confirmation
 			m.updateMode = "restart"
@@ -518,8 +989,9 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 		}
 		m.currentUpdateIndex = -1
 		// Start ticker to refresh view during updates
+		cmd := m.performUpdates()
 		return m, tea.Batch(
-			m.performUpdates(),
+			cmd,
 			tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
 				return tickMsg{}
 			}),
@@ -541,8 +1013,9 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 			}
 			m.currentUpdateIndex = -1
 			// Start ticker to refresh view during updates
+			cmd := m.performUpdates()
 			return m, tea.Batch(
-				m.performUpdates(),
+				cmd,
 				tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
 					return tickMsg{}
 				}),
@@ -586,25 +1059,129 @@ func (m Model) handleAction() (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	options := m.actionOptions()
+	if m.cursor >= len(options) || m.loading {
+		return m, nil
+	}
+
+	operation := options[m.cursor].operation
+	if strings.HasPrefix(operation, "restore:") && m.confirmRestore != operation {
+		// Restoring overwrites the volumes, ask first
+		m.confirmRestore = operation
+		m.message = ""
+		m.err = nil
+		return m, nil
+	}
+	m.confirmRestore = ""
+
 	m.loading = true
 	m.err = nil
+	m.message = ""
+	m.plan = nil
+	if m.dryRun {
+		return m, dryRunOperation(m.ctx, func(ctx context.Context) tea.Cmd {
+			return performOperation(ctx, m.selectedProject, operation)
+		})
+	}
+	return m, performOperation(m.ctx, m.selectedProject, operation)
+}
 
-	if m.selectedProject.IsRunning() {
-		// Running: Stop or Restart
-		switch m.cursor {
-		case 0: // Stop
-			return m, performOperation(m.selectedProject, "stop")
-		case 1: // Restart
-			return m, performOperation(m.selectedProject, "restart")
-		}
+// actionOption is an entry of the action menu
+type actionOption struct {
+	label     string
+	operation string // Passed to performOperation
+}
+
+// actionOptions returns the actions available for the selected project
+func (m Model) actionOptions() []actionOption {
+	if m.selectedProject == nil {
+		return nil
+	}
+	var options []actionOption
+	if !m.selectedProject.IsRunning() {
+		// Starting applies the current compose file anyway
+		options = append(options, actionOption{"Start containers", "start"})
 	} else {
-		// Stopped: Start
-		if m.cursor == 0 {
-			return m, performOperation(m.selectedProject, "start")
+		options = append(options,
+			actionOption{"Stop containers", "stop"},
+			actionOption{"Restart containers", "restart"},
+		)
+		if len(m.selectedProject.Drift) > 0 {
+			options = append(options, actionOption{"Apply compose file (up -d)", "apply"})
 		}
 	}
 
-	return m, nil
+	options = append(options, actionOption{"Back up volumes now", "backup"})
+	for _, backup := range m.backups {
+		label := fmt.Sprintf("Restore backup of %s", backup.Created.Local().Format("2006-01-02 15:04:05"))
+		options = append(options, actionOption{label, "restore:" + backup.ID})
+	}
+	return options
+}
+
+// handleUnmanagedAction runs the selected action of the unmanaged action menu
+func (m Model) handleUnmanagedAction() (tea.Model, tea.Cmd) {
+	if m.selectedStack == nil || m.loading {
+		return m, nil
+	}
+
+	options := m.unmanagedOptions()
+	if m.cursor >= len(options) {
+		return m, nil
+	}
+
+	m.loading = true
+	m.err = nil
+	m.plan = nil
+	if m.dryRun {
+		stack, operation := *m.selectedStack, options[m.cursor].operation
+		if operation == "adopt" {
+			// Only remembered in the cache, nothing to run
+			return m, func() tea.Msg {
+				return planMsg{steps: []docker.PlannedCommand{{Note: "adopt " + stack.WorkingDir + " as managed project"}}}
+			}
+		}
+		return m, dryRunOperation(m.ctx, func(ctx context.Context) tea.Cmd {
+			return performUnmanagedOperation(ctx, stack, operation)
+		})
+	}
+	return m, performUnmanagedOperation(m.ctx, *m.selectedStack, options[m.cursor].operation)
+}
+
+// unmanagedOptions returns the actions available for the selected stack
+func (m Model) unmanagedOptions() []actionOption {
+	if m.selectedStack == nil {
+		return nil
+	}
+
+	var options []actionOption
+	if m.selectedStack.Running > 0 {
+		options = append(options, actionOption{"Stop containers", "stop"})
+	}
+	options = append(options, actionOption{"Remove containers (volumes are kept)", "remove"})
+	if m.selectedStack.CanAdopt() {
+		options = append(options, actionOption{"Adopt directory as managed project", "adopt"})
+	}
+	return options
+}
+
+// adoptProject adds a project adopted from the unmanaged screen and
+// remembers its directory in the cache, so it stays managed
+func (m *Model) adoptProject(p *docker.Project) {
+	for _, known := range m.allProjects {
+		if known.Host == "" && known.Path == p.Path {
+			return
+		}
+	}
+
+	m.addProject(p)
+	m.cache.Adopted = append(m.cache.Adopted, p.Path)
+	if info, err := os.Stat(p.Path); err == nil && m.cache.Directories != nil {
+		m.cache.Directories[p.Path] = info.ModTime()
+	}
+	if err := m.cache.Save(m.allProjects); err != nil {
+		m.message += fmt.Sprintf(" (failed to save cache: %v)", err)
+	}
 }
 
 // handleSpace handles space key for toggling selections
@@ -613,6 +1190,8 @@ func (m Model) handleSpace() (tea.Model, tea.Cmd) {
 		// Toggle selection for current project
 		if m.selectedUpdates[m.cursor] {
 			delete(m.selectedUpdates, m.cursor)
+		} else if m.cursor < len(m.projects) && m.projects[m.cursor].UpdatePolicy() == docker.PolicyPinned {
+			m.message = fmt.Sprintf("%s is pinned (dcm.update.policy), it can't be updated", m.projects[m.cursor].Name)
 		} else {
 			m.selectedUpdates[m.cursor] = true
 		}
@@ -642,11 +1221,21 @@ func (m Model) handleSpace() (tea.Model, tea.Cmd) {
 // handleSelectAll handles 'a' key for selecting/deselecting all
 func (m Model) handleSelectAll() (tea.Model, tea.Cmd) {
 	if m.screen == ScreenUpdateList {
-		// If all selected, deselect all. Otherwise select all
-		if len(m.selectedUpdates) == len(m.projects) {
+		// Select all projects that update automatically, or deselect all
+		// if they already are. Notify-only projects are selected by hand.
+		var auto []int
+		allSelected := true
+		for i, p := range m.projects {
+			switch p.UpdatePolicy() {
+			case docker.PolicyAuto, docker.PolicyPatch:
+				auto = append(auto, i)
+				allSelected = allSelected && m.selectedUpdates[i]
+			}
+		}
+		if allSelected {
 			m.selectedUpdates = make(map[int]bool)
 		} else {
-			for i := range m.projects {
+			for _, i := range auto {
 				m.selectedUpdates[i] = true
 			}
 		}
@@ -656,13 +1245,101 @@ func (m Model) handleSelectAll() (tea.Model, tea.Cmd) {
 
 // handleRefresh handles 'r' key for manually refreshing update checks
 func (m Model) handleRefresh() (tea.Model, tea.Cmd) {
+	if m.screen == ScreenUnmanaged && !m.loadingUnmanaged {
+		m.loadingUnmanaged = true
+		m.message = ""
+		m.err = nil
+		return m, findUnmanaged(m.ctx, m.hosts, m.allProjects, m.cache.SearchRoots)
+	}
 	if m.screen == ScreenUpdateList && !m.checkingUpdates {
 		m.checkingUpdates = true
-		return m, m.checkForUpdates()
+		m.checksCompleted = 0
+		m.checkingProjects = make(map[int]bool)
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.cancelCheck = cancel
+		m.checkEvents = m.checker.Run(ctx, m.projects)
+		return m, waitForCheckEvent(m.checkEvents)
+	}
+	return m, nil
+}
+
+// handleCancel handles 'x' key for cancelling a running update check or batch update
+func (m Model) handleCancel() (tea.Model, tea.Cmd) {
+	switch m.screen {
+	case ScreenUpdateList:
+		if m.checkingUpdates && m.cancelCheck != nil {
+			m.cancelCheck()
+		}
+	case ScreenUpdating:
+		if m.loading && m.cancelUpdates != nil {
+			m.cancelUpdates()
+			m.cancellingUpdates = true
+		}
+	}
+	return m, nil
+}
+
+// cancelRunning interrupts all running checks and updates
+func (m Model) cancelRunning() {
+	if m.cancelCheck != nil {
+		m.cancelCheck()
+	}
+	if m.cancelUpdates != nil {
+		m.cancelUpdates()
+	}
+}
+
+// handleToggleSerial handles 's' key for switching between parallel and serial updates
+func (m Model) handleToggleSerial() (tea.Model, tea.Cmd) {
+	if m.screen == ScreenUpdateModeSelect || m.screen == ScreenUpdateRestartConfirm {
+		m.scheduler.Serial = !m.scheduler.Serial
+	}
+	return m, nil
+}
+
+// handleToggleStrategy handles 't' key for cycling the update strategy of the
+// next batch: each project's own, then every strategy for all of them
+func (m Model) handleToggleStrategy() (tea.Model, tea.Cmd) {
+	if m.screen != ScreenUpdateModeSelect && m.screen != ScreenUpdateRestartConfirm {
+		return m, nil
+	}
+	if m.strategyOverride == "" {
+		m.strategyOverride = docker.Strategies[0]
+		return m, nil
+	}
+	for i, strategy := range docker.Strategies {
+		if strategy == m.strategyOverride {
+			if i+1 < len(docker.Strategies) {
+				m.strategyOverride = docker.Strategies[i+1]
+			} else {
+				m.strategyOverride = ""
+			}
+			break
+		}
+	}
+	return m, nil
+}
+
+// handleToggleBackup handles 'b' key for backing up volumes before the
+// restarts of the next batch
+func (m Model) handleToggleBackup() (tea.Model, tea.Cmd) {
+	if m.screen == ScreenUpdateModeSelect || m.screen == ScreenUpdateRestartConfirm {
+		m.backupBeforeUpdate = !m.backupBeforeUpdate
 	}
 	return m, nil
 }
 
+// handleToggleDryRun handles 'd' key for switching between running
+// operations and only showing their commands
+func (m Model) handleToggleDryRun() (tea.Model, tea.Cmd) {
+	if m.loading {
+		return m, nil // Applies to operations started afterwards
+	}
+	m.dryRun = !m.dryRun
+	m.plan = nil
+	return m, nil
+}
+
 // handleNumberKey handles direct number selection (1-9, 0 for 10)
 func (m Model) handleNumberKey(num int) (tea.Model, tea.Cmd) {
 	// 0 represents 10
@@ -672,8 +1349,8 @@ func (m Model) handleNumberKey(num int) (tea.Model, tea.Cmd) {
 
 	switch m.screen {
 	case ScreenMainMenu:
-		// Main menu has 3 options
-		if num >= 1 && num <= 3 {
+		// Main menu has 5 options
+		if num >= 1 && num <= 5 {
 			m.cursor = num - 1
 			return m.handleEnter()
 		}
@@ -707,12 +1384,8 @@ func (m Model) handleNumberKey(num int) (tea.Model, tea.Cmd) {
 		}
 
 	case ScreenActionMenu:
-		// Action menu - 1 or 2 options depending on state
-		maxOptions := 1
-		if m.selectedProject != nil && m.selectedProject.IsRunning() {
-			maxOptions = 2
-		}
-		if num >= 1 && num <= maxOptions {
+		// Action menu - number of options depends on state
+		if num >= 1 && num <= len(m.actionOptions()) {
 			m.cursor = num - 1
 			return m.handleEnter()
 		}
@@ -735,28 +1408,52 @@ func (m Model) handleNumberKey(num int) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// calculateCacheAge calculates how old the cached data is
+// calculateCacheAge calculates how old the cached update check results are
 func (m Model) calculateCacheAge() string {
-	if len(m.projects) == 0 {
-		return "unknown"
-	}
-
-	// Find the oldest LastUpdated time
-	oldestTime := m.projects[0].LastUpdated
+	// Find the oldest and newest image check
+	var oldestTime, newestTime time.Time
 	for _, p := range m.projects {
-		if p.LastUpdated.Before(oldestTime) {
-			oldestTime = p.LastUpdated
+		for _, img := range p.ImageInfo {
+			if img.CheckedAt.IsZero() {
+				continue
+			}
+			if oldestTime.IsZero() || img.CheckedAt.Before(oldestTime) {
+				oldestTime = img.CheckedAt
+			}
+			if img.CheckedAt.After(newestTime) {
+				newestTime = img.CheckedAt
+			}
 		}
 	}
 
-	// Calculate age
-	age := oldestTime
-	if age.IsZero() {
-		return "unknown"
+	if oldestTime.IsZero() {
+		return "never"
 	}
 
 	// Format as human-readable string
-	return age.Format("2006-01-02 15:04")
+	if newestTime.Sub(oldestTime) < time.Minute {
+		return newestTime.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s to %s", oldestTime.Format("2006-01-02 15:04"), newestTime.Format("2006-01-02 15:04"))
+}
+
+// formatAge formats how long ago t was in a compact form (e.g. "5m", "3h", "2d")
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
 }
 
 // View renders the UI
@@ -777,6 +1474,8 @@ func (m Model) View() string {
 		view = m.viewContainerDetail()
 	case ScreenActionMenu:
 		view = m.viewActionMenu()
+	case ScreenDiagnostics:
+		view = m.viewDiagnostics()
 	case ScreenUpdateList:
 		view = m.viewUpdateList()
 	case ScreenUpdateModeSelect:
@@ -793,10 +1492,18 @@ func (m Model) View() string {
 		view = m.viewHelp()
 	case ScreenConfirmExit:
 		view = m.viewConfirmExit()
+	case ScreenUnmanaged:
+		view = m.viewUnmanaged()
+	case ScreenUnmanagedAction:
+		view = m.viewUnmanagedAction()
 	default:
 		view = "Unknown screen"
 	}
 
+	if m.dryRun {
+		view = styleWarning.Render("DRY RUN - commands are shown, not executed (d to toggle)") + "\n" + view
+	}
+
 	// Debug logging
 	if m.debugMode {
 		m.logViewToFile(view)
@@ -847,11 +1554,17 @@ func (m Model) viewMainMenu() string {
 
 	b.WriteString(styleTitle.Render("Docker Compose Manager v2.1"))
 	b.WriteString("\n\n")
+	if len(m.hosts) > 1 {
+		b.WriteString(styleMuted.Render(fmt.Sprintf("Hosts: %s (h to switch)", m.hostLabel())))
+		b.WriteString("\n\n")
+	}
 
 	options := []string{
 		"Manage Containers (Start/Stop/Restart)",
 		"Perform Updates",
 		"Help & Documentation",
+		fmt.Sprintf("Discovery Diagnostics (%d)", len(m.cache.Diagnostics)),
+		"Unmanaged Stacks",
 	}
 
 	for i, option := range options {
@@ -867,7 +1580,7 @@ func (m Model) viewMainMenu() string {
 	}
 
 	b.WriteString("\n")
-	b.WriteString(styleHelp.Render("Use ↑/↓ or 1-3 to navigate, Enter to select, q to exit"))
+	b.WriteString(styleHelp.Render("Use ↑/↓ or 1-5 to navigate, Enter to select, q to exit"))
 
 	if m.message != "" {
 		b.WriteString("\n\n")
@@ -881,7 +1594,11 @@ func (m Model) viewMainMenu() string {
 func (m Model) viewContainerList() string {
 	var b strings.Builder
 
-	b.WriteString(styleTitle.Render("Manage Containers"))
+	title := "Manage Containers"
+	if len(m.hosts) > 1 {
+		title += " - " + m.hostLabel()
+	}
+	b.WriteString(styleTitle.Render(title))
 	b.WriteString("\n\n")
 
 	// Calculate visible range for scrolling
@@ -925,24 +1642,252 @@ func (m Model) viewContainerList() string {
 			statusStyled = styleMuted.Render(status)
 		}
 
-		b.WriteString(fmt.Sprintf("%s %s %s %s\n", cursor, number, paddedName, statusStyled))
+		// Containers differ from the compose file. Until drift is known,
+		// fall back to comparing the compose file mtime with the containers.
+		if project.DriftChecked {
+			if len(project.Drift) > 0 {
+				statusStyled += " " + styleWarning.Render(fmt.Sprintf("⚠ drift (%d)", len(project.Drift)))
+			}
+		} else if project.ComposeChanged {
+			statusStyled += " " + styleWarning.Render("⚠ compose changed")
+		}
+		if len(project.Unhealthy) > 0 {
+			statusStyled += " " + styleError.Render(fmt.Sprintf("✗ unhealthy (%d)", len(project.Unhealthy)))
+		}
+		switch project.LockState {
+		case docker.LockLocked:
+			statusStyled += " " + styleMuted.Render("🔒")
+		case docker.LockPartial:
+			statusStyled += " " + styleMuted.Render("🔒 partial")
+		case docker.LockStale:
+			statusStyled += " " + styleWarning.Render("🔒 not applied")
+		}
+
+		host := ""
+		if len(m.hosts) > 1 {
+			host = styleMuted.Render(fmt.Sprintf("%-12s", truncateMiddle(project.HostOf().HostName(), 12))) + " "
+		}
+
+		b.WriteString(fmt.Sprintf("%s %s %s %s%s\n", cursor, number, paddedName, host, statusStyled))
+	}
+
+	// Bottom scroll indicator (fixed space)
+	if viewEnd < len(m.projects) {
+		b.WriteString(styleMuted.Render("▼ More below - scroll down\n"))
+	} else {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := "Use ↑/↓ or 1-9/0 to navigate, Enter to select, Esc/q to go back"
+	if len(m.hosts) > 1 {
+		help += ", h to switch host"
+	}
+	b.WriteString(styleHelp.Render(help))
+
+	if m.message != "" {
+		b.WriteString("\n\n")
+		b.WriteString(styleInfo.Render(m.message))
+	}
+
+	return styleBox.Render(b.String())
+}
+
+// viewDiagnostics renders the problems found during project discovery
+func (m Model) viewDiagnostics() string {
+	var b strings.Builder
+
+	b.WriteString(styleTitle.Render("Discovery Diagnostics"))
+	b.WriteString("\n\n")
+
+	diagnostics := m.cache.Diagnostics
+	if len(diagnostics) == 0 {
+		b.WriteString(styleSuccess.Render("✓ No problems found during the last scan"))
+		b.WriteString("\n\n")
+		b.WriteString(styleHelp.Render("Esc/q to go back"))
+		return styleBox.Render(b.String())
+	}
+
+	viewStart := m.viewportOffset
+	viewEnd := m.viewportOffset + maxVisibleItems
+	if viewEnd > len(diagnostics) {
+		viewEnd = len(diagnostics)
+	}
+
+	if viewStart > 0 {
+		b.WriteString(styleMuted.Render("▲ More above - scroll up\n"))
+	} else {
+		b.WriteString("\n")
+	}
+
+	for i := viewStart; i < viewEnd; i++ {
+		d := diagnostics[i]
+		cursor := " "
+		if m.cursor == i {
+			cursor = styleHighlight.Render(">")
+		}
+
+		kindStyle := styleMuted
+		switch d.Kind {
+		case docker.DiagnosticAmbiguous:
+			kindStyle = styleWarning
+		case docker.DiagnosticUnreadable:
+			kindStyle = styleError
+		}
+
+		b.WriteString(fmt.Sprintf("%s %s %s\n", cursor, kindStyle.Render(fmt.Sprintf("%-10s", d.Kind)), d.Path))
+		b.WriteString(styleMuted.Render(fmt.Sprintf("             %s", d.Message)))
+		b.WriteString("\n")
+	}
+
+	if viewEnd < len(diagnostics) {
+		b.WriteString(styleMuted.Render("▼ More below - scroll down\n"))
+	} else {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(styleHelp.Render("Found during the last scan. Use ↑/↓ to scroll, Esc/q to go back"))
+
+	return styleBox.Render(b.String())
+}
+
+// viewUnmanaged renders the unmanaged stacks list
+func (m Model) viewUnmanaged() string {
+	var b strings.Builder
+
+	b.WriteString(styleTitle.Render("Unmanaged Stacks"))
+	b.WriteString("\n\n")
+
+	if m.loadingUnmanaged {
+		b.WriteString(styleInfo.Render("⏳ Listing compose containers...\n"))
+		return styleBox.Render(b.String())
+	}
+	if m.err != nil {
+		b.WriteString(styleError.Render(fmt.Sprintf("❌ Error: %v\n", m.err)))
+		b.WriteString("\n")
+	} else if m.message != "" {
+		b.WriteString(styleSuccess.Render(fmt.Sprintf("✓ %s\n", m.message)))
+		b.WriteString("\n")
+	}
+
+	if len(m.unmanaged) == 0 {
+		b.WriteString(styleSuccess.Render("✓ All compose containers belong to managed projects"))
+		b.WriteString("\n\n")
+		b.WriteString(styleHelp.Render("Press u to reload, Esc/q to go back"))
+		return styleBox.Render(b.String())
+	}
+
+	viewStart := m.viewportOffset
+	viewEnd := m.viewportOffset + maxVisibleItems
+	if viewEnd > len(m.unmanaged) {
+		viewEnd = len(m.unmanaged)
+	}
+
+	if viewStart > 0 {
+		b.WriteString(styleMuted.Render("▲ More above - scroll up\n"))
+	} else {
+		b.WriteString("\n")
+	}
+
+	for i := viewStart; i < viewEnd; i++ {
+		stack := m.unmanaged[i]
+		cursor := " "
+		name := fmt.Sprintf("%-20s", truncateMiddle(stack.Name, 20))
+		if m.cursor == i {
+			cursor = styleHighlight.Render(">")
+			name = styleHighlight.Render(name)
+		}
+
+		status := styleMuted.Render(fmt.Sprintf("Stopped (%d)", len(stack.Containers)))
+		if stack.Running > 0 {
+			status = styleSuccess.Render(fmt.Sprintf("Running (%d/%d)", stack.Running, len(stack.Containers)))
+		}
+
+		dir := stack.WorkingDir
+		if dir == "" {
+			dir = "unknown directory"
+		}
+		if stack.Host != "" {
+			dir = stack.Host + ":" + dir
+		}
+		b.WriteString(fmt.Sprintf("%s %s %s\n", cursor, name, status))
+		b.WriteString(styleMuted.Render(fmt.Sprintf("  %s - %s", dir, stack.Reason)))
+		b.WriteString("\n")
+	}
+
+	if viewEnd < len(m.unmanaged) {
+		b.WriteString(styleMuted.Render("▼ More below - scroll down\n"))
+	} else {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(styleHelp.Render("Use ↑/↓ to navigate, Enter for actions, u to reload, Esc/q to go back"))
+
+	return styleBox.Render(b.String())
+}
+
+// viewUnmanagedAction renders the action menu of an unmanaged stack
+func (m Model) viewUnmanagedAction() string {
+	if m.selectedStack == nil {
+		return "No stack selected"
+	}
+	stack := m.selectedStack
+
+	var b strings.Builder
+
+	b.WriteString(styleTitle.Render(fmt.Sprintf("Unmanaged Stack: %s", stack.Name)))
+	b.WriteString("\n\n")
+
+	dir := stack.WorkingDir
+	if dir == "" {
+		dir = "unknown"
+	}
+	if stack.Host != "" {
+		b.WriteString(styleInfo.Render(fmt.Sprintf("Host: %s", stack.Host)))
+		b.WriteString("\n")
+	}
+	b.WriteString(styleInfo.Render(fmt.Sprintf("Started from: %s", dir)))
+	b.WriteString("\n")
+	for _, f := range stack.ConfigFiles {
+		b.WriteString(styleInfo.Render(fmt.Sprintf("Compose File: %s", f)))
+		b.WriteString("\n")
 	}
+	b.WriteString(styleWarning.Render(fmt.Sprintf("⚠ %s", stack.Reason)))
+	b.WriteString("\n\n")
 
-	// Bottom scroll indicator (fixed space)
-	if viewEnd < len(m.projects) {
-		b.WriteString(styleMuted.Render("▼ More below - scroll down\n"))
-	} else {
-		b.WriteString("\n")
+	b.WriteString(styleHighlight.Render(fmt.Sprintf("📦 Containers (%d running)", stack.Running)))
+	b.WriteString("\n")
+	for _, name := range stack.Names {
+		b.WriteString(fmt.Sprintf("  %s\n", name))
 	}
+	b.WriteString("\n")
 
+	if m.loading {
+		b.WriteString(styleInfo.Render("⏳ Processing...\n"))
+	} else if m.err != nil {
+		b.WriteString(styleError.Render(fmt.Sprintf("❌ Error: %v\n", m.err)))
+	}
+	if !m.loading && m.plan != nil {
+		b.WriteString(styleSuccess.Render(fmt.Sprintf("✓ %s\n", m.message)))
+		b.WriteString(viewPlan(m.plan))
+	}
 	b.WriteString("\n")
-	b.WriteString(styleHelp.Render("Use ↑/↓ or 1-9/0 to navigate, Enter to select, Esc/q to go back"))
 
-	if m.message != "" {
-		b.WriteString("\n\n")
-		b.WriteString(styleInfo.Render(m.message))
+	for i, action := range m.unmanagedOptions() {
+		option := action.label
+		cursor := " "
+		if m.cursor == i {
+			cursor = styleHighlight.Render(">")
+			option = styleHighlight.Render(option)
+		}
+		b.WriteString(fmt.Sprintf("%s %s\n", cursor, option))
 	}
 
+	b.WriteString("\n")
+	b.WriteString(styleHelp.Render("Use ↑/↓ to navigate, Enter to select, Esc/q to go back"))
+
 	return styleBox.Render(b.String())
 }
 
@@ -959,22 +1904,22 @@ func (m Model) viewActionMenu() string {
 
 	if m.loading {
 		b.WriteString(styleInfo.Render("⏳ Processing...\n"))
+	} else if m.confirmRestore != "" {
+		b.WriteString(styleWarning.Render("⚠ Restoring replaces the current contents of the volumes and stops the project meanwhile.\n"))
+		b.WriteString(styleWarning.Render("  Press Enter again to restore.\n"))
 	} else if m.err != nil {
 		b.WriteString(styleError.Render(fmt.Sprintf("❌ Error: %v\n", m.err)))
 	} else if m.message != "" {
 		b.WriteString(styleSuccess.Render(fmt.Sprintf("✓ %s\n", m.message)))
 	}
+	if !m.loading && m.plan != nil {
+		b.WriteString(viewPlan(m.plan))
+	}
 
 	b.WriteString("\n")
 
-	var options []string
-	if m.selectedProject.IsRunning() {
-		options = []string{"Stop containers", "Restart containers"}
-	} else {
-		options = []string{"Start containers"}
-	}
-
-	for i, option := range options {
+	for i, action := range m.actionOptions() {
+		option := action.label
 		cursor := " "
 		if m.cursor == i {
 			cursor = styleHighlight.Render(">")
@@ -1017,15 +1962,20 @@ func (m Model) viewConfirmExit() string {
 func (m Model) viewUpdateList() string {
 	var b strings.Builder
 
-	b.WriteString(styleTitle.Render("Select Projects to Update"))
+	title := "Select Projects to Update"
+	if len(m.hosts) > 1 {
+		title += " - " + m.hostLabel()
+	}
+	b.WriteString(styleTitle.Render(title))
 	b.WriteString("\n\n")
 
 	// Show cache age and refresh status - PLAIN TEXT
 	if m.checkingUpdates {
-		b.WriteString("⏳ Checking for updates...")
+		b.WriteString(fmt.Sprintf("⏳ Checking for updates... (%d/%d, %d running)",
+			m.checksCompleted, len(m.projects), len(m.checkingProjects)))
 		b.WriteString("\n\n")
 	} else if m.cacheAge != "" {
-		b.WriteString(fmt.Sprintf("Cache updated: %s", m.cacheAge))
+		b.WriteString(fmt.Sprintf("Update check: %s", m.cacheAge))
 		b.WriteString("\n\n")
 	}
 
@@ -1084,7 +2034,7 @@ func (m Model) viewUpdateList() string {
 
 		// Show spinner if this project is currently being checked
 		spinner := ""
-		if m.checkingUpdates && m.currentCheckIndex == i {
+		if m.checkingUpdates && m.checkingProjects[i] {
 			spinner = " ⏳"
 		}
 
@@ -1156,6 +2106,9 @@ func (m Model) viewUpdateList() string {
 				// Prepare all version data BEFORE building lines
 				localVersion := truncateMiddle(img.CurrentVersion, cw.Local)
 				repoVersion := truncateMiddle(img.LatestVersion, cw.Repository)
+				if img.HeldBack {
+					repoVersion = truncateMiddle("held "+img.LatestVersion, cw.Repository)
+				}
 				imgNameTrunc := truncateMiddle(imgName, cw.Image)
 				imgTagTrunc := truncateMiddle(imgTag, cw.Tag)
 
@@ -1164,7 +2117,12 @@ func (m Model) viewUpdateList() string {
 				if firstImg {
 					// First image: show complete line with project name
 					updateIndicator := "  "
-					if hasUpdates {
+					switch policy := project.UpdatePolicy(); {
+					case policy == docker.PolicyPinned:
+						updateIndicator = "📌"
+					case hasUpdates && policy == docker.PolicyNotify:
+						updateIndicator = "🔔"
+					case hasUpdates:
 						updateIndicator = "⬆ "
 					}
 					// Build COMPLETE line: 2sp + cursor(1) + sp + number(4) + sp + checkbox(4) + sp + name(16) + indicator(2) + 2sp + image(20) + 2sp + tag(12) + 2sp + local(15) + 2sp + repo
@@ -1235,7 +2193,16 @@ func (m Model) viewUpdateList() string {
 		// Always reserve space for the "Selected" line to maintain consistent height
 		b.WriteString("\n")
 	}
-	b.WriteString(styleHelp.Render("1-9/0 or Space to select, 'a' select all, 'u' update cache, Enter to continue, Esc/q to go back"))
+	if m.checkingUpdates {
+		b.WriteString(styleHelp.Render("1-9/0 or Space to select, 'a' select all, 'x' cancel check, Enter to continue, Esc/q to go back"))
+	} else {
+		b.WriteString(styleHelp.Render("1-9/0 or Space to select, 'a' select all, 'u' update cache, Enter to continue, Esc/q to go back"))
+	}
+
+	if m.message != "" {
+		b.WriteString("\n\n")
+		b.WriteString(styleInfo.Render(m.message))
+	}
 
 	return styleBox.Render(b.String())
 }
@@ -1246,7 +2213,34 @@ func (m Model) viewUpdateModeSelect() string {
 
 	b.WriteString(styleTitle.Render("Select Update Mode"))
 	b.WriteString("\n\n")
-	b.WriteString(fmt.Sprintf("%d project(s) selected for update\n\n", len(m.selectedUpdates)))
+	b.WriteString(fmt.Sprintf("%d project(s) selected for update\n", len(m.selectedUpdates)))
+	b.WriteString(styleInfo.Render("Scheduling: " + m.schedulingDisplay()))
+	b.WriteString("\n")
+	b.WriteString(styleInfo.Render("Strategy: " + m.strategyDisplay()))
+	b.WriteString("\n")
+	b.WriteString(styleInfo.Render("Backup: " + m.backupDisplay()))
+	b.WriteString("\n\n")
+
+	// Strategy each project will be restarted with
+	shown := 0
+	for _, p := range m.selectedProjects() {
+		if shown == maxVisibleItems {
+			b.WriteString(styleMuted.Render(fmt.Sprintf("  ... and %d more", len(m.selectedUpdates)-shown)))
+			b.WriteString("\n")
+			break
+		}
+		strategy := string(m.strategyOverride)
+		if strategy == "" {
+			strategy = "..."
+			if s, ok := m.projectStrategies[p.ID()]; ok {
+				strategy = string(s)
+			}
+		}
+		b.WriteString(styleMuted.Render(fmt.Sprintf("  %-30s %s", truncateMiddle(p.Name, 30), strategy)))
+		b.WriteString("\n")
+		shown++
+	}
+	b.WriteString("\n")
 
 	options := []string{
 		"Pull Images Only (no restart)",
@@ -1263,11 +2257,40 @@ func (m Model) viewUpdateModeSelect() string {
 	}
 
 	b.WriteString("\n")
-	b.WriteString(styleHelp.Render("Use ↑/↓ to navigate, Enter to select, Esc/q to go back"))
+	b.WriteString(styleHelp.Render("Use ↑/↓ to navigate, Enter to select, 's' toggle serial, 't' change strategy, 'b' toggle backup, Esc/q to go back"))
 
 	return styleBox.Render(b.String())
 }
 
+// strategyDisplay describes how the containers of the next batch will be recreated
+func (m Model) strategyDisplay() string {
+	switch m.strategyOverride {
+	case "":
+		return "per project (dcm.update.strategy / config)"
+	case docker.StrategyFull:
+		return "full for all (down, then recreate everything)"
+	case docker.StrategyChanged:
+		return "changed for all (recreate only services with a new image)"
+	}
+	return "rolling for all (one service at a time, waiting until healthy)"
+}
+
+// backupDisplay describes whether the next batch backs up volumes first
+func (m Model) backupDisplay() string {
+	if m.backupBeforeUpdate {
+		return fmt.Sprintf("volumes before restarting (%s)", m.options.Backup.Dir)
+	}
+	return "off"
+}
+
+// schedulingDisplay describes how the next batch of updates will be run
+func (m Model) schedulingDisplay() string {
+	if m.scheduler.Serial {
+		return "serial, ordered by priority"
+	}
+	return fmt.Sprintf("parallel (max %d at a time), ordered by priority", m.scheduler.MaxParallel)
+}
+
 // viewUpdateRestartConfirm renders the restart confirmation screen
 func (m Model) viewUpdateRestartConfirm() string {
 	var b strings.Builder
@@ -1309,7 +2332,13 @@ func (m Model) viewUpdateRestartConfirm() string {
 	selectedCount := len(m.selectedRestarts)
 	b.WriteString(styleInfo.Render(fmt.Sprintf("Will restart: %d project(s)", selectedCount)))
 	b.WriteString("\n")
-	b.WriteString(styleHelp.Render("Space to toggle, Enter to continue, Esc/q to go back"))
+	b.WriteString(styleInfo.Render("Scheduling: " + m.schedulingDisplay()))
+	b.WriteString("\n")
+	b.WriteString(styleInfo.Render("Strategy: " + m.strategyDisplay()))
+	b.WriteString("\n")
+	b.WriteString(styleInfo.Render("Backup: " + m.backupDisplay()))
+	b.WriteString("\n")
+	b.WriteString(styleHelp.Render("Space to toggle, 's' toggle serial, 't' change strategy, 'b' toggle backup, Enter to continue, Esc/q to go back"))
 
 	return styleBox.Render(b.String())
 }
@@ -1421,26 +2450,27 @@ func (m Model) viewUpdating() string {
 		"Nr.", "Name", "Ergebnis", "Status")))
 	b.WriteString("\n")
 
-	// Table rows - show all selected projects
+	// Table rows - show all selected projects in queue order
 	rowNum := 1
-	for i, project := range m.projects {
-		if !m.selectedUpdates[i] {
-			continue
-		}
-
+	for _, i := range m.updateQueueOrder() {
+		project := m.projects[i]
 		status := m.projectUpdateStatus[i]
 		result := m.projectUpdateResult[i]
 		statusIcon := ""
 
 		switch status {
 		case "pending":
-			statusIcon = ""
-		case "updating":
+			if pos, ok := m.updateQueue[i]; ok && result == "" {
+				result = fmt.Sprintf("queued #%d", pos)
+			}
+		case "updating", "backingup", "verifying":
 			statusIcon = "⏳" // Double-width emoji (2 chars)
 		case "success":
 			statusIcon = "✓ " // Single-width + space to match hourglass width
 		case "failed":
 			statusIcon = "✗ " // Single-width + space to match hourglass width
+		case "rolledback":
+			statusIcon = "↩ "
 		}
 
 		// Truncate long names and results
@@ -1467,6 +2497,12 @@ func (m Model) viewUpdating() string {
 		} else if status == "failed" {
 			visibleLen := utf8.RuneCountInString(result)
 			resultStr = styleError.Render(result) + strings.Repeat(" ", 30-visibleLen)
+		} else if status == "rolledback" {
+			visibleLen := utf8.RuneCountInString(result)
+			resultStr = styleWarning.Render(result) + strings.Repeat(" ", 30-visibleLen)
+		} else if status == "pending" || status == "backingup" || status == "verifying" {
+			visibleLen := utf8.RuneCountInString(result)
+			resultStr = styleMuted.Render(result) + strings.Repeat(" ", 30-visibleLen)
 		} else {
 			visibleLen := utf8.RuneCountInString(result)
 			resultStr = result + strings.Repeat(" ", 30-visibleLen)
@@ -1480,7 +2516,9 @@ func (m Model) viewUpdating() string {
 			statusStr = styleSuccess.Render(statusIcon)
 		} else if status == "failed" {
 			statusStr = styleError.Render(statusIcon)
-		} else if status == "updating" {
+		} else if status == "rolledback" {
+			statusStr = styleWarning.Render(statusIcon)
+		} else if status == "updating" || status == "backingup" || status == "verifying" {
 			statusStr = styleHighlight.Render(statusIcon)
 		} else {
 			statusStr = statusIcon
@@ -1499,19 +2537,71 @@ func (m Model) viewUpdating() string {
 		rowNum++
 	}
 
+	// Output of the pre/post hooks, last lines of each project
+	var hookLines []string
+	for _, i := range m.updateQueueOrder() {
+		log := m.projects[i].HookLog
+		switch m.projectUpdateStatus[i] {
+		case "success", "failed", "rolledback":
+		default:
+			continue // Hooks may still be writing it
+		}
+		if len(log) > maxHookOutputLines {
+			log = log[len(log)-maxHookOutputLines:]
+		}
+		for _, line := range log {
+			hookLines = append(hookLines, truncateMiddle(fmt.Sprintf("%s %s", m.projects[i].Name, line), 90))
+		}
+	}
+	if len(hookLines) > 0 {
+		b.WriteString("\n")
+		b.WriteString(styleMuted.Render("Hook output:"))
+		b.WriteString("\n")
+		for _, line := range hookLines {
+			b.WriteString(styleMuted.Render("  " + line))
+			b.WriteString("\n")
+		}
+	}
+
+	// Commands of a dry run, per finished project
+	for _, i := range m.updateQueueOrder() {
+		plan, ok := m.projectPlans[i]
+		if !ok {
+			continue
+		}
+		b.WriteString("\n")
+		b.WriteString(styleHighlight.Render(fmt.Sprintf("Planned commands for %s:", m.projects[i].Name)))
+		b.WriteString("\n")
+		b.WriteString(viewPlan(plan))
+	}
+
 	// Status message at the bottom
 	b.WriteString("\n")
 	if m.loading {
-		// Count how many are currently updating
-		updatingCount := 0
+		// Count queue states
+		pendingCount, updatingCount := 0, 0
 		for idx := range m.selectedUpdates {
-			if m.projectUpdateStatus[idx] == "updating" {
+			switch m.projectUpdateStatus[idx] {
+			case "pending":
+				pendingCount++
+			case "updating", "backingup", "verifying":
 				updatingCount++
 			}
 		}
-		b.WriteString(styleInfo.Render(fmt.Sprintf("⏳ Updating %d project(s) in parallel...", updatingCount)))
+		if m.cancellingUpdates {
+			b.WriteString(styleError.Render(fmt.Sprintf("⏹ Cancelling... waiting for %d running update(s) to stop", updatingCount)))
+		} else {
+			b.WriteString(styleInfo.Render(fmt.Sprintf("⏳ Pending: %d  Running: %d  Done: %d  (%s)",
+				pendingCount, updatingCount, m.updatesCompleted, m.schedulingDisplay())))
+			b.WriteString("\n\n")
+			b.WriteString(styleHelp.Render("Press x to cancel"))
+		}
 	} else {
-		b.WriteString(styleSuccess.Render("✓ All updates completed!"))
+		if m.dryRun {
+			b.WriteString(styleSuccess.Render("✓ Dry run completed, nothing was changed"))
+		} else {
+			b.WriteString(styleSuccess.Render("✓ All updates completed!"))
+		}
 		b.WriteString("\n\n")
 		b.WriteString(styleHelp.Render("Press any key to continue..."))
 	}
@@ -1519,6 +2609,57 @@ func (m Model) viewUpdating() string {
 	return styleBox.Render(b.String())
 }
 
+// viewPlan renders the steps of a dry run, at most maxPlanLines of them
+func viewPlan(plan []docker.PlannedCommand) string {
+	var b strings.Builder
+	if len(plan) == 0 {
+		b.WriteString(styleMuted.Render("  (nothing to run)"))
+		b.WriteString("\n")
+	}
+	for i, step := range plan {
+		if i == maxPlanLines {
+			b.WriteString(styleMuted.Render(fmt.Sprintf("  ... %d more", len(plan)-maxPlanLines)))
+			b.WriteString("\n")
+			break
+		}
+		b.WriteString(styleMuted.Render("  " + truncateMiddle(step.String(), 100)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// selectedProjects returns the projects selected for update in list order
+func (m Model) selectedProjects() []*docker.Project {
+	var projects []*docker.Project
+	for i, p := range m.projects {
+		if m.selectedUpdates[i] {
+			projects = append(projects, p)
+		}
+	}
+	return projects
+}
+
+// updateQueueOrder returns the selected project indices ordered by queue position.
+// Projects the scheduler hasn't queued yet keep their list order at the end.
+func (m Model) updateQueueOrder() []int {
+	var order []int
+	for i := range m.projects {
+		if m.selectedUpdates[i] {
+			order = append(order, i)
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		posA, okA := m.updateQueue[order[a]]
+		posB, okB := m.updateQueue[order[b]]
+		if okA != okB {
+			return okA
+		}
+		return posA < posB
+	})
+	return order
+}
+
 // renderProgressBar creates a text-based progress bar
 func renderProgressBar(percent, width int) string {
 	if percent < 0 {
@@ -1549,11 +2690,18 @@ func (m Model) viewHelp() string {
 	b.WriteString(styleHighlight.Render("⌨️  Keyboard Shortcuts"))
 	b.WriteString("\n")
 	b.WriteString("  ↑/↓ or k/j      Navigate menu items\n")
-	b.WriteString("  1, 2, 3         Direct menu selection (main menu only)\n")
+	b.WriteString("  1, 2, 3, 4      Direct menu selection (main menu only)\n")
 	b.WriteString("  Enter           Select item / Confirm action\n")
 	b.WriteString("  Space           Toggle selection (in update/restart lists)\n")
-	b.WriteString("  a               Select all / Deselect all (in lists)\n")
+	b.WriteString("  a               Select all / Deselect all (in lists; skips notify-only and pinned)\n")
 	b.WriteString("  r               Refresh update check (in update screen)\n")
+	b.WriteString("  u               Reload the list (in unmanaged stacks screen)\n")
+	b.WriteString("  s               Toggle serial/parallel updates (in update mode screen)\n")
+	b.WriteString("  t               Cycle update strategy: per project/full/changed/rolling (in update mode screen)\n")
+	b.WriteString("  b               Toggle volume backup before restarting (in update mode screen)\n")
+	b.WriteString("  d               Toggle dry run (show commands instead of running them)\n")
+	b.WriteString("  x               Cancel running update check or updates\n")
+	b.WriteString("  h               Switch between all hosts and a single host\n")
 	b.WriteString("  Esc or q        Go back to previous screen\n")
 	b.WriteString("  Ctrl+C          Force quit application\n\n")
 
@@ -1566,7 +2714,14 @@ func (m Model) viewHelp() string {
 	b.WriteString("    - Pull & Restart: Download and recreate containers\n")
 	b.WriteString("  • Cache System: Fast startup with background update checks\n")
 	b.WriteString("  • Version Display: Shows current and available versions\n")
-	b.WriteString("  • Multi-Select: Update multiple projects at once\n\n")
+	b.WriteString("  • Multi-Select: Update multiple projects at once\n")
+	b.WriteString("  • Update Policies: 📌 pinned, 🔔 notify-only and patch-only projects (dcm.update.policy)\n")
+	b.WriteString("  • Discovery Diagnostics: Ambiguous compose files, unreadable and skipped paths\n")
+	b.WriteString("  • Unmanaged Stacks: Stop, remove or adopt compose stacks started elsewhere\n")
+	b.WriteString("  • Multiple Hosts: Projects on docker contexts, TCP and SSH hosts\n")
+	b.WriteString("  • Volume Backups: Back up and restore volumes from a project's action menu\n")
+	b.WriteString("  • Dry Run: Show the commands of operations without running them (--dry-run)\n")
+	b.WriteString("  • Lock Status: 🔒 projects whose images are pinned to digests (lock command)\n\n")
 
 	b.WriteString(styleHighlight.Render("🔄 Update Workflow"))
 	b.WriteString("\n")
@@ -1588,6 +2743,17 @@ func (m Model) viewHelp() string {
 	return styleBox.Render(b.String())
 }
 
+// formatSize formats a size in bytes for display
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	}
+	return fmt.Sprintf("%d KB", bytes/1024)
+}
+
 // viewContainerDetail renders detailed view of containers in a project
 func (m Model) viewContainerDetail() string {
 	if m.selectedProject == nil {
@@ -1596,19 +2762,62 @@ func (m Model) viewContainerDetail() string {
 
 	// If ImageInfo is empty, try to get running container info
 	if len(m.selectedProject.ImageInfo) == 0 {
-		m.selectedProject.GetRunningContainerInfo()
+		m.selectedProject.GetRunningContainerInfo(m.ctx)
 	}
 
 	var b strings.Builder
 	b.WriteString(styleTitle.Render(fmt.Sprintf("Project: %s", m.selectedProject.Name)))
 	b.WriteString("\n\n")
 
+	if m.selectedProject.Host != "" {
+		b.WriteString(styleInfo.Render(fmt.Sprintf("Host: %s", m.selectedProject.Host)))
+		b.WriteString("\n")
+	}
 	b.WriteString(styleInfo.Render(fmt.Sprintf("Path: %s", m.selectedProject.Path)))
 	b.WriteString("\n")
 	b.WriteString(styleInfo.Render(fmt.Sprintf("Compose File: %s", m.selectedProject.ComposeFile)))
 	b.WriteString("\n")
 	b.WriteString(styleInfo.Render(fmt.Sprintf("Status: %s", m.selectedProject.Status)))
-	b.WriteString("\n\n")
+	b.WriteString("\n")
+	if len(m.selectedProject.Unhealthy) > 0 {
+		b.WriteString(styleError.Render(fmt.Sprintf("✗ Unhealthy: %s", strings.Join(m.selectedProject.Unhealthy, ", "))))
+		b.WriteString("\n")
+	}
+	if !m.selectedProject.DriftChecked && m.selectedProject.ComposeChanged {
+		b.WriteString(styleWarning.Render("⚠ Compose file changed since the containers were created - restart to apply"))
+		b.WriteString("\n")
+	}
+	switch m.selectedProject.LockState {
+	case docker.LockLocked:
+		b.WriteString(styleInfo.Render("🔒 Locked: all images are pinned to digests and running"))
+		b.WriteString("\n")
+	case docker.LockPartial:
+		b.WriteString(styleWarning.Render("🔒 Partially locked: some images follow their tags (the lock command pins them)"))
+		b.WriteString("\n")
+	case docker.LockStale:
+		b.WriteString(styleWarning.Render("🔒 Locked to new digests, containers still run the old images - apply to switch"))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	for _, warning := range m.selectedProject.Warnings {
+		b.WriteString(styleWarning.Render("⚠ " + warning))
+		b.WriteString("\n")
+	}
+	if len(m.selectedProject.Warnings) > 0 {
+		b.WriteString("\n")
+	}
+
+	if len(m.selectedProject.Drift) > 0 {
+		b.WriteString(styleWarning.Render("⚠ Configuration drift - containers differ from the compose file"))
+		b.WriteString("\n\n")
+		for _, d := range m.selectedProject.Drift {
+			b.WriteString(styleWarning.Render(fmt.Sprintf("  %-20s  ", truncateMiddle(d.Service, 20))))
+			b.WriteString(styleMuted.Render(d.Reason))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
 
 	// Show containers/images
 	b.WriteString(styleHighlight.Render("📦 Containers & Images"))
@@ -1623,9 +2832,10 @@ func (m Model) viewContainerDetail() string {
 		b.WriteString(styleHighlight.Render(fmt.Sprintf("%-20s  ", "Image")))
 		b.WriteString(styleHighlight.Render(fmt.Sprintf("%-12s  ", "Tag")))
 		b.WriteString(styleHighlight.Render(fmt.Sprintf("%-15s  ", "Lokal")))
-		b.WriteString(styleHighlight.Render("Repository"))
+		b.WriteString(styleHighlight.Render(fmt.Sprintf("%-15s  ", "Repository")))
+		b.WriteString(styleHighlight.Render("Checked"))
 		b.WriteString("\n")
-		b.WriteString(styleMuted.Render("  ──────  ────────────────────  ────────────  ───────────────  ──────────────"))
+		b.WriteString(styleMuted.Render("  ──────  ────────────────────  ────────────  ───────────────  ───────────────  ──────────"))
 		b.WriteString("\n")
 
 		// Sort image names for consistent display order
@@ -1666,7 +2876,35 @@ func (m Model) viewContainerDetail() string {
 			b.WriteString(styleInfo.Render(fmt.Sprintf("%-20s  ", truncateMiddle(imgName, 20))))
 			b.WriteString(styleMuted.Render(fmt.Sprintf("%-12s  ", truncateMiddle(imgTag, 12))))
 			b.WriteString(fmt.Sprintf("%-15s  ", truncateMiddle(img.CurrentVersion, 15)))
-			b.WriteString(fmt.Sprintf("%s\n", truncateMiddle(img.LatestVersion, 15)))
+			b.WriteString(fmt.Sprintf("%-15s  ", truncateMiddle(img.LatestVersion, 15)))
+			b.WriteString(styleMuted.Render(formatAge(img.CheckedAt)))
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(styleHighlight.Render("💾 Backups"))
+	b.WriteString("\n\n")
+	switch {
+	case m.loadingBackups:
+		b.WriteString(styleMuted.Render("Loading backups..."))
+		b.WriteString("\n")
+	case m.backupsErr != nil:
+		b.WriteString(styleError.Render(fmt.Sprintf("✗ %v", m.backupsErr)))
+		b.WriteString("\n")
+	case len(m.backups) == 0:
+		b.WriteString(styleMuted.Render(fmt.Sprintf("No backups in %s/%s", m.selectedProject.BackupDir(), m.selectedProject.Name)))
+		b.WriteString("\n")
+	default:
+		for _, backup := range m.backups {
+			var sources []string
+			for _, mount := range backup.Mounts {
+				sources = append(sources, mount.Source)
+			}
+			b.WriteString(styleInfo.Render(fmt.Sprintf("  %-19s  ", backup.Created.Local().Format("2006-01-02 15:04:05"))))
+			b.WriteString(fmt.Sprintf("%9s  ", formatSize(backup.Size)))
+			b.WriteString(styleMuted.Render(truncateMiddle(strings.Join(sources, ", "), 60)))
+			b.WriteString("\n")
 		}
 	}
 
@@ -1701,6 +2939,9 @@ var (
 	styleInfo = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("111"))
 
+	styleWarning = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
 	styleMuted = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240"))
 
@@ -1717,101 +2958,346 @@ type updateCompleteMsg struct {
 	projectName  string
 	success      bool
 	err          error
+	plan         []docker.PlannedCommand // Set in dry runs
+}
+type updateQueuedMsg struct {
+	projectIndex int
+	position     int // 1-based position in the update queue
+}
+type updateStartedMsg struct {
+	projectIndex int
+}
+type updateBackingUpMsg struct {
+	projectIndex int
+}
+type updateVerifyingMsg struct {
+	projectIndex int
 }
 type allUpdatesCompleteMsg struct{}
 type updatesCheckedMsg struct{}
 type projectCheckProgressMsg struct {
-	index int // Index of project being checked
-}
-type projectCheckCompleteMsg struct {
-	index int // Index of project that was just checked
+	index   int                 // Index of project being checked
+	done    bool                // Check of this project has finished
+	project *docker.Project     // Project being checked
+	result  *docker.CheckResult // Result to apply when done
 }
 type tickMsg struct{} // Tick message to refresh view during updates
+type watchEventMsg struct {
+	event docker.WatchEvent
+}
+type driftCheckedMsg struct {
+	projects []*docker.Project            // Projects that were checked
+	results  map[string]docker.DriftResult // Drift and lock state by project path, missing if the check failed
+}
+type strategiesResolvedMsg struct {
+	strategies map[string]docker.UpdateStrategy // Strategy by project ID
+}
+type watchedProjectMsg struct {
+	project *docker.Project // Freshly loaded project for a written compose file
+}
+type statusTickMsg struct{} // Time for the periodic status refresh
+type unmanagedMsg struct {
+	stacks []docker.UnmanagedStack
+	err    error
+}
+type unmanagedDoneMsg struct {
+	message string
+	err     error
+	adopted *docker.Project // Project loaded by "adopt"
+}
+type containerEventMsg struct {
+	event docker.ContainerEvent
+}
+type planMsg struct {
+	steps []docker.PlannedCommand // Commands the operation would have run
+	err   error                   // The operation failed before the end of the plan
+}
+type backupsMsg struct {
+	projectID string          // Project the backups belong to
+	backups   []docker.Backup // Newest first
+	err       error
+}
+type backupDoneMsg struct {
+	message string
+	err     error
+}
+type statusesMsg struct {
+	projects []*docker.Project                // Projects that were queried
+	statuses map[string]docker.ProjectStatus // Status by project ID
+}
 
 // performOperation performs a container operation asynchronously
-func performOperation(project *docker.Project, operation string) tea.Cmd {
+func performOperation(ctx context.Context, project *docker.Project, operation string) tea.Cmd {
+	if operation == "backup" || strings.HasPrefix(operation, "restore:") {
+		return performBackupOperation(ctx, project, operation)
+	}
 	return func() tea.Msg {
 		var err error
 		switch operation {
 		case "start":
-			err = project.Start()
+			err = project.Start(ctx)
 		case "stop":
-			err = project.Stop()
+			err = project.Stop(ctx)
 		case "restart":
-			err = project.Restart()
+			err = project.Restart(ctx)
+		case "apply":
+			err = project.Apply(ctx)
 		}
 
 		if err != nil {
 			return errorMsg{err: err}
 		}
 
-		return operationMsg(fmt.Sprintf("Successfully %sed %s", operation, project.Name))
+		if operation == "apply" {
+			return operationMsg(fmt.Sprintf("Applied compose file to %s", project.Name))
+		}
+
+		msg := fmt.Sprintf("Successfully %sed %s", operation, project.Name)
+		if (operation == "start" || operation == "stop") && len(project.HookLog) > 0 {
+			msg += " - " + project.HookLog[len(project.HookLog)-1]
+		}
+		return operationMsg(msg)
+	}
+}
+
+// performBackupOperation backs up the volumes of a project or restores the
+// backup of a "restore:<id>" operation
+func performBackupOperation(ctx context.Context, project *docker.Project, operation string) tea.Cmd {
+	return func() tea.Msg {
+		if id, ok := strings.CutPrefix(operation, "restore:"); ok {
+			if err := project.Restore(ctx, id); err != nil {
+				return backupDoneMsg{err: err}
+			}
+			return backupDoneMsg{message: fmt.Sprintf("Restored backup %s of %s", id, project.Name)}
+		}
+
+		backup, err := project.Backup(ctx)
+		if err != nil {
+			return backupDoneMsg{err: err}
+		}
+		return backupDoneMsg{message: fmt.Sprintf("Backed up %d volume(s) of %s to %s/%s/%s",
+			len(backup.Mounts), project.Name, project.BackupDir(), project.Name, backup.ID)}
+	}
+}
+
+// dryRunOperation runs the operation of start with a recording context and
+// reports the planned commands
+func dryRunOperation(ctx context.Context, start func(ctx context.Context) tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		recorder := &docker.Recorder{}
+		var err error
+		switch msg := start(docker.WithDryRun(ctx, recorder))().(type) {
+		case errorMsg:
+			err = msg.err
+		case backupDoneMsg:
+			err = msg.err
+		case unmanagedDoneMsg:
+			err = msg.err
+		}
+		return planMsg{steps: recorder.Steps(), err: err}
+	}
+}
+
+// loadBackups lists the backups of a project, which runs a helper container
+func loadBackups(ctx context.Context, project *docker.Project) tea.Cmd {
+	return func() tea.Msg {
+		backups, err := project.Backups(ctx)
+		return backupsMsg{projectID: project.ID(), backups: backups, err: err}
 	}
 }
 
-// performUpdates performs updates for all selected projects in parallel
-func (m Model) performUpdates() tea.Cmd {
-	var cmds []tea.Cmd
+// performUpdates hands all selected projects to the update scheduler
+func (m *Model) performUpdates() tea.Cmd {
+	var jobs []docker.UpdateJob
 
 	for idx := range m.selectedUpdates {
 		if idx >= len(m.projects) {
 			continue
 		}
 
-		// Mark as updating immediately
-		m.projectUpdateStatus[idx] = "updating"
+		// Pull only mode never restarts; restart mode only restarts
+		// projects that were selected for restart
+		jobs = append(jobs, docker.UpdateJob{
+			Index:    idx,
+			Project:  m.projects[idx],
+			Restart:  m.updateMode != "pull" && m.selectedRestarts[idx],
+			Strategy: m.strategyOverride,
+			Backup:   m.backupBeforeUpdate,
+		})
+	}
 
-		project := m.projects[idx]
-		shouldRestart := m.selectedRestarts[idx]
-		mode := m.updateMode
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelUpdates = cancel
+	m.cancellingUpdates = false
+	m.updateQueue = make(map[int]int)
+	m.projectPlans = make(map[int][]docker.PlannedCommand)
+	m.scheduler.DryRun = m.dryRun
+	m.updateEvents = m.scheduler.Run(ctx, jobs)
+	return waitForUpdateEvent(m.updateEvents)
+}
 
-		// Create a command for this specific update
-		cmd := func(idx int, p *docker.Project, restart bool, updateMode string) tea.Cmd {
-			return func() tea.Msg {
-				var err error
+// resolveStrategies looks up the update strategy of each project, which
+// renders their compose files
+func resolveStrategies(ctx context.Context, projects []*docker.Project) tea.Cmd {
+	return func() tea.Msg {
+		strategies := make(map[string]docker.UpdateStrategy, len(projects))
+		for _, p := range projects {
+			strategies[p.ID()] = p.UpdateStrategy(ctx)
+		}
+		return strategiesResolvedMsg{strategies: strategies}
+	}
+}
 
-				// Determine operation based on mode
-				if updateMode == "pull" {
-					// Pull only mode - never restart
-					err = p.PullOnly()
-				} else if restart {
-					// Restart mode + project selected for restart
-					err = p.Update()
-				} else {
-					// Restart mode but project NOT selected for restart - pull only
-					err = p.PullOnly()
-				}
+// waitForUpdateEvent waits for the next event of the running update batch
+func waitForUpdateEvent(events <-chan docker.UpdateEvent) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return allUpdatesCompleteMsg{}
+		}
+
+		switch ev.State {
+		case docker.UpdateQueued:
+			return updateQueuedMsg{projectIndex: ev.Index, position: ev.Position}
+		case docker.UpdateRunning:
+			return updateStartedMsg{projectIndex: ev.Index}
+		case docker.UpdateBackingUp:
+			return updateBackingUpMsg{projectIndex: ev.Index}
+		case docker.UpdateVerifying:
+			return updateVerifyingMsg{projectIndex: ev.Index}
+		}
+		return updateCompleteMsg{
+			projectIndex: ev.Index,
+			projectName:  ev.Project.Name,
+			success:      ev.Err == nil,
+			err:          ev.Err,
+			plan:         ev.Plan,
+		}
+	}
+}
 
-				return updateCompleteMsg{
-					projectIndex: idx,
-					projectName:  p.Name,
-					success:      err == nil,
-					err:          err,
-				}
-			}
-		}(idx, project, shouldRestart, mode)
+// waitForCheckEvent waits for the next event of a running update check
+func waitForCheckEvent(events <-chan docker.CheckEvent) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return updatesCheckedMsg{}
+		}
+		return projectCheckProgressMsg{index: ev.Index, done: ev.Done, project: ev.Project, result: ev.Result}
+	}
+}
 
-		cmds = append(cmds, cmd)
+// waitForWatchEvent waits for the next change reported by the watcher
+func waitForWatchEvent(events <-chan docker.WatchEvent) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return nil
+		}
+		return watchEventMsg{event: ev}
 	}
+}
 
-	// Run all updates in parallel
-	return tea.Batch(cmds...)
+// loadWatchedProject loads a new or edited project including its status
+func loadWatchedProject(ctx context.Context, composeFile string) tea.Cmd {
+	return func() tea.Msg {
+		project, err := docker.NewProject(composeFile)
+		if err != nil {
+			return nil // Gone again already; the removal event follows
+		}
+		project.UpdateStatus(ctx)
+		return watchedProjectMsg{project: project}
+	}
+}
+
+// checkDrift compares the containers of the given projects with their compose files
+func checkDrift(ctx context.Context, projects []*docker.Project) tea.Cmd {
+	projects = append([]*docker.Project(nil), projects...)
+	return func() tea.Msg {
+		return driftCheckedMsg{
+			projects: projects,
+			results:  docker.CheckDrifts(ctx, projects),
+		}
+	}
+}
+
+// requestStatusRefresh starts a status refresh, or marks the running one as
+// stale so another follows when it's done
+func (m *Model) requestStatusRefresh() tea.Cmd {
+	if m.refreshingStatus {
+		m.statusStale = true
+		return nil
+	}
+	m.refreshingStatus = true
+	m.statusStale = false
+	return refreshStatuses(m.ctx, m.allProjects)
+}
+
+// waitForContainerEvent waits for the next container status change
+func waitForContainerEvent(events <-chan docker.ContainerEvent) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return nil
+		}
+		return containerEventMsg{event: ev}
+	}
+}
+
+// findUnmanaged lists the unmanaged compose stacks of all hosts in the background
+func findUnmanaged(ctx context.Context, hosts []*docker.Host, projects []*docker.Project, searchRoots []string) tea.Cmd {
+	projects = append([]*docker.Project(nil), projects...)
+	return func() tea.Msg {
+		stacks, err := docker.FindUnmanagedStacks(ctx, hosts, projects, searchRoots)
+		return unmanagedMsg{stacks: stacks, err: err}
+	}
 }
 
-// checkForUpdates starts checking for available updates (sequential)
-func (m Model) checkForUpdates() tea.Cmd {
-	// Start with the first project
+// performUnmanagedOperation stops, removes or adopts an unmanaged stack
+func performUnmanagedOperation(ctx context.Context, stack docker.UnmanagedStack, operation string) tea.Cmd {
 	return func() tea.Msg {
-		return projectCheckProgressMsg{index: 0}
+		switch operation {
+		case "stop":
+			if err := stack.Stop(ctx); err != nil {
+				return unmanagedDoneMsg{err: err}
+			}
+			return unmanagedDoneMsg{message: fmt.Sprintf("Stopped %s", stack.Name)}
+
+		case "remove":
+			if err := stack.Remove(ctx); err != nil {
+				return unmanagedDoneMsg{err: err}
+			}
+			return unmanagedDoneMsg{message: fmt.Sprintf("Removed %s", stack.Name)}
+
+		case "adopt":
+			project, err := stack.Adopt()
+			if err != nil {
+				return unmanagedDoneMsg{err: err}
+			}
+			project.UpdateStatus(ctx)
+			return unmanagedDoneMsg{
+				message: fmt.Sprintf("Adopted %s from %s", project.Name, project.Path),
+				adopted: project,
+			}
+		}
+		return unmanagedDoneMsg{}
 	}
 }
 
-// checkSingleProject checks a single project for updates
-func (m Model) checkSingleProject(index int) tea.Cmd {
+// scheduleStatusRefresh triggers the next status refresh after statusRefreshInterval
+func scheduleStatusRefresh() tea.Cmd {
+	return tea.Tick(statusRefreshInterval, func(time.Time) tea.Msg {
+		return statusTickMsg{}
+	})
+}
+
+// refreshStatuses queries the container status of all projects in the background
+func refreshStatuses(ctx context.Context, projects []*docker.Project) tea.Cmd {
+	projects = append([]*docker.Project(nil), projects...)
 	return func() tea.Msg {
-		if index >= 0 && index < len(m.projects) {
-			m.projects[index].UpdateImageInfo()
+		return statusesMsg{
+			projects: projects,
+			statuses: docker.CollectStatuses(ctx, projects),
 		}
-		return projectCheckCompleteMsg{index: index}
 	}
 }